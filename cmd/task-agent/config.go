@@ -0,0 +1,468 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/thecoolrobot/task-agent/internal/config"
+)
+
+// profileFlag is the global --profile flag.
+var profileFlag string
+
+func newConfigProfilesCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "profiles",
+		Short: "Manage named config profiles (per workspace or client)",
+	}
+
+	list := &cobra.Command{
+		Use:   "list",
+		Short: "List profiles; * marks the active one",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.LoadBase()
+			if err != nil {
+				return err
+			}
+			names := cfg.ProfileNames()
+			if len(names) == 0 {
+				fmt.Println("No profiles. Create one with: task-agent config profiles create <name>")
+				return nil
+			}
+			active, _, _ := config.SelectProfile(cfg, profileFlag)
+			fmt.Printf("\n  %-20s %-20s %s\n", "NAME", "WORKSPACE", "PROVIDER / MODEL")
+			fmt.Println(strings.Repeat("─", 70))
+			for _, name := range names {
+				eff := cfg.WithProfile(name)
+				marker := " "
+				if name == active {
+					marker = "*"
+				}
+				note := ""
+				if name == cfg.DefaultProfile {
+					note = "  (default)"
+				}
+				fmt.Printf("%s %-20s %-20s %s / %s%s\n", marker, name, eff.WorkspaceGID, eff.Provider, eff.Model, note)
+			}
+			fmt.Println()
+			return nil
+		},
+	}
+
+	var none bool
+	use := &cobra.Command{
+		Use:   "use <name>",
+		Short: "Make a profile the default (--none to use the base settings)",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if none {
+				return cobra.NoArgs(cmd, args)
+			}
+			return cobra.ExactArgs(1)(cmd, args)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.LoadBase()
+			if err != nil {
+				return err
+			}
+			cfg.DefaultProfile = ""
+			if !none {
+				if _, ok := cfg.Profiles[args[0]]; !ok {
+					return fmt.Errorf("%w %q", config.ErrUnknownProfile, args[0])
+				}
+				cfg.DefaultProfile = args[0]
+			}
+			if err := config.Save(cfg); err != nil {
+				return err
+			}
+			if none {
+				fmt.Println("✅ No default profile — using the base settings")
+			} else {
+				fmt.Printf("✅ Default profile: %s\n", args[0])
+			}
+			return nil
+		},
+	}
+	use.Flags().BoolVar(&none, "none", false, "Clear the default profile")
+
+	var p config.Profile
+	var makeDefault bool
+	create := &cobra.Command{
+		Use:   "create <name>",
+		Short: "Create a profile; unset fields inherit the base settings",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.LoadBase()
+			if err != nil {
+				return err
+			}
+			name := args[0]
+			if _, ok := cfg.Profiles[name]; ok {
+				return fmt.Errorf("profile %q already exists", name)
+			}
+			if cfg.Profiles == nil {
+				cfg.Profiles = map[string]*config.Profile{}
+			}
+			prof := p
+			cfg.Profiles[name] = &prof
+			if makeDefault {
+				cfg.DefaultProfile = name
+			}
+			if err := config.Save(cfg); err != nil {
+				return err
+			}
+			fmt.Printf("✅ Created profile %s\n", name)
+			return nil
+		},
+	}
+	create.Flags().StringVar(&p.WorkspaceGID, "workspace", "", "Asana workspace GID")
+	create.Flags().StringVar(&p.ProjectGID, "project", "", "Asana project GID")
+	create.Flags().StringVar(&p.Provider, "provider", "", "AI provider")
+	create.Flags().StringVar(&p.Model, "model", "", "Model")
+	create.Flags().StringVar(&p.OutputDir, "output-dir", "", "Output directory")
+	create.Flags().BoolVar(&makeDefault, "use", false, "Make it the default profile")
+
+	del := &cobra.Command{
+		Use:   "delete <name>",
+		Short: "Delete a profile",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.LoadBase()
+			if err != nil {
+				return err
+			}
+			if _, ok := cfg.Profiles[args[0]]; !ok {
+				return fmt.Errorf("%w %q", config.ErrUnknownProfile, args[0])
+			}
+			delete(cfg.Profiles, args[0])
+			if cfg.DefaultProfile == args[0] {
+				cfg.DefaultProfile = ""
+			}
+			if err := config.Save(cfg); err != nil {
+				return err
+			}
+			fmt.Printf("🗑  Deleted profile %s\n", args[0])
+			return nil
+		},
+	}
+
+	cmd.AddCommand(list, use, create, del)
+	return cmd
+}
+
+func newConfigShowCmd() *cobra.Command {
+	var resolved, asJSON bool
+	cmd := &cobra.Command{
+		Use:   "show",
+		Short: "Print the effective config (--resolved: with where each value came from)",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.LoadProfile(profileFlag)
+			if err != nil {
+				return err
+			}
+			for _, w := range cfg.Warnings() {
+				fmt.Fprintf(os.Stderr, "⚠️  %s\n", w)
+			}
+			settings := maskSettings(cfg.Resolved())
+			if !resolved {
+				tree := map[string]any{}
+				for _, s := range settings {
+					setKey(tree, s.Key, s.Value)
+				}
+				data, err := json.MarshalIndent(tree, "", "  ")
+				if err != nil {
+					return err
+				}
+				fmt.Println(string(data))
+				return nil
+			}
+			if asJSON {
+				return printJSON(settings)
+			}
+			project := cfg.ProjectPath()
+			if project == "" {
+				project = "(none)"
+			}
+			fmt.Printf("\n  User config   : %s\n", config.ConfigPath())
+			fmt.Printf("  Project config: %s\n", project)
+			if cfg.Active != "" {
+				fmt.Printf("  Profile       : %s\n", cfg.Active)
+			}
+			fmt.Printf("\n  %-32s %-28s %s\n", "KEY", "VALUE", "SOURCE")
+			fmt.Println(strings.Repeat("─", 90))
+			for _, s := range settings {
+				data, _ := json.Marshal(s.Value)
+				v := string(data)
+				if len([]rune(v)) > 28 {
+					v = string([]rune(v)[:27]) + "…"
+				}
+				fmt.Printf("  %-32s %-28s %s\n", s.Key, v, s.Source)
+			}
+			fmt.Println()
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&resolved, "resolved", false, "List every setting with its source (flag, env, project, user, default)")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "With --resolved, print JSON")
+	return cmd
+}
+
+// mask hides all but the last four characters of a secret.
+func mask(s string) string {
+	if len(s) <= 8 {
+		return strings.Repeat("*", 8)
+	}
+	return strings.Repeat("*", 8) + s[len(s)-4:]
+}
+
+// setKey stores v at dotted key in tree.
+func setKey(tree map[string]any, key string, v any) {
+	parts := strings.Split(key, ".")
+	for _, p := range parts[:len(parts)-1] {
+		sub, ok := tree[p].(map[string]any)
+		if !ok {
+			sub = map[string]any{}
+			tree[p] = sub
+		}
+		tree = sub
+	}
+	tree[parts[len(parts)-1]] = v
+}
+
+// printJSON writes v to stdout as indented JSON.
+func printJSON(v any) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func newConfigGetCmd() *cobra.Command {
+	var asJSON bool
+	cmd := &cobra.Command{
+		Use:   "get <key>",
+		Short: "Print one effective setting, e.g. model or verify.fix_attempts",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.LoadProfile(profileFlag)
+			if err != nil {
+				return err
+			}
+			v, err := cfg.Get(args[0])
+			if err != nil {
+				return err
+			}
+			if s, ok := v.(string); ok && !asJSON {
+				fmt.Println(s)
+				return nil
+			}
+			return printJSON(v)
+		},
+	}
+	cmd.Flags().BoolVar(&asJSON, "json", false, "Print the value as JSON")
+	return cmd
+}
+
+func newConfigSetCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "set <key> <value>",
+		Short: "Change a setting (lists are comma-separated; objects take JSON)",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return editConfig(args[0], func(cfg *config.Config) error { return cfg.Set(args[0], args[1]) })
+		},
+	}
+}
+
+func newConfigUnsetCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "unset <key>",
+		Short: "Reset a setting to its default (or remove a map entry such as api_keys.openai)",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return editConfig(args[0], func(cfg *config.Config) error { return cfg.Unset(args[0]) })
+		},
+	}
+}
+
+// editConfig applies edit to the config (or the active profile) and saves
+// it, warning when the project config hides the change.
+func editConfig(key string, edit func(*config.Config) error) error {
+	cfg, err := config.LoadProfile(profileFlag)
+	if err != nil {
+		return err
+	}
+	if err := edit(cfg); err != nil {
+		return err
+	}
+	if err := config.Save(cfg); err != nil {
+		return fmt.Errorf("saving config: %w", err)
+	}
+	where := config.ConfigPath()
+	if cfg.Active != "" {
+		where += " (profile " + cfg.Active + ")"
+	}
+	fmt.Printf("✅ %s saved to %s\n", key, where)
+	if src := cfg.Source(key); strings.HasPrefix(src, config.SourceProject) {
+		fmt.Fprintf(os.Stderr, "⚠️  %s is overridden by %s\n", key, strings.TrimPrefix(src, config.SourceProject+" "))
+	}
+	return nil
+}
+
+func newConfigListCmd() *cobra.Command {
+	var asJSON bool
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "Print every effective setting as key=value (API keys masked)",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.LoadProfile(profileFlag)
+			if err != nil {
+				return err
+			}
+			settings := maskSettings(cfg.Resolved())
+			if asJSON {
+				return printJSON(settings)
+			}
+			for _, s := range settings {
+				v, ok := s.Value.(string)
+				if !ok {
+					data, _ := json.Marshal(s.Value)
+					v = string(data)
+				}
+				fmt.Printf("%s=%s\n", s.Key, v)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&asJSON, "json", false, "Print key, value and source as JSON")
+	return cmd
+}
+
+func newConfigValidateCmd() *cobra.Command {
+	var asJSON bool
+	cmd := &cobra.Command{
+		Use:   "validate",
+		Short: "Check provider, model, API key, output dir, asana-cli, theme and keys; exits non-zero when invalid",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.LoadProfile(profileFlag)
+			var problems []string
+			if err != nil {
+				problems = []string{err.Error()}
+			} else {
+				problems = append(cfg.Warnings(), cfg.Validate()...)
+				problems = append(problems, tuiProblems(cfg)...)
+			}
+			if asJSON {
+				if problems == nil {
+					problems = []string{}
+				}
+				if err := printJSON(map[string]any{
+					"config":   config.ConfigPath(),
+					"project":  cfg.ProjectPath(),
+					"profile":  cfg.Active,
+					"valid":    len(problems) == 0,
+					"problems": problems,
+				}); err != nil {
+					return err
+				}
+			} else if len(problems) == 0 {
+				fmt.Printf("✅ Config is valid — %s / %s\n", cfg.Provider, cfg.Model)
+			} else {
+				for _, p := range problems {
+					fmt.Println("❌", p)
+				}
+			}
+			if len(problems) > 0 {
+				return fmt.Errorf("%d problem(s) in config", len(problems))
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&asJSON, "json", false, "Output the result as JSON")
+	return cmd
+}
+
+// maskSettings hides API keys in settings.
+func maskSettings(settings []config.Setting) []config.Setting {
+	for i, s := range settings {
+		if v, ok := s.Value.(string); ok && v != "" && config.IsSecret(s.Key) && !config.IsKeyRef(v) {
+			settings[i].Value = mask(v)
+		}
+	}
+	return settings
+}
+
+func newConfigMigrateKeysCmd() *cobra.Command {
+	var store string
+	var dryRun bool
+	cmd := &cobra.Command{
+		Use:   "migrate-keys",
+		Short: "Move plaintext API keys into the OS keyring or key files and store references instead",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.LoadBase()
+			if err != nil {
+				return err
+			}
+			moved, err := config.MigrateKeys(cfg, store, dryRun)
+			if err != nil {
+				return err
+			}
+			if len(moved) == 0 {
+				fmt.Println("No plaintext API keys in", config.ConfigPath())
+				return nil
+			}
+			verb := "Moved"
+			if dryRun {
+				verb = "Would move"
+			}
+			for _, m := range moved {
+				fmt.Printf("🔐 %s %s → %s\n", verb, m.Key, m.Ref)
+			}
+			if dryRun {
+				return nil
+			}
+			if err := config.Save(cfg); err != nil {
+				return fmt.Errorf("saving config: %w", err)
+			}
+			fmt.Printf("✅ %d key(s) moved; %s now holds references only\n", len(moved), config.ConfigPath())
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&store, "to", config.StoreKeyring, "Where to move keys: "+strings.Join(config.KeyStores, " or "))
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show what would move without changing anything")
+	return cmd
+}
+
+func newConfigEnvCmd() *cobra.Command {
+	var asJSON bool
+	cmd := &cobra.Command{
+		Use:   "env",
+		Short: "List the environment variables that override settings; * marks those set",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			vars := config.EnvVars()
+			if asJSON {
+				return printJSON(vars)
+			}
+			fmt.Printf("\n  %-38s %s\n", "VARIABLE", "SETTING")
+			fmt.Println(strings.Repeat("─", 70))
+			for _, ev := range vars {
+				marker := " "
+				if os.Getenv(ev.Name) != "" {
+					marker = "*"
+				}
+				fmt.Printf("%s %-38s %s\n", marker, ev.Name, ev.Key)
+			}
+			fmt.Printf("\n  %s selects a profile.\n\n", config.ProfileEnv)
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&asJSON, "json", false, "Print the variables as JSON")
+	return cmd
+}
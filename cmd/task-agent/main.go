@@ -1,18 +1,32 @@
 package main
 
 import (
+	"bufio"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/spf13/cobra"
 
 	"github.com/thecoolrobot/task-agent/internal/ai"
 	"github.com/thecoolrobot/task-agent/internal/asana"
+	"github.com/thecoolrobot/task-agent/internal/cassette"
+	"github.com/thecoolrobot/task-agent/internal/compare"
 	"github.com/thecoolrobot/task-agent/internal/config"
+	"github.com/thecoolrobot/task-agent/internal/editor"
+	"github.com/thecoolrobot/task-agent/internal/eval"
+	"github.com/thecoolrobot/task-agent/internal/export"
+	"github.com/thecoolrobot/task-agent/internal/history"
 	"github.com/thecoolrobot/task-agent/internal/output"
+	"github.com/thecoolrobot/task-agent/internal/runner"
 	"github.com/thecoolrobot/task-agent/internal/tui"
+	"github.com/thecoolrobot/task-agent/internal/verify"
 )
 
 // Set via ldflags at build time
@@ -25,19 +39,58 @@ var (
 func main() {
 	if err := newRoot().Execute(); err != nil {
 		fmt.Fprintln(os.Stderr, err)
+		if errors.Is(err, verify.ErrFailed) {
+			os.Exit(2)
+		}
 		os.Exit(1)
 	}
 }
 
 func loadConfig() *config.Config {
-	cfg, err := config.Load()
+	cfg, err := config.LoadProfile(profileFlag)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Warning: could not load config: %v\n", err)
 		return config.Defaults()
 	}
+	for _, w := range cfg.Warnings() {
+		fmt.Fprintf(os.Stderr, "⚠️  %s\n", w)
+	}
+	config.ApplyBaseURLs(cfg)
 	return cfg
 }
 
+// applyFlags overrides settings with the flags given on the command line;
+// keys maps a flag name to the setting it overrides.
+func applyFlags(cmd *cobra.Command, cfg *config.Config, keys map[string]string) error {
+	for name, key := range keys {
+		if f := cmd.Flags().Lookup(name); f != nil && f.Changed {
+			if err := cfg.SetFlag(key, f.Value.String(), "--"+name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// runTUI loads the user themes and starts the TUI, after warning about
+// theme and key binding problems.
+func runTUI(cfg *config.Config) error {
+	for _, w := range tuiProblems(cfg) {
+		fmt.Fprintf(os.Stderr, "⚠️  %s\n", w)
+	}
+	tui.Version = version
+	return tui.Run(cfg, newAsanaClient(cfg))
+}
+
+// tuiProblems loads the user themes and checks the theme and keys
+// settings, which only the TUI uses.
+func tuiProblems(cfg *config.Config) []string {
+	problems := tui.LoadThemes(config.ThemesDir())
+	problems = append(problems, tui.ThemeProblems(cfg.Theme, cfg.ThemeOverrides)...)
+	_, keyProblems := tui.NewKeyMap(cfg.Keys)
+	return append(problems, keyProblems...)
+}
+
 func newAsanaClient(cfg *config.Config) *asana.Client {
 	client, err := asana.NewClient(cfg.AsanaCLIPath)
 	if err != nil {
@@ -48,27 +101,61 @@ func newAsanaClient(cfg *config.Config) *asana.Client {
 	return client
 }
 
-func doExecute(task *asana.Task, providerID, model, outDir string, cfg *config.Config) error {
-	apiKey := config.GetAPIKey(cfg, providerID)
-	if providerID != "ollama" && apiKey == "" {
-		prov, _ := ai.GetProvider(providerID)
-		return fmt.Errorf("no API key for %s — set %s or run: task-agent config", prov.Name, prov.EnvKey)
+// doExecute runs task and prints the outcome. A non-empty prompt replaces the
+// rendered task message.
+func doExecute(task *asana.Task, providerID, model, outDir, prompt string, cfg *config.Config) error {
+	apiKey, err := config.LookupAPIKey(cfg, providerID)
+	if err != nil {
+		return err
+	}
+	if _, ok := ai.GetProvider(providerID); !ok {
+		return fmt.Errorf("unknown provider: %s — run: task-agent providers", providerID)
 	}
 	fmt.Printf("🤖 Provider : %s / %s\n", providerID, model)
 	fmt.Printf("📋 Task     : %s\n", task.Name)
 	fmt.Println("⚡ Running in YOLO mode...")
-	client := ai.NewClient(providerID, model, apiKey)
-	taskMD := asana.FormatTaskMarkdown(task)
-	result, err := client.ExecuteTask(taskMD, func(msg string) { fmt.Println(" →", msg) })
+	out, err := runner.Run(context.Background(), task, runner.Options{
+		ProviderID: providerID,
+		Model:      model,
+		APIKey:     apiKey,
+		OutputDir:  outDir,
+		Layout:     cfg.OutputLayout,
+		Prompt:     prompt,
+		Verify:     cfg.Verify,
+		Retention:  cfg.Retention,
+		Progress:   func(msg string) { fmt.Println(" →", msg) },
+	})
 	if err != nil {
-		return fmt.Errorf("AI execution: %w", err)
+		if out == nil {
+			return fmt.Errorf("AI execution: %w", err)
+		}
+		return err
 	}
-	outPath, err := output.Write(result, task, outDir)
-	if err != nil {
-		return fmt.Errorf("saving output: %w", err)
+	fmt.Printf("\n✅ Saved to: %s\n\n", out.OutPath)
+	return printOutcome(out)
+}
+
+// printOutcome prints the result preview and verification status, returning
+// the verification error when it failed.
+func printOutcome(out *runner.Outcome) error {
+	fmt.Println(output.Preview(out.Result))
+	if m, err := output.LoadManifest(out.OutPath); err == nil {
+		for _, r := range m.Rejected {
+			fmt.Printf("⚠️  Rejected %s — %s\n", r.Path, r.Reason)
+		}
+	}
+	if n := len(out.Attempts); n > 0 {
+		fmt.Printf("🔧 %d fix attempt(s); earlier versions in %s\n", n-1, filepath.Join(out.OutPath, output.MetaDir, runner.IterationsDir))
+	}
+	switch out.Status() {
+	case verify.StatusVerified:
+		fmt.Println("✅ Verified")
+	case verify.StatusUnverified:
+		fmt.Println("➖ Not verified — no applicable hooks ran")
+	case verify.StatusFailed:
+		fmt.Printf("❌ Verification failed — see %s\n", filepath.Join(out.OutPath, output.MetaDir, "verify.log"))
+		return out.Verification.Err()
 	}
-	fmt.Printf("\n✅ Saved to: %s\n\n", outPath)
-	fmt.Println(output.Preview(result))
 	return nil
 }
 
@@ -97,17 +184,59 @@ func printTaskTable(tasks []asana.Task) {
 	fmt.Println()
 }
 
+// setupCassette installs a recording or replaying HTTP transport for all AI
+// traffic. Flags win over the TASK_AGENT_RECORD / TASK_AGENT_REPLAY env vars.
+func setupCassette(recordDir, replayDir string) error {
+	if recordDir == "" {
+		recordDir = os.Getenv("TASK_AGENT_RECORD")
+	}
+	if replayDir == "" {
+		replayDir = os.Getenv("TASK_AGENT_REPLAY")
+	}
+	switch {
+	case recordDir != "" && replayDir != "":
+		return fmt.Errorf("--record and --replay are mutually exclusive")
+	case recordDir != "":
+		ai.SetTransport(cassette.New(cassette.Record, recordDir))
+	case replayDir != "":
+		if _, err := os.Stat(replayDir); err != nil {
+			return fmt.Errorf("replay cassettes: %w", err)
+		}
+		ai.SetTransport(cassette.New(cassette.Replay, replayDir))
+	}
+	return nil
+}
+
 func newRoot() *cobra.Command {
+	var recordDir, replayDir, configFile string
 	root := &cobra.Command{
 		Use:     "task-agent",
 		Short:   "YOLO AI Task Executor for Asana",
 		Version: version,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			config.SetConfigFile(configFile)
+			// Fail early on a mistyped --profile rather than silently running
+			// with the wrong workspace or keys.
+			managing := cmd.Parent() != nil && cmd.Parent().Name() == "profiles"
+			cfg, err := config.LoadProfile(profileFlag)
+			if errors.Is(err, config.ErrUnknownProfile) && !managing {
+				return err
+			}
+			// Later loads see the rewritten file, so report an upgrade now.
+			if cfg != nil && cfg.Upgraded() != "" {
+				fmt.Fprintf(os.Stderr, "⚠️  %s\n", cfg.Upgraded())
+			}
+			return setupCassette(recordDir, replayDir)
+		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			cfg := loadConfig()
-			return tui.Run(cfg, newAsanaClient(cfg))
+			return runTUI(loadConfig())
 		},
 	}
-	root.AddCommand(newTUICmd(), newRunCmd(), newListCmd(), newSearchCmd(), newConfigCmd(), newProvidersCmd())
+	root.PersistentFlags().StringVar(&recordDir, "record", "", "Record AI HTTP traffic to cassette files in this directory")
+	root.PersistentFlags().StringVar(&configFile, "config", "", "Config file to use (default: $"+config.HomeEnv+"/config.json, ~/.task-agent/config.json if it exists, else $XDG_CONFIG_HOME/task-agent/config.json)")
+	root.PersistentFlags().StringVar(&profileFlag, "profile", "", "Config profile to use (default: $"+config.ProfileEnv+", then the default profile)")
+	root.PersistentFlags().StringVar(&replayDir, "replay", "", "Replay AI HTTP traffic from cassette files instead of calling providers")
+	root.AddCommand(newTUICmd(), newRunCmd(), newChatCmd(), newHistoryCmd(), newExportCmd(), newManifestCmd(), newOutputsCmd(), newCompareCmd(), newEvalCmd(), newListCmd(), newSearchCmd(), newConfigCmd(), newProvidersCmd())
 	return root
 }
 
@@ -116,14 +245,16 @@ func newTUICmd() *cobra.Command {
 		Use:   "tui",
 		Short: "Launch the interactive Bubble Tea TUI (default)",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			cfg := loadConfig()
-			return tui.Run(cfg, newAsanaClient(cfg))
+			return runTUI(loadConfig())
 		},
 	}
 }
 
 func newRunCmd() *cobra.Command {
 	var providerID, model, outDir string
+	var verifyOutput, edit, dryRun bool
+	var fixAttempts int
+	var dumpPath string
 	cmd := &cobra.Command{
 		Use:   "run <task-gid>",
 		Short: "Execute a specific task by GID (no TUI)",
@@ -134,29 +265,537 @@ func newRunCmd() *cobra.Command {
 			if client == nil {
 				return fmt.Errorf("asana-cli required")
 			}
-			if providerID == "" {
-				providerID = cfg.Provider
+			if err := applyFlags(cmd, cfg, map[string]string{
+				"provider": "provider",
+				"model":    "model",
+				"output":   "output_dir",
+				"verify":   "verify.enabled",
+				"fix":      "verify.fix_attempts",
+			}); err != nil {
+				return err
+			}
+			if fixAttempts > 0 && !cfg.Verify.Enabled {
+				if err := cfg.SetFlag("verify.enabled", "true", "--fix"); err != nil {
+					return err
+				}
+			}
+			providerID, model, outDir = cfg.Provider, cfg.Model, cfg.OutputDir
+			fmt.Printf("🔍 Fetching task %s...\n", args[0])
+			task, err := client.ViewTask(args[0])
+			if err != nil {
+				return err
+			}
+			var prompt string
+			if edit {
+				fmt.Printf("📝 Opening prompt in %s...\n", editor.Name())
+				if prompt, err = editor.Edit("task-agent-prompt-*.md", runner.Prompt(task)); err != nil {
+					return err
+				}
+			}
+			if dryRun || dumpPath != "" {
+				apiKey, err := config.LookupAPIKey(cfg, providerID)
+				if err != nil {
+					return err
+				}
+				d, err := runner.Preview(task, runner.Options{
+					ProviderID: providerID,
+					Model:      model,
+					APIKey:     apiKey,
+					Prompt:     prompt,
+				})
+				if err != nil {
+					return err
+				}
+				if dumpPath != "" {
+					if err := os.WriteFile(dumpPath, d.Body, 0600); err != nil {
+						return fmt.Errorf("dump request: %w", err)
+					}
+					fmt.Printf("💾 Request body saved to %s\n", dumpPath)
+				}
+				if dryRun {
+					fmt.Printf("\n%s\n🧪 Dry run — nothing was sent\n", d.Format())
+					return nil
+				}
+			}
+			return doExecute(task, providerID, model, outDir, prompt, cfg)
+		},
+	}
+	cmd.Flags().StringVarP(&providerID, "provider", "p", "", "AI provider")
+	cmd.Flags().StringVarP(&model, "model", "m", "", "Model name")
+	cmd.Flags().StringVarP(&outDir, "output", "o", "", "Output directory")
+	cmd.Flags().BoolVar(&verifyOutput, "verify", false, "Build/test the output with verification hooks (default: verify.enabled from config)")
+	cmd.Flags().BoolVar(&edit, "edit", false, "Edit the rendered prompt in $EDITOR before sending it")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Print the full request (prompt, settings, masked headers, token and cost estimate) without sending it")
+	cmd.Flags().StringVar(&dumpPath, "dump-request", "", "Save the exact JSON request body to this file")
+	cmd.Flags().IntVar(&fixAttempts, "fix", 0, "Send verification failures back to the model up to N times (implies --verify)")
+	return cmd
+}
+
+func newChatCmd() *cobra.Command {
+	var message, outDir string
+	cmd := &cobra.Command{
+		Use:   "chat <run-id>",
+		Short: "Continue a past run's conversation and write a new revision of its output",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg := loadConfig()
+			if outDir == "" {
+				outDir = cfg.OutputDir
+			}
+			dir, err := history.Resolve(outDir, args[0])
+			if err != nil {
+				return err
+			}
+			conv, err := history.Load(dir)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("💬 %s — %s / %s (revision %d)\n", conv.TaskName, conv.Provider, conv.Model, conv.Revision)
+			apiKey, err := config.LookupAPIKey(cfg, conv.Provider)
+			if err != nil {
+				return err
+			}
+			send := func(msg string) error {
+				out, err := runner.Chat(context.Background(), dir, msg, runner.ChatOptions{
+					APIKey:   apiKey,
+					Verify:   cfg.Verify,
+					Progress: func(msg string) { fmt.Println(" →", msg) },
+				})
+				if err != nil {
+					return err
+				}
+				fmt.Printf("\n✅ Revision %d written to: %s\n\n", out.Revision, out.OutPath)
+				return printOutcome(out)
+			}
+			if message != "" {
+				return send(message)
+			}
+
+			fmt.Println("   Type a follow-up and press Enter; an empty line or \"exit\" quits.")
+			sc := bufio.NewScanner(os.Stdin)
+			for {
+				fmt.Print("\nyou> ")
+				if !sc.Scan() {
+					return sc.Err()
+				}
+				line := strings.TrimSpace(sc.Text())
+				if line == "" || line == "exit" || line == "quit" {
+					return nil
+				}
+				if err := send(line); err != nil {
+					fmt.Fprintln(os.Stderr, "❌", err)
+				}
+			}
+		},
+	}
+	cmd.Flags().StringVar(&message, "message", "", "Send one follow-up and exit instead of chatting interactively")
+	cmd.Flags().StringVarP(&outDir, "output", "o", "", "Output directory to look up run IDs in")
+	return cmd
+}
+
+func newHistoryCmd() *cobra.Command {
+	var outDir string
+	cmd := &cobra.Command{
+		Use:   "history",
+		Short: "List past runs that can be resumed with chat",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg := loadConfig()
+			if outDir == "" {
+				outDir = cfg.OutputDir
+			}
+			runs, err := history.List(outDir)
+			if err != nil {
+				return err
+			}
+			if len(runs) == 0 {
+				fmt.Println("No runs found.")
+				return nil
+			}
+			fmt.Printf("\n%-44s %-4s %-34s %s\n", "RUN ID", "REV", "MODEL", "UPDATED")
+			fmt.Println(strings.Repeat("─", 100))
+			for _, r := range runs {
+				c := r.Conversation
+				id := r.ID
+				if len(id) > 44 {
+					id = id[:43] + "…"
+				}
+				fmt.Printf("%-44s %-4d %-34s %s\n", id, c.Revision, c.Provider+"/"+c.Model, c.Updated.Format("2006-01-02 15:04"))
+			}
+			fmt.Println()
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&outDir, "output", "o", "", "Output directory")
+	return cmd
+}
+
+func newExportCmd() *cobra.Command {
+	var format, dest, outDir string
+	cmd := &cobra.Command{
+		Use:   "export <run-dir|run-id>",
+		Short: "Package an output folder as a zip/tar.gz archive or a self-contained HTML report",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dir := args[0]
+			if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+				if outDir == "" {
+					outDir = loadConfig().OutputDir
+				}
+				if dir, err = history.Resolve(outDir, args[0]); err != nil {
+					return err
+				}
+			}
+			path, err := export.Export(dir, format, dest)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("📦 Exported %s → %s\n", filepath.Base(dir), path)
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&format, "format", "f", export.Zip, "Export format: "+strings.Join(export.Formats, ", "))
+	cmd.Flags().StringVar(&dest, "to", "", "Destination file (default: next to the folder, e.g. <folder>.zip)")
+	cmd.Flags().StringVarP(&outDir, "output", "o", "", "Output directory to look up run IDs in")
+	return cmd
+}
+
+func newOutputsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "outputs",
+		Short: "Manage task-agent output folders",
+	}
+	cmd.AddCommand(newOutputsPruneCmd(), newOutputsDiffCmd())
+	return cmd
+}
+
+func newOutputsDiffCmd() *cobra.Command {
+	var outDir string
+	cmd := &cobra.Command{
+		Use:   "diff <task-gid> [revA] [revB]",
+		Short: "Diff two revisions of a task's output (task layout)",
+		Long: `Show a unified diff between two revisions of a task written with
+"output_layout": "task". revB defaults to the latest revision and revA to the
+one before it. Revisions can be given as 3, rev-3 or latest.`,
+		Args: cobra.RangeArgs(1, 3),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if outDir == "" {
+				outDir = loadConfig().OutputDir
+			}
+			taskDir := output.TaskDir(outDir, args[0])
+			ix, err := output.LoadIndex(taskDir)
+			if err != nil {
+				return fmt.Errorf("no revisions of task %s in %s — is \"output_layout\" set to \"task\"?", args[0], outDir)
 			}
-			if model == "" {
-				model = cfg.Model
+			refs := append(args[1:], "", "")
+			b, err := ix.Resolve(refs[1])
+			if err != nil {
+				return err
 			}
+			a := ix.Previous(b)
+			if refs[0] != "" {
+				if a, err = ix.Resolve(refs[0]); err != nil {
+					return err
+				}
+			}
+			if a == 0 {
+				return fmt.Errorf("task %s has only one revision", args[0])
+			}
+			nameA, nameB := output.RevisionName(a), output.RevisionName(b)
+			diff, changed, err := output.Diff(filepath.Join(taskDir, nameA), filepath.Join(taskDir, nameB), nameA, nameB)
+			if err != nil {
+				return err
+			}
+			fmt.Print(diff)
+			fmt.Printf("📊 %s → %s: %d file(s) changed\n", nameA, nameB, changed)
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&outDir, "output", "o", "", "Output directory")
+	return cmd
+}
+
+func newOutputsPruneCmd() *cobra.Command {
+	var outDir string
+	var dryRun bool
+	var p output.Policy
+	cmd := &cobra.Command{
+		Use:   "prune",
+		Short: "Delete old output folders by retention policy",
+		Long: `Delete output folders written by task-agent (those with an agent-manifest.json)
+that match any of the given rules. Without rule flags the "retention" policy from
+the config is used. Other files and folders in the output directory are never touched.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg := loadConfig()
 			if outDir == "" {
 				outDir = cfg.OutputDir
 			}
+			flags := cmd.Flags()
+			if !flags.Changed("keep") && !flags.Changed("older-than") && !flags.Changed("max-size") && !flags.Changed("failed-only") {
+				p = cfg.Retention
+			}
+			if !p.Enabled() {
+				return fmt.Errorf("no retention rule — pass --keep, --older-than, --max-size or --failed-only, or set \"retention\" in %s", config.ConfigPath())
+			}
+			pruned, err := output.Prune(outDir, p, dryRun)
+			verb := "Deleted"
+			if dryRun {
+				verb = "Would delete"
+			}
+			var freed int64
+			for _, f := range pruned {
+				freed += f.Size
+				fmt.Printf("🗑  %-52s %9s  %s\n", filepath.Base(f.Dir), output.FormatSize(f.Size), f.Reason)
+			}
+			if err != nil {
+				return err
+			}
+			fmt.Printf("🧹 %s %d folder(s), %s (%s)\n", verb, len(pruned), output.FormatSize(freed), p.Describe())
+			return nil
+		},
+	}
+	cmd.Flags().IntVar(&p.KeepPerTask, "keep", 0, "Keep the newest N folders of each task")
+	cmd.Flags().IntVar(&p.OlderThanDays, "older-than", 0, "Delete folders older than this many days")
+	cmd.Flags().IntVar(&p.MaxTotalMB, "max-size", 0, "Delete the oldest folders until the total is under this many MB")
+	cmd.Flags().BoolVar(&p.FailedOnly, "failed-only", false, "Only delete runs whose verification failed")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "List what would be deleted without deleting")
+	cmd.Flags().StringVarP(&outDir, "output", "o", "", "Output directory")
+	return cmd
+}
+
+func newManifestCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "manifest",
+		Short: "Inspect agent-manifest.json in output folders",
+	}
+	var asJSON bool
+	validate := &cobra.Command{
+		Use:   "validate <dir>",
+		Short: "Check an output folder's files against the sizes and SHA-256 hashes in its manifest",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			m, err := output.LoadManifest(args[0])
+			if err != nil {
+				return err
+			}
+			problems := m.Validate(args[0])
+			if asJSON {
+				if problems == nil {
+					problems = []string{}
+				}
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				if err := enc.Encode(map[string]any{"dir": args[0], "version": m.Version, "files": len(m.Files), "valid": len(problems) == 0, "problems": problems}); err != nil {
+					return err
+				}
+			} else if len(problems) == 0 {
+				fmt.Printf("✅ %s — %d file(s) match the manifest (v%d)\n", args[0], len(m.Files), m.Version)
+			} else {
+				for _, p := range problems {
+					fmt.Println("❌", p)
+				}
+			}
+			if len(problems) > 0 {
+				return fmt.Errorf("%d problem(s) in %s", len(problems), args[0])
+			}
+			return nil
+		},
+	}
+	validate.Flags().BoolVar(&asJSON, "json", false, "Output the result as JSON")
+	cmd.AddCommand(validate)
+	return cmd
+}
+
+func newCompareCmd() *cobra.Command {
+	var models, outDir, keep string
+	cmd := &cobra.Command{
+		Use:   "compare <task-gid>",
+		Short: "Execute a task on several models in parallel and compare results",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg := loadConfig()
+			client := newAsanaClient(cfg)
+			if client == nil {
+				return fmt.Errorf("asana-cli required")
+			}
+			if err := applyFlags(cmd, cfg, map[string]string{
+				"models": "compare_models",
+				"output": "output_dir",
+			}); err != nil {
+				return err
+			}
+			refs, err := ai.ParseModelRefs(strings.Join(cfg.CompareModels, ","))
+			if err != nil {
+				return err
+			}
+			if len(refs) < 2 {
+				return fmt.Errorf("compare needs at least two models — pass --models provider/model,provider/model")
+			}
+			outDir = cfg.OutputDir
 			fmt.Printf("🔍 Fetching task %s...\n", args[0])
 			task, err := client.ViewTask(args[0])
 			if err != nil {
 				return err
 			}
-			return doExecute(task, providerID, model, outDir, cfg)
+			fmt.Printf("⚖️  Comparing %d models on: %s\n", len(refs), task.Name)
+			var mu sync.Mutex
+			rep, err := compare.Run(task, compare.Options{
+				Models:    refs,
+				OutputDir: outDir,
+				APIKey:    func(id string) (string, error) { return config.LookupAPIKey(cfg, id) },
+				Progress: func(ref ai.ModelRef, msg string) {
+					mu.Lock()
+					defer mu.Unlock()
+					fmt.Printf(" → [%s] %s\n", ref, msg)
+				},
+			})
+			if err != nil {
+				return err
+			}
+			printComparison(rep)
+			if keep == "" {
+				fmt.Printf("📁 Results: %s\n", rep.Dir)
+				return nil
+			}
+			for i, e := range rep.Entries {
+				if e.Ref().String() == keep {
+					dest, err := rep.Keep(i, outDir)
+					if err != nil {
+						return err
+					}
+					fmt.Printf("🏆 Kept %s → %s\n", keep, dest)
+					return nil
+				}
+			}
+			return fmt.Errorf("--keep %s is not one of the compared models", keep)
 		},
 	}
-	cmd.Flags().StringVarP(&providerID, "provider", "p", "", "AI provider")
-	cmd.Flags().StringVarP(&model, "model", "m", "", "Model name")
+	cmd.Flags().StringVar(&models, "models", "", "Comma-separated provider/model list (default: compare_models from config)")
+	cmd.Flags().StringVarP(&outDir, "output", "o", "", "Output directory")
+	cmd.Flags().StringVar(&keep, "keep", "", "Promote this provider/model's result and discard the others")
+	return cmd
+}
+
+func printComparison(rep *compare.Report) {
+	fmt.Printf("\n%-36s %5s %9s %15s %9s %9s %6s\n", "MODEL", "FILES", "SIZE", "TOKENS IN/OUT", "COST", "LATENCY", "PARSED")
+	fmt.Println(strings.Repeat("─", 96))
+	for _, e := range rep.Entries {
+		ref := e.Ref().String()
+		if len(ref) > 36 {
+			ref = ref[:35] + "…"
+		}
+		if e.Error != "" {
+			fmt.Printf("%-36s ❌ %s\n", ref, e.Error)
+			continue
+		}
+		parsed := "✅"
+		if !e.Parsed {
+			parsed = "❌"
+		}
+		fmt.Printf("%-36s %5d %9s %15s %9s %9s %6s\n", ref, e.Files, compare.HumanBytes(e.Bytes),
+			fmt.Sprintf("%d/%d", e.Usage.InputTokens, e.Usage.OutputTokens), e.CostString(), e.LatencyString(), parsed)
+	}
+	fmt.Println()
+}
+
+func newEvalCmd() *cobra.Command {
+	var models, outDir, baseline string
+	var failUnder float64
+	cmd := &cobra.Command{
+		Use:   "eval <suite.json>",
+		Short: "Benchmark models and prompts against a suite of tasks with assertions",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg := loadConfig()
+			suite, err := eval.LoadSuite(args[0])
+			if err != nil {
+				return err
+			}
+			list := models
+			if list == "" {
+				list = strings.Join(suite.Models, ",")
+			}
+			if list == "" {
+				list = cfg.Provider + "/" + cfg.Model
+			}
+			refs, err := ai.ParseModelRefs(list)
+			if err != nil {
+				return err
+			}
+			if outDir == "" {
+				outDir = cfg.OutputDir
+			}
+			var client *asana.Client
+			for _, c := range suite.Cases {
+				if c.GID != "" {
+					client = newAsanaClient(cfg)
+					break
+				}
+			}
+			fmt.Printf("🧪 Suite %s: %d case(s) × %d model(s)\n", suite.Name, len(suite.Cases), len(refs))
+			rep, err := eval.Run(suite, eval.Options{
+				Models:    refs,
+				OutputDir: outDir,
+				APIKey:    func(id string) (string, error) { return config.LookupAPIKey(cfg, id) },
+				Asana:     client,
+				Progress:  func(msg string) { fmt.Println(" →", msg) },
+			})
+			if err != nil {
+				return err
+			}
+			fmt.Printf("\n%-40s %7s %11s %6s\n", "MODEL", "SCORE", "ASSERTIONS", "ERRORS")
+			fmt.Println(strings.Repeat("─", 68))
+			for _, s := range rep.Summary {
+				fmt.Printf("%-40s %6.1f%% %11s %6d\n", s.Model, s.Score*100, fmt.Sprintf("%d/%d", s.Passed, s.Total), s.Errors)
+			}
+			fmt.Printf("\n📊 Report: %s\n", rep.Dir)
+
+			if baseline != "" {
+				old, err := eval.LoadReport(baseline)
+				if err != nil {
+					return fmt.Errorf("loading baseline: %w", err)
+				}
+				printEvalDiff(eval.Diff(old, rep))
+			}
+			for _, s := range rep.Summary {
+				if s.Score < failUnder {
+					return fmt.Errorf("%s scored %.1f%%, below --fail-under %.1f%%", s.Model, s.Score*100, failUnder*100)
+				}
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&models, "models", "", "Comma-separated provider/model list (default: suite models, then config)")
 	cmd.Flags().StringVarP(&outDir, "output", "o", "", "Output directory")
+	cmd.Flags().StringVar(&baseline, "baseline", "", "Previous report.json (or run folder) to diff scores against")
+	cmd.Flags().Float64Var(&failUnder, "fail-under", 0, "Exit non-zero if any model's score (0–1) is below this")
 	return cmd
 }
 
+func printEvalDiff(deltas []eval.Delta) {
+	fmt.Println("\nChanges vs baseline:")
+	changed := 0
+	for _, d := range deltas {
+		switch {
+		case d.Before == nil:
+			fmt.Printf("  + %s / %s: %.1f%% (new)\n", d.Case, d.Model, *d.After*100)
+		case d.After == nil:
+			fmt.Printf("  - %s / %s: was %.1f%% (removed)\n", d.Case, d.Model, *d.Before*100)
+		case *d.Before != *d.After:
+			arrow := "▲"
+			if *d.After < *d.Before {
+				arrow = "▼"
+			}
+			fmt.Printf("  %s %s / %s: %.1f%% → %.1f%%\n", arrow, d.Case, d.Model, *d.Before*100, *d.After*100)
+		default:
+			continue
+		}
+		changed++
+	}
+	if changed == 0 {
+		fmt.Println("  (no score changes)")
+	}
+}
+
 func newListCmd() *cobra.Command {
 	var project string
 	var asJSON bool
@@ -224,7 +863,7 @@ func newSearchCmd() *cobra.Command {
 }
 
 func newConfigCmd() *cobra.Command {
-	return &cobra.Command{
+	cmd := &cobra.Command{
 		Use:   "config",
 		Short: "Interactive configuration setup",
 		RunE: func(cmd *cobra.Command, args []string) error {
@@ -252,8 +891,8 @@ func newConfigCmd() *cobra.Command {
 			cfg.ProjectGID = prompt("Default Project GID", cfg.ProjectGID, false)
 			cfg.OutputDir = prompt("Output directory", cfg.OutputDir, false)
 			fmt.Println("\nAPI Keys:")
-			for _, prov := range ai.Providers {
-				if prov.EnvKey == "" {
+			for _, prov := range ai.Providers() {
+				if !prov.RequiresKey() {
 					continue
 				}
 				key := prompt("  "+prov.Name, cfg.APIKeys[prov.ID], true)
@@ -262,7 +901,8 @@ func newConfigCmd() *cobra.Command {
 				}
 			}
 			fmt.Println("\nAI Provider:")
-			for i, p := range ai.Providers {
+			providers := ai.Providers()
+			for i, p := range providers {
 				marker := " "
 				if p.ID == cfg.Provider {
 					marker = "▶"
@@ -270,10 +910,10 @@ func newConfigCmd() *cobra.Command {
 				fmt.Printf("  %d. %s %s\n", i+1, marker, p.Name)
 			}
 			var choice int
-			fmt.Printf("Choose [1-%d]: ", len(ai.Providers))
+			fmt.Printf("Choose [1-%d]: ", len(providers))
 			fmt.Scan(&choice)
-			if choice >= 1 && choice <= len(ai.Providers) {
-				prov := ai.Providers[choice-1]
+			if choice >= 1 && choice <= len(providers) {
+				prov := providers[choice-1]
 				cfg.Provider = prov.ID
 				fmt.Printf("\nModels for %s:\n", prov.Name)
 				for i, m := range prov.Models {
@@ -294,24 +934,32 @@ func newConfigCmd() *cobra.Command {
 				return fmt.Errorf("saving config: %w", err)
 			}
 			fmt.Printf("\n✅ Config saved to %s\n", config.ConfigPath())
+			if cfg.Active != "" {
+				fmt.Printf("   Profile : %s\n", cfg.Active)
+			}
 			fmt.Printf("   Provider: %s / %s\n", cfg.Provider, cfg.Model)
 			return nil
 		},
 	}
+	cmd.AddCommand(newConfigProfilesCmd(), newConfigShowCmd(), newConfigGetCmd(), newConfigSetCmd(), newConfigUnsetCmd(), newConfigListCmd(), newConfigValidateCmd(), newConfigMigrateKeysCmd(), newConfigEnvCmd())
+	return cmd
 }
 
 func newProvidersCmd() *cobra.Command {
-	return &cobra.Command{
+	var live bool
+	cmd := &cobra.Command{
 		Use:   "providers",
 		Short: "List available AI providers and models",
 		RunE: func(cmd *cobra.Command, args []string) error {
 			cfg := loadConfig()
-			for _, prov := range ai.Providers {
-				apiKey := config.GetAPIKey(cfg, prov.ID)
+			for _, prov := range ai.Providers() {
+				apiKey, keyErr := config.LookupAPIKey(cfg, prov.ID)
 				status := "❌ no key"
 				switch {
-				case prov.ID == "ollama":
+				case !prov.RequiresKey():
 					status = "🔧 local (no key needed)"
+				case keyErr != nil:
+					status = "⚠️  " + keyErr.Error()
 				case apiKey != "":
 					status = "✅ key found"
 				}
@@ -327,9 +975,24 @@ func newProvidersCmd() *cobra.Command {
 					}
 					fmt.Printf("     %s %s\n", marker, m)
 				}
+				if live && keyErr == nil && (apiKey != "" || !prov.RequiresKey()) {
+					ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+					models, err := ai.NewClient(prov.ID, "", apiKey).ListModels(ctx)
+					cancel()
+					if err != nil {
+						fmt.Printf("   ⚠️  could not list models: %v\n", err)
+						continue
+					}
+					fmt.Printf("   Available to your key (%d):\n", len(models))
+					for _, m := range models {
+						fmt.Printf("       %s\n", m)
+					}
+				}
 			}
 			fmt.Println()
 			return nil
 		},
 	}
+	cmd.Flags().BoolVar(&live, "live", false, "Also ask each provider with a key which models it offers")
+	return cmd
 }
\ No newline at end of file
@@ -0,0 +1,1285 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zalando/go-keyring"
+
+	"github.com/thecoolrobot/task-agent/internal/ai"
+	"github.com/thecoolrobot/task-agent/internal/asana"
+	"github.com/thecoolrobot/task-agent/internal/config"
+	"github.com/thecoolrobot/task-agent/internal/history"
+	"github.com/thecoolrobot/task-agent/internal/output"
+	"github.com/thecoolrobot/task-agent/internal/testharness"
+	"github.com/thecoolrobot/task-agent/internal/verify"
+)
+
+type env struct {
+	asana  *testharness.FakeAsana
+	llm    *testharness.LLMServer
+	outDir string
+}
+
+// setup isolates $HOME, writes a config pointing at the fakes and returns
+// them for scripting.
+func setup(t *testing.T, fixtures testharness.Fixtures) *env {
+	t.Helper()
+	home := testharness.Home(t)
+	t.Setenv("ANTHROPIC_API_KEY", "")
+	t.Setenv(config.ProfileEnv, "")
+	e := &env{
+		asana:  testharness.NewFakeAsana(t, fixtures),
+		llm:    testharness.NewLLMServer(t),
+		outDir: filepath.Join(home, "out"),
+	}
+	// Run from inside the temp home so no project config above the
+	// checkout is picked up. The fake asana-cli is built first, from here.
+	chdir(t, home)
+	cfg := config.Defaults()
+	cfg.AsanaCLIPath = e.asana.Path
+	cfg.OutputDir = e.outDir
+	cfg.WorkspaceGID = "ws-1"
+	cfg.ProjectGID = "proj-1"
+	config.SetAPIKey(cfg, "anthropic", "sk-test")
+	if err := config.Save(cfg); err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+// chdir changes the working directory for the rest of the test.
+func chdir(t *testing.T, dir string) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+// execute runs the CLI with args and returns captured stdout.
+func execute(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	orig := os.Stdout
+	os.Stdout = w
+	done := make(chan string)
+	go func() {
+		b, _ := io.ReadAll(r)
+		done <- string(b)
+	}()
+
+	root := newRoot()
+	root.SetArgs(args)
+	root.SilenceUsage = true
+	root.SilenceErrors = true
+	runErr := root.Execute()
+
+	w.Close()
+	os.Stdout = orig
+	return <-done, runErr
+}
+
+func TestRunWritesOutput(t *testing.T) {
+	e := setup(t, testharness.Fixtures{
+		"view 42": testharness.OK(asana.Task{GID: "42", Name: "Write the docs", Notes: "Explain everything"}),
+	})
+	e.llm.ReplyResult(ai.TaskResult{
+		OutputType: "code_folder",
+		Summary:    "Wrote docs",
+		Files: []ai.OutputFile{
+			{Path: "docs/guide.md", Content: "# Guide\n", Description: "User guide"},
+			{Path: "README.md", Content: "# Readme\n", Description: "Readme"},
+		},
+	})
+
+	out, err := execute(t, "run", "42")
+	if err != nil {
+		t.Fatalf("run: %v\n%s", err, out)
+	}
+	if !strings.Contains(out, "Saved to:") {
+		t.Errorf("missing save line in output:\n%s", out)
+	}
+
+	dirs, _ := filepath.Glob(filepath.Join(e.outDir, "*_Write_the_docs"))
+	if len(dirs) != 1 {
+		t.Fatalf("want one output folder, got %v", dirs)
+	}
+	for _, f := range []string{"docs/guide.md", "README.md", "AGENT_MANIFEST.md"} {
+		if _, err := os.Stat(filepath.Join(dirs[0], f)); err != nil {
+			t.Errorf("missing %s: %v", f, err)
+		}
+	}
+
+	reqs := e.llm.Requests()
+	if len(reqs) != 1 || reqs[0].Path != "/v1/messages" {
+		t.Fatalf("want one Anthropic request, got %+v", reqs)
+	}
+	if got := reqs[0].Headers.Get("X-Api-Key"); got != "sk-test" {
+		t.Errorf("x-api-key = %q", got)
+	}
+	msgs, _ := reqs[0].Body["messages"].([]any)
+	if len(msgs) != 1 || !strings.Contains(msgs[0].(map[string]any)["content"].(string), "Explain everything") {
+		t.Errorf("task description not sent: %+v", reqs[0].Body)
+	}
+}
+
+func TestRunVerifyFailure(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hooks use sh")
+	}
+	e := setup(t, testharness.Fixtures{
+		"view 42": testharness.OK(asana.Task{GID: "42", Name: "Broken build"}),
+	})
+	cfg, _ := config.Load()
+	cfg.Verify.Hooks = []verify.Hook{{Name: "check", Command: "test -f missing.txt"}}
+	if err := config.Save(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := execute(t, "run", "42", "--verify")
+	if !errors.Is(err, verify.ErrFailed) {
+		t.Fatalf("want verification error, got %v\n%s", err, out)
+	}
+	dirs, _ := filepath.Glob(filepath.Join(e.outDir, "*_Broken_build"))
+	if len(dirs) != 1 {
+		t.Fatalf("want one output folder, got %v", dirs)
+	}
+	manifest, _ := os.ReadFile(filepath.Join(dirs[0], "AGENT_MANIFEST.md"))
+	if !strings.Contains(string(manifest), "## Verification: failed") {
+		t.Errorf("manifest lacks verification section:\n%s", manifest)
+	}
+	if _, err := os.Stat(filepath.Join(dirs[0], ".agent", "verify.log")); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRunFixLoop(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hooks use sh")
+	}
+	e := setup(t, testharness.Fixtures{
+		"view 42": testharness.OK(asana.Task{GID: "42", Name: "Needs fixing"}),
+	})
+	cfg, _ := config.Load()
+	// Each check leaves a build artifact named after its process.
+	cfg.Verify.Hooks = []verify.Hook{{Name: "check", Command: "touch stamp-$$; echo 'missing fixed.txt'; test -f fixed.txt"}}
+	if err := config.Save(cfg); err != nil {
+		t.Fatal(err)
+	}
+	e.llm.ReplyResult(testharness.DefaultResult)
+	e.llm.ReplyResult(ai.TaskResult{
+		OutputType: "code_folder",
+		Summary:    "Still broken",
+		Files:      []ai.OutputFile{{Path: "README.md", Content: "# Again\n"}},
+	})
+	e.llm.ReplyResult(ai.TaskResult{
+		OutputType: "code_folder",
+		Summary:    "Fixed",
+		Files:      []ai.OutputFile{{Path: "fixed.txt", Content: "ok\n"}},
+	})
+
+	if out, err := execute(t, "run", "42", "--fix", "2"); err != nil {
+		t.Fatalf("run: %v\n%s", err, out)
+	}
+	reqs := e.llm.Requests()
+	if len(reqs) != 3 {
+		t.Fatalf("want 3 LLM requests, got %d", len(reqs))
+	}
+	msgs := reqs[2].Body["messages"].([]any)
+	last := msgs[len(msgs)-1].(map[string]any)["content"].(string)
+	if len(msgs) != 3 || !strings.Contains(last, "missing fixed.txt") || !strings.Contains(last, "# Again") {
+		t.Errorf("fix turn lacks failure output or files:\n%s", last)
+	}
+
+	dirs, _ := filepath.Glob(filepath.Join(e.outDir, "*_Needs_fixing"))
+	if len(dirs) != 1 {
+		t.Fatalf("want one output folder, got %v", dirs)
+	}
+	dir := dirs[0]
+	for _, f := range []string{"fixed.txt", ".agent/attempts.json", ".agent/iterations/1/README.md", ".agent/iterations/1/verify.log", ".agent/iterations/1/response.txt"} {
+		if _, err := os.Stat(filepath.Join(dir, f)); err != nil {
+			t.Errorf("missing %s", f)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "README.md")); err == nil {
+		t.Error("stale README.md from the first attempt left in place")
+	}
+	manifest, _ := os.ReadFile(filepath.Join(dir, "AGENT_MANIFEST.md"))
+	if !strings.Contains(string(manifest), "## Verification: verified") || !strings.Contains(string(manifest), "## Fix Loop") {
+		t.Errorf("manifest:\n%s", manifest)
+	}
+	// Only the last check's artifact is left; earlier ones were cleaned up
+	// with the attempt they belonged to.
+	if stamps, _ := filepath.Glob(filepath.Join(dir, "stamp-*")); len(stamps) != 1 {
+		t.Errorf("artifacts left: %v", stamps)
+	}
+	if stamps, _ := filepath.Glob(filepath.Join(dir, ".agent", "iterations", "*", "stamp-*")); len(stamps) != 0 {
+		t.Errorf("artifacts archived: %v", stamps)
+	}
+	// The saved conversation keeps every turn, not just the last exchange.
+	conv, err := history.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(conv.Messages) != 6 || !strings.Contains(conv.Messages[3].Content, "Still broken") || !strings.Contains(conv.Messages[5].Content, "Fixed") {
+		t.Errorf("conversation has %d messages: %+v", len(conv.Messages), conv.Messages)
+	}
+}
+
+func TestChatRevisesRun(t *testing.T) {
+	e := setup(t, testharness.Fixtures{
+		"view 42": testharness.OK(asana.Task{GID: "42", Name: "Draft post"}),
+	})
+	if _, err := execute(t, "run", "42"); err != nil {
+		t.Fatal(err)
+	}
+	dirs, _ := filepath.Glob(filepath.Join(e.outDir, "*_Draft_post"))
+	if len(dirs) != 1 {
+		t.Fatalf("want one output folder, got %v", dirs)
+	}
+	runID := filepath.Base(dirs[0])
+
+	e.llm.ReplyResult(ai.TaskResult{
+		OutputType: "markdown",
+		Summary:    "Shorter",
+		Files:      []ai.OutputFile{{Path: "post.md", Content: "# Short\n"}},
+	})
+	out, err := execute(t, "chat", runID, "--message", "make it shorter")
+	if err != nil {
+		t.Fatalf("chat: %v\n%s", err, out)
+	}
+	if !strings.Contains(out, "Revision 2") {
+		t.Errorf("missing revision line:\n%s", out)
+	}
+
+	reqs := e.llm.Requests()
+	msgs := reqs[len(reqs)-1].Body["messages"].([]any)
+	if len(msgs) != 3 || !strings.HasPrefix(msgs[2].(map[string]any)["content"].(string), "make it shorter") {
+		t.Errorf("follow-up did not resume the conversation: %+v", msgs)
+	}
+	if reqs[0].Body["system"] != reqs[1].Body["system"] {
+		t.Error("follow-up used a different system prompt")
+	}
+
+	for _, f := range []string{"post.md", ".agent/revisions/1/README.md", ".agent/conversation.json"} {
+		if _, err := os.Stat(filepath.Join(dirs[0], f)); err != nil {
+			t.Errorf("missing %s", f)
+		}
+	}
+	hist, err := execute(t, "history")
+	if err != nil || !strings.Contains(hist, runID) {
+		t.Errorf("history does not list run: %v\n%s", err, hist)
+	}
+}
+
+func TestRunEditPrompt(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake editor is a shell script")
+	}
+	e := setup(t, testharness.Fixtures{
+		"view 42": testharness.OK(asana.Task{GID: "42", Name: "Edit me"}),
+	})
+	script := filepath.Join(t.TempDir(), "fake-editor")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\necho 'Also add a changelog entry.' >> \"$1\"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("VISUAL", "")
+	t.Setenv("EDITOR", script)
+
+	if out, err := execute(t, "run", "42", "--edit"); err != nil {
+		t.Fatalf("run: %v\n%s", err, out)
+	}
+	msgs := e.llm.Requests()[0].Body["messages"].([]any)
+	sent := msgs[0].(map[string]any)["content"].(string)
+	if !strings.Contains(sent, "# Task: Edit me") || !strings.HasSuffix(sent, "Also add a changelog entry.") {
+		t.Errorf("edited prompt not sent:\n%s", sent)
+	}
+	dirs, _ := filepath.Glob(filepath.Join(e.outDir, "*_Edit_me"))
+	if len(dirs) != 1 {
+		t.Fatalf("want one output folder, got %v", dirs)
+	}
+	saved, _ := os.ReadFile(filepath.Join(dirs[0], ".agent", "prompt.md"))
+	if string(saved) != sent {
+		t.Errorf("saved prompt differs from sent prompt:\n%s", saved)
+	}
+}
+
+func TestRunDryRun(t *testing.T) {
+	e := setup(t, testharness.Fixtures{
+		"view 42": testharness.OK(asana.Task{GID: "42", Name: "Audit me", Notes: "Check the prompt"}),
+	})
+	dump := filepath.Join(t.TempDir(), "body.json")
+	out, err := execute(t, "run", "42", "--dry-run", "--dump-request", dump)
+	if err != nil {
+		t.Fatalf("dry run: %v", err)
+	}
+	if n := len(e.llm.Requests()); n != 0 {
+		t.Fatalf("dry run sent %d request(s)", n)
+	}
+	for _, want := range []string{"x-api-key: ********", "Model       : claude-sonnet-4-6", "Check the prompt", "Input tokens: ~", "nothing was sent"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+	var body map[string]any
+	data, _ := os.ReadFile(dump)
+	if err := json.Unmarshal(data, &body); err != nil || body["model"] != "claude-sonnet-4-6" {
+		t.Errorf("dumped body: %v %s", err, data)
+	}
+	if entries, _ := os.ReadDir(e.outDir); len(entries) != 0 {
+		t.Errorf("dry run wrote output: %v", entries)
+	}
+}
+
+func TestManifestValidate(t *testing.T) {
+	e := setup(t, testharness.Fixtures{
+		"view 42": testharness.OK(asana.Task{GID: "42", Name: "Ship it", Priority: "high"}),
+	})
+	e.llm.ReplyResult(ai.TaskResult{
+		OutputType: "code_folder",
+		Summary:    "Shipped",
+		Files: []ai.OutputFile{
+			{Path: "main.go", Content: "package main\n"},
+			{Path: "../escape.txt", Content: "nope"},
+		},
+	})
+	out, err := execute(t, "run", "42")
+	if err != nil {
+		t.Fatalf("run: %v\n%s", err, out)
+	}
+	if !strings.Contains(out, "Rejected ../escape.txt") {
+		t.Errorf("rejected file not reported:\n%s", out)
+	}
+	dirs, _ := filepath.Glob(filepath.Join(e.outDir, "*_Ship_it"))
+	if len(dirs) != 1 {
+		t.Fatalf("want one output folder, got %v", dirs)
+	}
+	dir := dirs[0]
+	if _, err := os.Stat(filepath.Join(e.outDir, "escape.txt")); err == nil {
+		t.Error("file escaped the output folder")
+	}
+
+	m, err := output.LoadManifest(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Version != output.ManifestVersion || m.Task.GID != "42" || m.Task.Priority != "high" || m.Model != "claude-sonnet-4-6" || m.Usage.OutputTokens != 200 {
+		t.Errorf("manifest metadata: %+v", m)
+	}
+	if len(m.Files) != 1 || m.Files[0].Path != "main.go" || m.Files[0].Size != 13 || len(m.Files[0].SHA256) != 64 {
+		t.Errorf("manifest files: %+v", m.Files)
+	}
+	if len(m.Rejected) != 1 || m.Rejected[0].Path != "../escape.txt" {
+		t.Errorf("manifest rejected: %+v", m.Rejected)
+	}
+
+	if out, err := execute(t, "manifest", "validate", dir); err != nil || !strings.Contains(out, "1 file(s) match") {
+		t.Fatalf("validate intact folder: %v\n%s", err, out)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte("package tampered\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	out, err = execute(t, "manifest", "validate", dir, "--json")
+	if err == nil {
+		t.Fatal("validate accepted a modified file")
+	}
+	var res struct {
+		Valid    bool     `json:"valid"`
+		Problems []string `json:"problems"`
+	}
+	if err := json.Unmarshal([]byte(out), &res); err != nil || res.Valid || len(res.Problems) != 2 {
+		t.Errorf("validate --json: %v %s", err, out)
+	}
+}
+
+// writeFolder fakes a past run of task gid created age ago.
+func writeFolder(t *testing.T, outDir, name, gid string, age time.Duration, status string) string {
+	t.Helper()
+	dir := filepath.Join(outDir, name)
+	exec := &ai.Execution{Result: &ai.TaskResult{Files: []ai.OutputFile{{Path: "a.txt", Content: "data"}}}}
+	if err := output.WriteTo(exec, &asana.Task{GID: gid, Name: name}, dir); err != nil {
+		t.Fatal(err)
+	}
+	err := output.UpdateManifest(dir, func(m *output.Manifest) {
+		m.CreatedAt = time.Now().Add(-age)
+		if status != "" {
+			m.Verification = &output.ManifestVerification{Status: status}
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestOutputsPrune(t *testing.T) {
+	e := setup(t, testharness.Fixtures{
+		"view 1": testharness.OK(asana.Task{GID: "1", Name: "Again"}),
+	})
+	day := 24 * time.Hour
+	old := writeFolder(t, e.outDir, "old", "1", 3*day, "")
+	mid := writeFolder(t, e.outDir, "mid", "1", 2*day, "failed")
+	newer := writeFolder(t, e.outDir, "new", "1", day, "")
+	other := writeFolder(t, e.outDir, "other", "2", 40*day, "")
+	foreign := filepath.Join(e.outDir, "notes")
+	if err := os.MkdirAll(foreign, 0755); err != nil {
+		t.Fatal(err)
+	}
+	exists := func(dir string) bool { _, err := os.Stat(dir); return err == nil }
+
+	out, err := execute(t, "outputs", "prune", "--keep", "1", "--dry-run")
+	if err != nil {
+		t.Fatalf("dry run: %v\n%s", err, out)
+	}
+	if !strings.Contains(out, "Would delete 2 folder(s)") || !exists(old) || !exists(mid) {
+		t.Fatalf("dry run output or deletion:\n%s", out)
+	}
+
+	if out, err := execute(t, "outputs", "prune", "--failed-only"); err != nil || !strings.Contains(out, "Deleted 1 folder(s)") {
+		t.Fatalf("failed-only: %v\n%s", err, out)
+	}
+	if exists(mid) || !exists(old) {
+		t.Error("failed-only pruned the wrong folders")
+	}
+
+	if _, err := execute(t, "outputs", "prune", "--older-than", "30"); err != nil {
+		t.Fatal(err)
+	}
+	if exists(other) || !exists(old) || !exists(newer) || !exists(foreign) {
+		t.Error("older-than pruned the wrong folders")
+	}
+
+	// Auto prune after a run keeps only the new folder of task 1.
+	cfg, _ := config.Load()
+	cfg.Retention = output.Policy{KeepPerTask: 1, Auto: true}
+	if err := config.Save(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := execute(t, "run", "1"); err != nil || !strings.Contains(out, "Pruned 2 old output folder(s)") {
+		t.Fatalf("auto prune: %v\n%s", err, out)
+	}
+	if exists(old) || exists(newer) || !exists(foreign) {
+		t.Error("auto prune pruned the wrong folders")
+	}
+	if dirs, _ := filepath.Glob(filepath.Join(e.outDir, "*_Again")); len(dirs) != 1 {
+		t.Errorf("new run folder missing: %v", dirs)
+	}
+}
+
+func TestTaskLayoutRevisions(t *testing.T) {
+	e := setup(t, testharness.Fixtures{
+		"view 42": testharness.OK(asana.Task{GID: "42", Name: "Greeter"}),
+	})
+	cfg, _ := config.Load()
+	cfg.OutputLayout = output.LayoutTask
+	if err := config.Save(cfg); err != nil {
+		t.Fatal(err)
+	}
+	for _, greeting := range []string{"hello\n", "hello, world\n"} {
+		e.llm.ReplyResult(ai.TaskResult{OutputType: "markdown", Summary: "Greets", Files: []ai.OutputFile{{Path: "greet.txt", Content: greeting}}})
+		if out, err := execute(t, "run", "42"); err != nil {
+			t.Fatalf("run: %v\n%s", err, out)
+		}
+	}
+
+	taskDir := filepath.Join(e.outDir, "42")
+	ix, err := output.LoadIndex(taskDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ix.Latest != 2 || len(ix.Revisions) != 2 || ix.TaskName != "Greeter" {
+		t.Errorf("index = %+v", ix)
+	}
+	if data, err := os.ReadFile(filepath.Join(taskDir, output.Latest, "greet.txt")); err != nil || string(data) != "hello, world\n" {
+		t.Errorf("latest does not point at rev-2: %q %v", data, err)
+	}
+	if out, _ := execute(t, "history"); !strings.Contains(out, "42/rev-2") {
+		t.Errorf("history does not list revisions:\n%s", out)
+	}
+
+	out, err := execute(t, "outputs", "diff", "42")
+	if err != nil {
+		t.Fatalf("diff: %v\n%s", err, out)
+	}
+	for _, want := range []string{"--- rev-1/greet.txt", "+++ rev-2/greet.txt", "-hello\n", "+hello, world", "1 file(s) changed"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("diff missing %q:\n%s", want, out)
+		}
+	}
+	if _, err := execute(t, "outputs", "diff", "42", "7"); err == nil {
+		t.Error("diff accepted an unknown revision")
+	}
+
+	if _, err := execute(t, "outputs", "prune", "--keep", "1"); err != nil {
+		t.Fatal(err)
+	}
+	ix, _ = output.LoadIndex(taskDir)
+	if len(ix.Revisions) != 1 || ix.Revisions[0].N != 2 {
+		t.Errorf("prune did not update the index: %+v", ix)
+	}
+	if _, err := os.Stat(filepath.Join(taskDir, "rev-1")); !os.IsNotExist(err) {
+		t.Error("rev-1 not pruned")
+	}
+}
+
+func TestRunEncodedAndExecutableFiles(t *testing.T) {
+	e := setup(t, testharness.Fixtures{
+		"view 42": testharness.OK(asana.Task{GID: "42", Name: "Assets"}),
+	})
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00binary")
+	e.llm.ReplyResult(ai.TaskResult{
+		OutputType: "mixed",
+		Summary:    "Assets",
+		Files: []ai.OutputFile{
+			{Path: "logo.png", Content: base64.StdEncoding.EncodeToString(png), Encoding: "base64"},
+			{Path: "run.sh", Content: "#!/bin/sh\necho hi\n", Mode: "executable"},
+			{Path: "bad.bin", Content: "not base64!", Encoding: "base64"},
+			{Path: "odd.txt", Content: "x", Mode: "0111"},
+		},
+	})
+	if out, err := execute(t, "run", "42"); err != nil {
+		t.Fatalf("run: %v\n%s", err, out)
+	}
+	dirs, _ := filepath.Glob(filepath.Join(e.outDir, "*_Assets"))
+	if len(dirs) != 1 {
+		t.Fatalf("want one output folder, got %v", dirs)
+	}
+	dir := dirs[0]
+	if data, _ := os.ReadFile(filepath.Join(dir, "logo.png")); string(data) != string(png) {
+		t.Errorf("logo.png not decoded: %q", data)
+	}
+	if runtime.GOOS != "windows" {
+		if info, err := os.Stat(filepath.Join(dir, "run.sh")); err != nil || info.Mode().Perm() != 0755 {
+			t.Errorf("run.sh mode: %v %v", info.Mode(), err)
+		}
+	}
+
+	m, err := output.LoadManifest(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byPath := map[string]output.ManifestEntry{}
+	for _, f := range m.Files {
+		byPath[f.Path] = f
+	}
+	if f := byPath["logo.png"]; f.Encoding != "base64" || f.Size != int64(len(png)) {
+		t.Errorf("logo.png entry: %+v", f)
+	}
+	if f := byPath["run.sh"]; f.Mode != "0755" {
+		t.Errorf("run.sh entry: %+v", f)
+	}
+	rejected := map[string]string{}
+	for _, r := range m.Rejected {
+		rejected[r.Path] = r.Reason
+	}
+	if rejected["bad.bin"] != "invalid base64" || !strings.Contains(rejected["odd.txt"], "invalid mode") {
+		t.Errorf("rejected = %v", rejected)
+	}
+	if out, err := execute(t, "manifest", "validate", dir); err != nil {
+		t.Errorf("validate: %v\n%s", err, out)
+	}
+}
+
+func TestRunOpenAICompatProvider(t *testing.T) {
+	e := setup(t, testharness.Fixtures{
+		"view 7": testharness.OK(asana.Task{GID: "7", Name: "Local task"}),
+	})
+	if _, err := execute(t, "run", "7", "-p", "ollama", "-m", "qwen2.5-coder"); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	reqs := e.llm.Requests()
+	if len(reqs) != 1 || reqs[0].Path != "/v1/chat/completions" {
+		t.Fatalf("want one chat completions request, got %+v", reqs)
+	}
+	if reqs[0].Body["model"] != "qwen2.5-coder" || reqs[0].Body["stream"] != true {
+		t.Errorf("model = %v, stream = %v", reqs[0].Body["model"], reqs[0].Body["stream"])
+	}
+}
+
+func TestProvidersLive(t *testing.T) {
+	setup(t, nil)
+	out, err := execute(t, "providers", "--live")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Anthropic has a key and Ollama needs none; OpenAI is skipped.
+	if n := strings.Count(out, "Available to your key (1):\n       fake-model"); n != 2 {
+		t.Errorf("want 2 live model lists, got %d:\n%s", n, out)
+	}
+	if out, _ := execute(t, "providers"); strings.Contains(out, "Available to your key") {
+		t.Errorf("models listed without --live:\n%s", out)
+	}
+}
+
+func TestProfiles(t *testing.T) {
+	e := setup(t, testharness.Fixtures{
+		"view 7": testharness.OK(asana.Task{GID: "7", Name: "Client task"}),
+	})
+	if _, err := execute(t, "config", "profiles", "create", "client", "--workspace", "ws-2", "--provider", "ollama", "--model", "qwen2.5-coder"); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if _, err := execute(t, "config", "profiles", "create", "client"); err == nil {
+		t.Error("creating a duplicate profile should fail")
+	}
+	out, err := execute(t, "config", "profiles", "list")
+	if err != nil || !strings.Contains(out, "client") || !strings.Contains(out, "ollama / qwen2.5-coder") || strings.Contains(out, "* client") {
+		t.Fatalf("list: %v\n%s", err, out)
+	}
+
+	// --profile and $TASK_AGENT_PROFILE both select it.
+	if _, err := execute(t, "--profile", "client", "run", "7"); err != nil {
+		t.Fatalf("run --profile: %v", err)
+	}
+	t.Setenv(config.ProfileEnv, "client")
+	if _, err := execute(t, "run", "7"); err != nil {
+		t.Fatalf("run with %s: %v", config.ProfileEnv, err)
+	}
+	t.Setenv(config.ProfileEnv, "")
+	if _, err := execute(t, "run", "7"); err != nil {
+		t.Fatalf("run without profile: %v", err)
+	}
+	reqs := e.llm.Requests()
+	if len(reqs) != 3 {
+		t.Fatalf("want 3 requests, got %d", len(reqs))
+	}
+	for i, want := range []string{"qwen2.5-coder", "qwen2.5-coder", "claude-sonnet-4-6"} {
+		if reqs[i].Body["model"] != want {
+			t.Errorf("request %d model = %v, want %s", i, reqs[i].Body["model"], want)
+		}
+	}
+
+	if _, err := execute(t, "--profile", "nope", "list"); !errors.Is(err, config.ErrUnknownProfile) {
+		t.Errorf("unknown profile: got %v", err)
+	}
+
+	// Saving with a profile active writes profile fields to the profile only.
+	cfg, err := config.LoadProfile("client")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.ProjectGID = "proj-2"
+	cfg.Theme = "light"
+	if err := config.Save(cfg); err != nil {
+		t.Fatal(err)
+	}
+	base, err := config.LoadBase()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if base.ProjectGID != "proj-1" || base.Provider != "anthropic" || base.Theme != "light" {
+		t.Errorf("base changed: project=%s provider=%s theme=%s", base.ProjectGID, base.Provider, base.Theme)
+	}
+	if p := base.Profiles["client"]; p.ProjectGID != "proj-2" || p.WorkspaceGID != "ws-2" || p.Provider != "ollama" {
+		t.Errorf("profile = %+v", p)
+	}
+
+	// A profile value that matches the base is still the profile's: it is
+	// saved and does not follow later base changes.
+	base.Provider, base.Model = "ollama", "qwen2.5-coder"
+	if err := config.Save(base); err != nil {
+		t.Fatal(err)
+	}
+	if cfg, err = config.LoadProfile("client"); err != nil {
+		t.Fatal(err)
+	}
+	cfg.Theme = "dark"
+	if err := config.Save(cfg); err != nil {
+		t.Fatal(err)
+	}
+	base, _ = config.LoadBase()
+	base.Provider, base.Model = "anthropic", "claude-sonnet-4-6"
+	if err := config.Save(base); err != nil {
+		t.Fatal(err)
+	}
+	if cfg, _ := config.LoadProfile("client"); cfg.Provider != "ollama" || cfg.Model != "qwen2.5-coder" {
+		t.Errorf("profile followed the base: %s / %s", cfg.Provider, cfg.Model)
+	}
+
+	if out, _ := execute(t, "--profile", "client", "config", "profiles", "list"); !strings.Contains(out, "* client") {
+		t.Errorf("--profile not marked active:\n%s", out)
+	}
+	if _, err := execute(t, "config", "profiles", "use", "client"); err != nil {
+		t.Fatal(err)
+	}
+	if cfg, _ := config.Load(); cfg.Active != "client" || cfg.WorkspaceGID != "ws-2" {
+		t.Errorf("default profile not applied: active=%q workspace=%s", cfg.Active, cfg.WorkspaceGID)
+	}
+	if _, err := execute(t, "config", "profiles", "delete", "client"); err != nil {
+		t.Fatal(err)
+	}
+	if cfg, err := config.Load(); err != nil || cfg.Active != "" || cfg.DefaultProfile != "" {
+		t.Errorf("after delete: %v active=%q default=%q", err, cfg.Active, cfg.DefaultProfile)
+	}
+}
+
+func TestProjectConfig(t *testing.T) {
+	e := setup(t, testharness.Fixtures{
+		"view 7": testharness.OK(asana.Task{GID: "7", Name: "Repo task"}),
+	})
+	repo := filepath.Join(filepath.Dir(e.outDir), "repo")
+	sub := filepath.Join(repo, "pkg", "deep")
+	if err := os.MkdirAll(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+	project := "provider: ollama\nmodel: qwen2.5-coder\noutput_dir: build/agent\nbase_urls:\n  ollama: http://evil.example\n"
+	if err := os.WriteFile(filepath.Join(repo, ".task-agent.yaml"), []byte(project), 0644); err != nil {
+		t.Fatal(err)
+	}
+	chdir(t, sub)
+
+	// Project values apply from any subdirectory; output_dir is relative
+	// to the project file.
+	if _, err := execute(t, "run", "7"); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	// Flags still win over the project.
+	if _, err := execute(t, "run", "7", "-m", "llama3"); err != nil {
+		t.Fatalf("run -m: %v", err)
+	}
+	reqs := e.llm.Requests()
+	if len(reqs) != 2 || reqs[0].Body["model"] != "qwen2.5-coder" || reqs[1].Body["model"] != "llama3" {
+		t.Fatalf("requests: %+v", reqs)
+	}
+	if dirs, _ := filepath.Glob(filepath.Join(repo, "build", "agent", "*_Repo_task")); len(dirs) == 0 {
+		t.Error("no output under the project's output_dir")
+	}
+
+	out, err := execute(t, "config", "show", "--resolved")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"Project config: " + filepath.Join(repo, ".task-agent.yaml"),
+		`"qwen2.5-coder"`,
+		"project " + filepath.Join(repo, ".task-agent.yaml"),
+		"workspace_gid                    \"ws-1\"",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("show --resolved missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "evil.example") {
+		t.Errorf("project base_urls should be ignored:\n%s", out)
+	}
+
+	// Saving keeps project values out of the user config.
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Theme = "nord"
+	if err := config.Save(cfg); err != nil {
+		t.Fatal(err)
+	}
+	user, err := config.LoadBase()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Model != "claude-sonnet-4-6" || user.OutputDir != e.outDir || user.Theme != "nord" {
+		t.Errorf("user config: model=%s output=%s theme=%s", user.Model, user.OutputDir, user.Theme)
+	}
+}
+
+func TestConfigCommands(t *testing.T) {
+	setup(t, nil)
+	t.Setenv("OPENAI_API_KEY", "")
+
+	if out, err := execute(t, "config", "get", "model"); err != nil || out != "claude-sonnet-4-6\n" {
+		t.Errorf("get model = %q, %v", out, err)
+	}
+	for _, kv := range [][2]string{
+		{"model", "claude-opus-4-6"},
+		{"verify.fix_attempts", "3"},
+		{"verify.enabled", "true"},
+		{"compare_models", "openai/gpt-4o, ollama/llama3.1"},
+		{"api_keys.openai", "sk-openai"},
+	} {
+		if _, err := execute(t, "config", "set", kv[0], kv[1]); err != nil {
+			t.Fatalf("set %s: %v", kv[0], err)
+		}
+	}
+	cfg, err := config.LoadBase()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Model != "claude-opus-4-6" || cfg.Verify.FixAttempts != 3 || !cfg.Verify.Enabled || len(cfg.CompareModels) != 2 || cfg.APIKeys["openai"] != "sk-openai" {
+		t.Errorf("after set: %+v", cfg)
+	}
+	if out, err := execute(t, "config", "get", "compare_models", "--json"); err != nil || !strings.Contains(out, `"ollama/llama3.1"`) {
+		t.Errorf("get compare_models = %q, %v", out, err)
+	}
+	if _, err := execute(t, "config", "set", "verify.fix_attempts", "many"); err == nil {
+		t.Error("want a parse error for a non-integer")
+	}
+	if _, err := execute(t, "config", "set", "no_such_key", "x"); !errors.Is(err, config.ErrUnknownKey) {
+		t.Errorf("unknown key: got %v", err)
+	}
+
+	for _, key := range []string{"model", "api_keys.openai"} {
+		if _, err := execute(t, "config", "unset", key); err != nil {
+			t.Fatalf("unset %s: %v", key, err)
+		}
+	}
+	if cfg, _ := config.LoadBase(); cfg.Model != "claude-sonnet-4-6" || cfg.APIKeys["openai"] != "" {
+		t.Errorf("after unset: model=%s openai key=%q", cfg.Model, cfg.APIKeys["openai"])
+	}
+
+	out, err := execute(t, "config", "list")
+	if err != nil || !strings.Contains(out, "verify.fix_attempts=3\n") || !strings.Contains(out, "api_keys.anthropic=********") || strings.Contains(out, "sk-test") {
+		t.Errorf("list: %v\n%s", err, out)
+	}
+
+	out, err = execute(t, "config", "validate", "--json")
+	if err != nil {
+		t.Fatalf("validate: %v\n%s", err, out)
+	}
+	var res struct {
+		Valid    bool
+		Problems []string
+	}
+	if err := json.Unmarshal([]byte(out), &res); err != nil || !res.Valid {
+		t.Fatalf("validate json: %v\n%s", err, out)
+	}
+
+	execute(t, "config", "set", "provider", "openai")
+	execute(t, "config", "set", "model", "gpt-5-imaginary")
+	execute(t, "config", "set", "output_layout", "nested")
+	execute(t, "config", "set", "theme_overrides.accent", "blue")
+	execute(t, "config", "set", "keys.search", "r")
+	out, err = execute(t, "config", "validate", "--json")
+	if err == nil {
+		t.Fatal("want a non-zero exit for an invalid config")
+	}
+	if err := json.Unmarshal([]byte(out), &res); err != nil || res.Valid {
+		t.Fatalf("validate json: %v\n%s", err, out)
+	}
+	got := strings.Join(res.Problems, "\n")
+	for _, want := range []string{"model: ", "api_keys.openai: ", "output_layout: ", "theme_overrides.accent: ", "keys.search: "} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %q problem in:\n%s", want, got)
+		}
+	}
+}
+
+func TestKeyReferences(t *testing.T) {
+	keyring.MockInit()
+	e := setup(t, testharness.Fixtures{
+		"view 42": testharness.OK(asana.Task{GID: "42", Name: "Task"}),
+	})
+	keyFile := filepath.Join(t.TempDir(), "anthropic.key")
+	if err := os.WriteFile(keyFile, []byte("sk-from-file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("MY_ANTHROPIC", "sk-from-env")
+	refs := [][2]string{
+		{"file:" + keyFile, "sk-from-file"},
+		{"env:MY_ANTHROPIC", "sk-from-env"},
+	}
+	if runtime.GOOS != "windows" {
+		refs = append(refs, [2]string{"cmd:printf 'sk-from-cmd\\nsecond line'", "sk-from-cmd"})
+	}
+	for _, ref := range refs {
+		if _, err := execute(t, "config", "set", "api_keys.anthropic", ref[0]); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := execute(t, "run", "42"); err != nil {
+			t.Fatalf("run with %s: %v", ref[0], err)
+		}
+		reqs := e.llm.Requests()
+		if got := reqs[len(reqs)-1].Headers.Get("X-Api-Key"); got != ref[1] {
+			t.Errorf("%s: x-api-key = %q, want %q", ref[0], got, ref[1])
+		}
+	}
+	// References are shown, not masked.
+	if out, _ := execute(t, "config", "list"); !strings.Contains(out, "api_keys.anthropic="+refs[len(refs)-1][0]) {
+		t.Errorf("list should show the reference:\n%s", out)
+	}
+
+	execute(t, "config", "set", "api_keys.anthropic", "file:"+filepath.Join(t.TempDir(), "missing"))
+	if _, err := execute(t, "run", "42"); err == nil || !strings.Contains(err.Error(), "api_keys.anthropic") {
+		t.Errorf("want a key reference error, got %v", err)
+	}
+
+	// Migration moves plaintext keys, including profile keys, and leaves
+	// references behind.
+	execute(t, "config", "set", "api_keys.anthropic", "sk-plain")
+	execute(t, "config", "profiles", "create", "client")
+	execute(t, "--profile", "client", "config", "set", "api_keys.openai", "sk-client")
+	out, err := execute(t, "config", "migrate-keys", "--dry-run")
+	if err != nil || !strings.Contains(out, "Would move api_keys.anthropic") {
+		t.Fatalf("dry run: %v\n%s", err, out)
+	}
+	if _, err := execute(t, "config", "migrate-keys", "--to", "keyring"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(config.ConfigPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "sk-plain") || strings.Contains(string(data), "sk-client") {
+		t.Errorf("plaintext keys left in config:\n%s", data)
+	}
+	cfg, _ := config.LoadBase()
+	if cfg.APIKeys["anthropic"] != "keyring:" || cfg.Profiles["client"].APIKeys["openai"] != "keyring:client/openai" {
+		t.Errorf("refs: %v / %v", cfg.APIKeys, cfg.Profiles["client"].APIKeys)
+	}
+	if got, _ := keyring.Get(config.KeyringService, "client/openai"); got != "sk-client" {
+		t.Errorf("keyring client/openai = %q", got)
+	}
+	if _, err := execute(t, "run", "42"); err != nil {
+		t.Fatal(err)
+	}
+	reqs := e.llm.Requests()
+	if got := reqs[len(reqs)-1].Headers.Get("X-Api-Key"); got != "sk-plain" {
+		t.Errorf("x-api-key from keyring = %q", got)
+	}
+}
+
+func TestEnvOverrides(t *testing.T) {
+	e := setup(t, testharness.Fixtures{
+		"view 42": testharness.OK(asana.Task{GID: "42", Name: "Env task"}),
+	})
+	user, err := config.LoadBase()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// No config file at all: everything comes from the environment.
+	if err := os.Remove(config.ConfigPath()); err != nil {
+		t.Fatal(err)
+	}
+	envOut := filepath.Join(t.TempDir(), "ci-out")
+	t.Setenv("TASK_AGENT_ASANA_CLI_PATH", user.AsanaCLIPath)
+	t.Setenv("TASK_AGENT_MODEL", "claude-opus-4-6")
+	t.Setenv("TASK_AGENT_OUTPUT_DIR", envOut)
+	t.Setenv("TASK_AGENT_OUTPUT_LAYOUT", "task")
+	t.Setenv("TASK_AGENT_COMPARE_MODELS", "openai/gpt-4o,groq/llama-3.1-8b-instant")
+	t.Setenv("TASK_AGENT_BASE_URLS_MOONSHOT", "http://moonshot.internal/v1")
+	t.Setenv("ANTHROPIC_API_KEY", "sk-env")
+
+	if _, err := execute(t, "run", "42"); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	reqs := e.llm.Requests()
+	if len(reqs) != 1 || reqs[0].Body["model"] != "claude-opus-4-6" || reqs[0].Headers.Get("X-Api-Key") != "sk-env" {
+		t.Fatalf("requests: %+v", reqs)
+	}
+	if _, err := os.Stat(filepath.Join(envOut, "42", "rev-1")); err != nil {
+		t.Errorf("output not under TASK_AGENT_OUTPUT_DIR with the task layout: %v", err)
+	}
+	for key, want := range map[string]string{
+		"base_urls.moonshot": "http://moonshot.internal/v1\n",
+		"compare_models":     "[\n  \"openai/gpt-4o\",\n  \"groq/llama-3.1-8b-instant\"\n]\n",
+	} {
+		if out, err := execute(t, "config", "get", key); err != nil || out != want {
+			t.Errorf("get %s = %q, %v", key, out, err)
+		}
+	}
+	out, err := execute(t, "config", "show", "--resolved")
+	if err != nil || !strings.Contains(out, "env TASK_AGENT_MODEL") || !strings.Contains(out, "env ANTHROPIC_API_KEY") {
+		t.Errorf("show --resolved: %v\n%s", err, out)
+	}
+
+	// A flag wins over the environment and is reported as the source.
+	if _, err := execute(t, "run", "42", "-m", "claude-haiku-4-5"); err != nil {
+		t.Fatalf("run -m: %v", err)
+	}
+	if reqs := e.llm.Requests(); len(reqs) != 2 || reqs[1].Body["model"] != "claude-haiku-4-5" {
+		t.Fatalf("requests: %+v", reqs)
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.SetFlag("model", "claude-haiku-4-5", "--model"); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Model != "claude-haiku-4-5" || cfg.Source("model") != config.SourceFlag+" --model" {
+		t.Errorf("model = %s from %q", cfg.Model, cfg.Source("model"))
+	}
+	for _, s := range cfg.Resolved() {
+		if s.Key == "model" && (s.Value != "claude-haiku-4-5" || s.Source != "flag --model") {
+			t.Errorf("resolved model: %+v", s)
+		}
+	}
+	if err := cfg.SetFlag("verify.fix_attempts", "lots", "--fix"); err == nil {
+		t.Error("a malformed flag value was accepted")
+	}
+
+	// Env and flag values are not written back when the config is saved.
+	cfg.Theme = "nord"
+	if err := config.Save(cfg); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(config.ConfigPath())
+	if strings.Contains(string(data), "claude-opus-4-6") || strings.Contains(string(data), "claude-haiku-4-5") || strings.Contains(string(data), envOut) || !strings.Contains(string(data), "nord") {
+		t.Errorf("saved config:\n%s", data)
+	}
+
+	// A malformed value is ignored with a warning, and validate fails.
+	t.Setenv("TASK_AGENT_VERIFY_FIX_ATTEMPTS", "lots")
+	if cfg, _ := config.Load(); cfg.Verify.FixAttempts != 0 || len(cfg.Warnings()) != 1 {
+		t.Errorf("fix attempts = %d, warnings %v", cfg.Verify.FixAttempts, cfg.Warnings())
+	}
+	if out, err := execute(t, "config", "validate"); err == nil || !strings.Contains(out, "TASK_AGENT_VERIFY_FIX_ATTEMPTS") {
+		t.Errorf("validate: %v\n%s", err, out)
+	}
+}
+
+func TestConfigMigration(t *testing.T) {
+	setup(t, nil)
+	path := config.ConfigPath()
+	v1 := `{"provider": "openai", "model": "gpt-4o", "colour": "teal", "verify": {"enabled": true, "enabld": true}}`
+	if err := os.WriteFile(path, []byte(v1), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Provider != "openai" || !cfg.Verify.Enabled || cfg.Upgraded() != "" {
+		t.Errorf("loaded config: %+v", cfg)
+	}
+	warnings := strings.Join(cfg.Warnings(), "\n")
+	for _, want := range []string{`unknown key "colour"`, `unknown key "verify.enabld"`} {
+		if !strings.Contains(warnings, want) {
+			t.Errorf("missing warning %q in:\n%s", want, warnings)
+		}
+	}
+	if _, err := os.Stat(path + ".v1.bak"); err == nil {
+		t.Error("a current config was backed up")
+	}
+
+	// Saving keeps the unknown keys and leaves no temp files behind.
+	cfg.Model = "gpt-4o-mini"
+	if err := config.Save(cfg); err != nil {
+		t.Fatal(err)
+	}
+	var onDisk map[string]any
+	data, _ := os.ReadFile(path)
+	if err := json.Unmarshal(data, &onDisk); err != nil || onDisk["colour"] != "teal" || onDisk["model"] != "gpt-4o-mini" ||
+		onDisk["verify"].(map[string]any)["enabld"] != true {
+		t.Errorf("saved config: %v\n%s", err, data)
+	}
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	for _, e := range entries {
+		if strings.Contains(e.Name(), ".tmp") {
+			t.Errorf("leftover temp file %s", e.Name())
+		}
+	}
+
+	// A file from a newer version is read and saved with its version and
+	// the settings this build does not know.
+	newer := `{"version": 99, "provider": "openai", "model": "gpt-4o", "new_setting": {"a": 1}}`
+	os.WriteFile(path, []byte(newer), 0600)
+	cfg, err = config.Load()
+	if err != nil || !strings.Contains(strings.Join(cfg.Warnings(), "\n"), "newer task-agent") {
+		t.Errorf("newer config: %v %v", err, cfg.Warnings())
+	}
+	if data, _ := os.ReadFile(path); string(data) != newer {
+		t.Errorf("newer config was rewritten on load:\n%s", data)
+	}
+	if err := config.Save(cfg); err != nil {
+		t.Fatal(err)
+	}
+	data, _ = os.ReadFile(path)
+	onDisk = nil
+	if err := json.Unmarshal(data, &onDisk); err != nil || onDisk["version"] != float64(99) || onDisk["new_setting"] == nil {
+		t.Errorf("newer config after save: %v\n%s", err, data)
+	}
+}
+
+func TestConfigLocations(t *testing.T) {
+	home := testharness.Home(t)
+	want := func(path string) {
+		t.Helper()
+		if got := config.ConfigPath(); got != path {
+			t.Errorf("config path = %s, want %s", got, path)
+		}
+	}
+	want(filepath.Join(home, ".config", "task-agent", "config.json"))
+	if runtime.GOOS != "windows" {
+		xdg := t.TempDir()
+		t.Setenv("XDG_CONFIG_HOME", xdg)
+		want(filepath.Join(xdg, "task-agent", "config.json"))
+	}
+	// An existing ~/.task-agent keeps being used.
+	if err := os.MkdirAll(config.LegacyDir(), 0700); err != nil {
+		t.Fatal(err)
+	}
+	want(filepath.Join(home, ".task-agent", "config.json"))
+	isolated := t.TempDir()
+	t.Setenv(config.HomeEnv, isolated)
+	want(filepath.Join(isolated, "config.json"))
+	if got := config.ThemesDir(); got != filepath.Join(isolated, "themes") {
+		t.Errorf("themes dir = %s", got)
+	}
+
+	// --config points at any file and leaves the default one alone.
+	t.Cleanup(func() { config.SetConfigFile("") })
+	custom := filepath.Join(t.TempDir(), "team", "agent.json")
+	if _, err := execute(t, "--config", custom, "config", "set", "model", "claude-opus-4-6"); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := execute(t, "--config", custom, "config", "get", "model"); err != nil || out != "claude-opus-4-6\n" {
+		t.Errorf("get from --config file = %q, %v", out, err)
+	}
+	if out, _ := execute(t, "config", "get", "model"); out != "claude-sonnet-4-6\n" {
+		t.Errorf("default config changed: model = %q", out)
+	}
+	if _, err := os.Stat(filepath.Join(isolated, "config.json")); err == nil {
+		t.Error("--config wrote to the default location")
+	}
+}
+
+func TestRunAsanaFailure(t *testing.T) {
+	setup(t, testharness.Fixtures{
+		"view 404": testharness.Fail("task not found"),
+	})
+	_, err := execute(t, "run", "404")
+	if err == nil || !strings.Contains(err.Error(), "task not found") {
+		t.Fatalf("want asana error, got %v", err)
+	}
+}
+
+func TestRunProviderError(t *testing.T) {
+	e := setup(t, testharness.Fixtures{
+		"view 42": testharness.OK(asana.Task{GID: "42", Name: "Task"}),
+	})
+	e.llm.Reply(testharness.LLMReply{Status: 401, Text: "invalid x-api-key"})
+	_, err := execute(t, "run", "42")
+	if err == nil || !strings.Contains(err.Error(), "invalid x-api-key") {
+		t.Fatalf("want provider error, got %v", err)
+	}
+}
+
+func TestRunMissingKey(t *testing.T) {
+	testharness.Home(t)
+	t.Setenv("OPENAI_API_KEY", "")
+	fake := testharness.NewFakeAsana(t, testharness.Fixtures{
+		"view 1": testharness.OK(asana.Task{GID: "1", Name: "Task"}),
+	})
+	cfg := config.Defaults()
+	cfg.AsanaCLIPath = fake.Path
+	if err := config.Save(cfg); err != nil {
+		t.Fatal(err)
+	}
+	_, err := execute(t, "run", "1", "-p", "openai")
+	if err == nil || !strings.Contains(err.Error(), "OPENAI_API_KEY") {
+		t.Fatalf("want missing key error, got %v", err)
+	}
+}
+
+// TestReplayWithoutKey records compare and eval runs, then replays them with
+// no API key and the LLM server gone.
+func TestReplayWithoutKey(t *testing.T) {
+	e := setup(t, testharness.Fixtures{
+		"view 42": testharness.OK(asana.Task{GID: "42", Name: "Write the docs"}),
+	})
+	t.Cleanup(func() { ai.SetTransport(nil) })
+	dir := t.TempDir()
+	cassettes := filepath.Join(dir, "cassettes")
+	suite := filepath.Join(dir, "suite.json")
+	if err := os.WriteFile(filepath.Join(dir, "task.md"), []byte("Write a README"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(suite, []byte(`{"cases": [{"name": "readme", "task_file": "task.md", "assertions": [{"type": "file_exists", "path": "README.md"}]}]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	compareArgs := []string{"compare", "42", "--models", "anthropic/claude-sonnet-4-6,anthropic/claude-haiku-4-5-20251001"}
+	evalArgs := []string{"eval", suite, "--models", "anthropic/claude-sonnet-4-6", "--fail-under", "1"}
+
+	for _, args := range [][]string{compareArgs, evalArgs} {
+		if out, err := execute(t, append([]string{"--record", cassettes}, args...)...); err != nil {
+			t.Fatalf("record %s: %v\n%s", args[0], err, out)
+		}
+	}
+	recorded := len(e.llm.Requests())
+
+	cfg, err := config.LoadBase()
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.SetAPIKey(cfg, "anthropic", "")
+	if err := config.Save(cfg); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{compareArgs, evalArgs} {
+		out, err := execute(t, append([]string{"--replay", cassettes}, args...)...)
+		if err != nil {
+			t.Fatalf("replay %s: %v\n%s", args[0], err, out)
+		}
+		if strings.Contains(out, "❌") || strings.Contains(out, "no API key") {
+			t.Errorf("replay %s failed a model:\n%s", args[0], out)
+		}
+	}
+	if n := len(e.llm.Requests()); n != recorded {
+		t.Errorf("replay sent %d live request(s)", n-recorded)
+	}
+}
+
+func TestListJSON(t *testing.T) {
+	setup(t, testharness.Fixtures{
+		"list proj-1": testharness.OK(testharness.Tasks("Alpha", "Beta")),
+	})
+	out, err := execute(t, "list", "--json")
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	var tasks []asana.Task
+	if err := json.Unmarshal([]byte(out), &tasks); err != nil {
+		t.Fatalf("decode %q: %v", out, err)
+	}
+	if len(tasks) != 2 || tasks[1].Name != "Beta" {
+		t.Errorf("unexpected tasks: %+v", tasks)
+	}
+}
+
+func TestListErrors(t *testing.T) {
+	setup(t, testharness.Fixtures{
+		"list proj-1": {Exit: 1, Stderr: "ASANA_TOKEN not set"},
+		"list other":  testharness.Fail("project not found"),
+		"list broken": {Raw: "not json"},
+	})
+	for project, want := range map[string]string{
+		"proj-1": "ASANA_TOKEN not set",
+		"other":  "project not found",
+		"broken": "invalid character",
+	} {
+		_, err := execute(t, "list", "-P", project)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("list -P %s: want %q, got %v", project, want, err)
+		}
+	}
+}
+
+func TestSearchTable(t *testing.T) {
+	e := setup(t, testharness.Fixtures{
+		"search ws-1 auth": testharness.OK(testharness.Tasks("Fix auth bug")),
+	})
+	out, err := execute(t, "search", "auth")
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if !strings.Contains(out, "Fix auth bug") {
+		t.Errorf("result missing from table:\n%s", out)
+	}
+	if calls := e.asana.Calls(); len(calls) != 1 || calls[0] != "search ws-1 auth" {
+		t.Errorf("unexpected asana calls: %v", calls)
+	}
+}
@@ -0,0 +1,162 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+func init() {
+	Register(anthropicBackend{info: Provider{
+		ID:           "anthropic",
+		Name:         "Anthropic",
+		Models:       []string{"claude-opus-4-6", "claude-sonnet-4-6", "claude-haiku-4-5-20251001"},
+		DefaultModel: "claude-sonnet-4-6",
+		EnvKey:       "ANTHROPIC_API_KEY",
+		BaseURL:      "https://api.anthropic.com/v1",
+		Pricing: map[string]Price{
+			"claude-opus-4-6":           {Input: 5, Output: 25},
+			"claude-sonnet-4-6":         {Input: 3, Output: 15},
+			"claude-haiku-4-5-20251001": {Input: 1, Output: 5},
+		},
+	}})
+}
+
+const anthropicVersion = "2023-06-01"
+
+// anthropicBackend speaks the Anthropic Messages API.
+type anthropicBackend struct {
+	info Provider
+}
+
+func (b anthropicBackend) Info() Provider { return b.info }
+
+func (b anthropicBackend) Capabilities() Capabilities {
+	return Capabilities{Streaming: true, ListModels: true}
+}
+
+type anthropicRequest struct {
+	Model       string    `json:"model"`
+	MaxTokens   int       `json:"max_tokens"`
+	System      string    `json:"system,omitempty"`
+	Messages    []Message `json:"messages"`
+	Temperature *float64  `json:"temperature,omitempty"`
+	Stream      bool      `json:"stream,omitempty"`
+}
+
+type anthropicResponse struct {
+	Content []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+	Usage Usage `json:"usage"`
+}
+
+func (b anthropicBackend) headers(apiKey string) map[string]string {
+	return map[string]string{
+		"x-api-key":         apiKey,
+		"anthropic-version": anthropicVersion,
+	}
+}
+
+func (b anthropicBackend) body(req Request, stream bool) anthropicRequest {
+	body := anthropicRequest{
+		Model:     req.Model,
+		MaxTokens: req.MaxTokens,
+		System:    req.System,
+		Messages:  req.Messages,
+		Stream:    stream,
+	}
+	if req.Temperature > 0 {
+		t := req.Temperature
+		body.Temperature = &t
+	}
+	return body
+}
+
+func (b anthropicBackend) Prepare(req Request) WireRequest {
+	return WireRequest{URL: baseURL(b.info) + "/messages", Headers: b.headers(req.APIKey), Body: b.body(req, req.Stream)}
+}
+
+func (b anthropicBackend) Complete(ctx context.Context, hc *http.Client, req Request) (*Response, error) {
+	w := b.Prepare(req)
+	resp, err := postJSON(ctx, hc, w.URL, w.Headers, w.Body)
+	if err != nil {
+		return nil, err
+	}
+	var ar anthropicResponse
+	if err := json.Unmarshal(resp, &ar); err != nil {
+		return nil, fmt.Errorf("unmarshal anthropic response: %w", err)
+	}
+	if ar.Error != nil {
+		return nil, fmt.Errorf("Anthropic API: %s", ar.Error.Message)
+	}
+	if len(ar.Content) == 0 {
+		return nil, fmt.Errorf("empty response from Anthropic")
+	}
+	return &Response{Text: ar.Content[0].Text, Usage: ar.Usage}, nil
+}
+
+type anthropicStreamEvent struct {
+	Type    string `json:"type"`
+	Message struct {
+		Usage Usage `json:"usage"`
+	} `json:"message"`
+	Delta struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"delta"`
+	Usage Usage `json:"usage"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (b anthropicBackend) Stream(ctx context.Context, hc *http.Client, req Request, onDelta func(string)) (*Response, error) {
+	out := &Response{}
+	var text []byte
+	err := postSSE(ctx, hc, baseURL(b.info)+"/messages", b.headers(req.APIKey), b.body(req, true), func(data []byte) error {
+		var ev anthropicStreamEvent
+		if err := json.Unmarshal(data, &ev); err != nil {
+			return fmt.Errorf("unmarshal anthropic stream event: %w", err)
+		}
+		switch ev.Type {
+		case "message_start":
+			out.Usage.InputTokens = ev.Message.Usage.InputTokens
+		case "content_block_delta":
+			if ev.Delta.Type == "text_delta" {
+				text = append(text, ev.Delta.Text...)
+				if onDelta != nil {
+					onDelta(ev.Delta.Text)
+				}
+			}
+		case "message_delta":
+			out.Usage.OutputTokens = ev.Usage.OutputTokens
+		case "error":
+			if ev.Error != nil {
+				return fmt.Errorf("Anthropic API: %s", ev.Error.Message)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(text) == 0 {
+		return nil, fmt.Errorf("empty response from Anthropic")
+	}
+	out.Text = string(text)
+	return out, nil
+}
+
+func (b anthropicBackend) ListModels(ctx context.Context, hc *http.Client, apiKey string) ([]string, error) {
+	resp, err := getJSON(ctx, hc, baseURL(b.info)+"/models", b.headers(apiKey))
+	if err != nil {
+		return nil, err
+	}
+	return parseModelList(resp)
+}
@@ -0,0 +1,154 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Backend is a self-contained AI provider implementation. Each backend
+// registers itself with Register from an init func; every consumer (config
+// key lookup, TUI fields, CLI provider listing) is driven from the registry.
+type Backend interface {
+	// Info returns the provider metadata shown to users.
+	Info() Provider
+	// Capabilities reports which optional features the backend supports.
+	Capabilities() Capabilities
+	// Complete sends a request and returns the full response.
+	Complete(ctx context.Context, hc *http.Client, req Request) (*Response, error)
+	// Stream sends a request and calls onDelta for each chunk of text as it
+	// arrives. The returned Response holds the concatenated text.
+	Stream(ctx context.Context, hc *http.Client, req Request, onDelta func(string)) (*Response, error)
+	// ListModels queries the provider for the models available to apiKey.
+	ListModels(ctx context.Context, hc *http.Client, apiKey string) ([]string, error)
+	// Prepare returns the HTTP request Complete would send for req, so dry
+	// runs show exactly what goes over the wire.
+	Prepare(req Request) WireRequest
+}
+
+// WireRequest is the HTTP form of a completion request.
+type WireRequest struct {
+	URL     string
+	Headers map[string]string
+	Body    any // marshalled with encoding/json
+}
+
+// Capabilities describes optional backend features.
+type Capabilities struct {
+	Streaming  bool
+	ListModels bool
+}
+
+// Message is a single conversation turn.
+type Message struct {
+	Role    string `json:"role"` // "user" | "assistant"
+	Content string `json:"content"`
+}
+
+// Request is a provider-agnostic completion request.
+type Request struct {
+	Model     string
+	APIKey    string
+	System    string
+	Messages  []Message
+	MaxTokens int
+	// Temperature 0 leaves sampling to the backend's default.
+	Temperature float64
+	// Stream makes Prepare describe the request Stream sends.
+	Stream bool
+}
+
+// Usage holds token counts reported by the provider.
+type Usage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+// Response is a provider-agnostic completion response.
+type Response struct {
+	Text  string
+	Usage Usage
+}
+
+// ─── Registry ────────────────────────────────────────────────────────────────
+
+var (
+	registryMu sync.RWMutex
+	registry   []Backend
+)
+
+// Register adds a backend to the registry. It panics on a duplicate ID so
+// mistakes surface at startup rather than as a silently shadowed provider.
+func Register(b Backend) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	id := b.Info().ID
+	for _, existing := range registry {
+		if existing.Info().ID == id {
+			panic(fmt.Sprintf("ai: backend %q registered twice", id))
+		}
+	}
+	registry = append(registry, b)
+}
+
+// GetBackend returns the registered backend for a provider ID.
+func GetBackend(id string) (Backend, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	for _, b := range registry {
+		if b.Info().ID == id {
+			return b, true
+		}
+	}
+	return nil, false
+}
+
+// Backends returns all registered backends in registration order.
+func Backends() []Backend {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	out := make([]Backend, len(registry))
+	copy(out, registry)
+	return out
+}
+
+// Providers returns the metadata of every registered backend.
+func Providers() []Provider {
+	backends := Backends()
+	out := make([]Provider, len(backends))
+	for i, b := range backends {
+		out[i] = b.Info()
+	}
+	return out
+}
+
+var baseURLOverrides sync.Map // provider ID → base URL
+
+// SetBaseURL points a provider at a different API base URL, e.g. a
+// self-hosted Ollama or a test server. An empty url restores the default.
+func SetBaseURL(providerID, url string) {
+	if url == "" {
+		baseURLOverrides.Delete(providerID)
+		return
+	}
+	baseURLOverrides.Store(providerID, strings.TrimSuffix(url, "/"))
+}
+
+// baseURL returns the effective base URL for a provider.
+func baseURL(p Provider) string {
+	if u, ok := baseURLOverrides.Load(p.ID); ok {
+		return u.(string)
+	}
+	return p.BaseURL
+}
+
+// GetProvider returns a provider by ID.
+func GetProvider(id string) (Provider, bool) {
+	b, ok := GetBackend(id)
+	if !ok {
+		return Provider{}, false
+	}
+	return b.Info(), true
+}
@@ -0,0 +1,136 @@
+package ai
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// DryRun is the exact request a Client would send for a conversation,
+// built without contacting the provider.
+type DryRun struct {
+	Provider  string
+	Model     string
+	System    string
+	Messages  []Message
+	MaxTokens int
+	// Temperature is the one in the body; nil leaves it to the provider.
+	Temperature *float64
+	Method      string
+	URL         string
+	// Headers has secret values masked.
+	Headers map[string]string
+	// Body is the JSON body byte-for-byte as it would be sent.
+	Body []byte
+	// EstimatedInputTokens is a rough count (about four characters per
+	// token) of the system prompt and messages.
+	EstimatedInputTokens int
+	// EstimatedCostUSD is the input cost at list price; nil when the model
+	// has no known pricing.
+	EstimatedCostUSD *float64
+}
+
+// DryRun builds the request Converse would send for messages under the
+// client's system prompt.
+func (c *Client) DryRun(messages []Message) (*DryRun, error) {
+	backend, ok := GetBackend(c.ProviderID)
+	if !ok {
+		return nil, fmt.Errorf("unknown provider: %s", c.ProviderID)
+	}
+	req := c.request(c.system(), messages)
+	req.Stream = backend.Capabilities().Streaming
+	w := backend.Prepare(req)
+	body, err := json.Marshal(w.Body)
+	if err != nil {
+		return nil, err
+	}
+	headers := map[string]string{"Content-Type": "application/json"}
+	for k, v := range w.Headers {
+		headers[k] = maskHeader(k, v)
+	}
+
+	d := &DryRun{
+		Provider:  c.ProviderID,
+		Model:     c.Model,
+		System:    req.System,
+		Messages:  messages,
+		MaxTokens: req.MaxTokens,
+		Method:    http.MethodPost,
+		URL:       w.URL,
+		Headers:   headers,
+		Body:      body,
+	}
+	var wire struct {
+		Temperature *float64 `json:"temperature"`
+	}
+	_ = json.Unmarshal(body, &wire)
+	d.Temperature = wire.Temperature
+	d.EstimatedInputTokens = EstimateTokens(req.System)
+	for _, m := range messages {
+		d.EstimatedInputTokens += EstimateTokens(m.Content)
+	}
+	if prov, ok := GetProvider(c.ProviderID); ok {
+		if cost, ok := prov.Cost(c.Model, Usage{InputTokens: d.EstimatedInputTokens}); ok {
+			d.EstimatedCostUSD = &cost
+		}
+	}
+	return d, nil
+}
+
+// EstimateTokens approximates the token count of s at four characters per
+// token — close enough for English prose and code to budget a request.
+func EstimateTokens(s string) int {
+	return (len(s) + 3) / 4
+}
+
+var secretHeaderNames = map[string]bool{"authorization": true, "x-api-key": true, "api-key": true}
+
+func maskHeader(name, value string) string {
+	if !secretHeaderNames[strings.ToLower(name)] {
+		return value
+	}
+	scheme := ""
+	if s, rest, ok := strings.Cut(value, " "); ok && strings.EqualFold(s, "bearer") {
+		scheme, value = s+" ", rest
+	}
+	switch {
+	case value == "":
+		return scheme + "(not set)"
+	case len(value) <= 12:
+		return scheme + strings.Repeat("*", 8)
+	default:
+		return scheme + value[:4] + "…" + value[len(value)-4:]
+	}
+}
+
+// Format renders the dry run for humans: request line, headers, settings,
+// estimates and the full prompt.
+func (d *DryRun) Format() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s\n", d.Method, d.URL)
+	names := make([]string, 0, len(d.Headers))
+	for k := range d.Headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		fmt.Fprintf(&b, "%s: %s\n", k, d.Headers[k])
+	}
+	temp := "provider default"
+	if d.Temperature != nil {
+		temp = fmt.Sprintf("%g", *d.Temperature)
+	}
+	fmt.Fprintf(&b, "\nProvider    : %s\nModel       : %s\nMax tokens  : %d\nTemperature : %s\n", d.Provider, d.Model, d.MaxTokens, temp)
+	cost := "unknown (no pricing for model)"
+	if d.EstimatedCostUSD != nil {
+		cost = fmt.Sprintf("$%.4f (input only)", *d.EstimatedCostUSD)
+	}
+	fmt.Fprintf(&b, "Input tokens: ~%d\nEst. cost   : %s\nBody size   : %d bytes\n", d.EstimatedInputTokens, cost, len(d.Body))
+	fmt.Fprintf(&b, "\n── System prompt ──\n%s\n", d.System)
+	for _, m := range d.Messages {
+		fmt.Fprintf(&b, "\n── %s ──\n%s\n", m.Role, m.Content)
+	}
+	return b.String()
+}
@@ -0,0 +1,222 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+func init() {
+	Register(openAICompatBackend{info: Provider{
+		ID:           "openai",
+		Name:         "OpenAI",
+		Models:       []string{"gpt-4o", "gpt-4o-mini", "gpt-4-turbo", "o1", "o3-mini"},
+		DefaultModel: "gpt-4o",
+		EnvKey:       "OPENAI_API_KEY",
+		BaseURL:      "https://api.openai.com/v1",
+		Pricing: map[string]Price{
+			"gpt-4o":      {Input: 2.5, Output: 10},
+			"gpt-4o-mini": {Input: 0.15, Output: 0.6},
+			"gpt-4-turbo": {Input: 10, Output: 30},
+			"o1":          {Input: 15, Output: 60},
+			"o3-mini":     {Input: 1.1, Output: 4.4},
+		},
+	}, streamUsage: true})
+	Register(openAICompatBackend{info: Provider{
+		ID:           "groq",
+		Name:         "Groq",
+		Models:       []string{"llama-3.3-70b-versatile", "llama-3.1-8b-instant", "mixtral-8x7b-32768", "gemma2-9b-it"},
+		DefaultModel: "llama-3.3-70b-versatile",
+		EnvKey:       "GROQ_API_KEY",
+		BaseURL:      "https://api.groq.com/openai/v1",
+		Pricing: map[string]Price{
+			"llama-3.3-70b-versatile": {Input: 0.59, Output: 0.79},
+			"llama-3.1-8b-instant":    {Input: 0.05, Output: 0.08},
+			"mixtral-8x7b-32768":      {Input: 0.24, Output: 0.24},
+			"gemma2-9b-it":            {Input: 0.2, Output: 0.2},
+		},
+	}})
+	Register(openAICompatBackend{info: Provider{
+		ID:           "moonshot",
+		Name:         "Moonshot (Kimi)",
+		Models:       []string{"kimi-k2-0711-preview", "moonshot-v1-8k", "moonshot-v1-32k", "moonshot-v1-128k"},
+		DefaultModel: "kimi-k2-0711-preview",
+		EnvKey:       "MOONSHOT_API_KEY",
+		BaseURL:      "https://api.moonshot.cn/v1",
+		Pricing: map[string]Price{
+			"kimi-k2-0711-preview": {Input: 0.6, Output: 2.5},
+		},
+	}})
+	Register(openAICompatBackend{info: Provider{
+		ID:           "ollama",
+		Name:         "Ollama (Local)",
+		Models:       []string{"llama3.3", "llama3.1", "qwen2.5-coder", "mistral", "codellama", "phi4", "gemma3:1b"},
+		DefaultModel: "llama3.3",
+		EnvKey:       "",
+		BaseURL:      "http://localhost:11434/v1",
+	}})
+}
+
+// openAICompatBackend speaks the OpenAI chat completions API, which Groq,
+// Moonshot and Ollama also implement.
+type openAICompatBackend struct {
+	info Provider
+	// streamUsage asks for a final usage chunk when streaming. Not every
+	// compatible server understands stream_options, so it is opt-in.
+	streamUsage bool
+}
+
+func (b openAICompatBackend) Info() Provider { return b.info }
+
+func (b openAICompatBackend) Capabilities() Capabilities {
+	return Capabilities{Streaming: true, ListModels: true}
+}
+
+// openAITemperature is the temperature sent when the request leaves it
+// unset; these APIs always got one from task-agent.
+const openAITemperature = 0.7
+
+type openAIRequest struct {
+	Model         string               `json:"model"`
+	Messages      []Message            `json:"messages"`
+	MaxTokens     int                  `json:"max_tokens"`
+	Temperature   float64              `json:"temperature"`
+	Stream        bool                 `json:"stream,omitempty"`
+	StreamOptions *openAIStreamOptions `json:"stream_options,omitempty"`
+}
+
+type openAIStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+type openAIUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+type openAIResponse struct {
+	Choices []struct {
+		Message struct {
+			Content string `json:"content"`
+		} `json:"message"`
+	} `json:"choices"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+	Usage openAIUsage `json:"usage"`
+}
+
+func (b openAICompatBackend) headers(apiKey string) map[string]string {
+	// Local servers such as Ollama ignore the key but still expect the header.
+	if !b.info.RequiresKey() {
+		apiKey = b.info.ID
+	}
+	return map[string]string{"Authorization": "Bearer " + apiKey}
+}
+
+func (b openAICompatBackend) body(req Request, stream bool) openAIRequest {
+	msgs := make([]Message, 0, len(req.Messages)+1)
+	if req.System != "" {
+		msgs = append(msgs, Message{Role: "system", Content: req.System})
+	}
+	msgs = append(msgs, req.Messages...)
+	body := openAIRequest{
+		Model:       req.Model,
+		Messages:    msgs,
+		MaxTokens:   req.MaxTokens,
+		Temperature: req.Temperature,
+		Stream:      stream,
+	}
+	if body.Temperature == 0 {
+		body.Temperature = openAITemperature
+	}
+	if stream && b.streamUsage {
+		body.StreamOptions = &openAIStreamOptions{IncludeUsage: true}
+	}
+	return body
+}
+
+func (b openAICompatBackend) Prepare(req Request) WireRequest {
+	return WireRequest{URL: baseURL(b.info) + "/chat/completions", Headers: b.headers(req.APIKey), Body: b.body(req, req.Stream)}
+}
+
+func (b openAICompatBackend) Complete(ctx context.Context, hc *http.Client, req Request) (*Response, error) {
+	w := b.Prepare(req)
+	resp, err := postJSON(ctx, hc, w.URL, w.Headers, w.Body)
+	if err != nil {
+		return nil, err
+	}
+	var or openAIResponse
+	if err := json.Unmarshal(resp, &or); err != nil {
+		return nil, fmt.Errorf("unmarshal %s response: %w", b.info.ID, err)
+	}
+	if or.Error != nil {
+		return nil, fmt.Errorf("%s API: %s", b.info.Name, or.Error.Message)
+	}
+	if len(or.Choices) == 0 {
+		return nil, fmt.Errorf("empty response from %s", b.info.Name)
+	}
+	return &Response{
+		Text:  or.Choices[0].Message.Content,
+		Usage: Usage{InputTokens: or.Usage.PromptTokens, OutputTokens: or.Usage.CompletionTokens},
+	}, nil
+}
+
+type openAIStreamChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+	} `json:"choices"`
+	Usage *openAIUsage `json:"usage"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (b openAICompatBackend) Stream(ctx context.Context, hc *http.Client, req Request, onDelta func(string)) (*Response, error) {
+	out := &Response{}
+	var text []byte
+	err := postSSE(ctx, hc, baseURL(b.info)+"/chat/completions", b.headers(req.APIKey), b.body(req, true), func(data []byte) error {
+		if string(data) == "[DONE]" {
+			return nil
+		}
+		var chunk openAIStreamChunk
+		if err := json.Unmarshal(data, &chunk); err != nil {
+			return fmt.Errorf("unmarshal %s stream chunk: %w", b.info.ID, err)
+		}
+		if chunk.Error != nil {
+			return fmt.Errorf("%s API: %s", b.info.Name, chunk.Error.Message)
+		}
+		if chunk.Usage != nil {
+			out.Usage = Usage{InputTokens: chunk.Usage.PromptTokens, OutputTokens: chunk.Usage.CompletionTokens}
+		}
+		for _, c := range chunk.Choices {
+			if c.Delta.Content == "" {
+				continue
+			}
+			text = append(text, c.Delta.Content...)
+			if onDelta != nil {
+				onDelta(c.Delta.Content)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(text) == 0 {
+		return nil, fmt.Errorf("empty response from %s", b.info.Name)
+	}
+	out.Text = string(text)
+	return out, nil
+}
+
+func (b openAICompatBackend) ListModels(ctx context.Context, hc *http.Client, apiKey string) ([]string, error) {
+	resp, err := getJSON(ctx, hc, baseURL(b.info)+"/models", b.headers(apiKey))
+	if err != nil {
+		return nil, err
+	}
+	return parseModelList(resp)
+}
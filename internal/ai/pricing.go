@@ -0,0 +1,64 @@
+package ai
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Price is a model's list price in USD per million tokens.
+type Price struct {
+	Input  float64
+	Output float64
+}
+
+// Cost estimates the USD cost of usage on model. Providers that need no API
+// key run locally and are always free.
+func (p Provider) Cost(model string, u Usage) (float64, bool) {
+	if !p.RequiresKey() {
+		return 0, true
+	}
+	price, ok := p.Pricing[model]
+	if !ok {
+		return 0, false
+	}
+	return (float64(u.InputTokens)*price.Input + float64(u.OutputTokens)*price.Output) / 1e6, true
+}
+
+// ModelRef names a model on a specific provider, written "provider/model".
+type ModelRef struct {
+	Provider string
+	Model    string
+}
+
+func (r ModelRef) String() string {
+	return r.Provider + "/" + r.Model
+}
+
+// ParseModelRef parses "provider/model". The model part may itself contain
+// slashes or colons (e.g. "ollama/gemma3:1b").
+func ParseModelRef(s string) (ModelRef, error) {
+	prov, model, ok := strings.Cut(strings.TrimSpace(s), "/")
+	if !ok || prov == "" || model == "" {
+		return ModelRef{}, fmt.Errorf("invalid model %q — want provider/model", s)
+	}
+	if _, ok := GetProvider(prov); !ok {
+		return ModelRef{}, fmt.Errorf("unknown provider %q in %q", prov, s)
+	}
+	return ModelRef{Provider: prov, Model: model}, nil
+}
+
+// ParseModelRefs parses a comma-separated list of provider/model refs.
+func ParseModelRefs(list string) ([]ModelRef, error) {
+	var refs []ModelRef
+	for _, part := range strings.Split(list, ",") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		ref, err := ParseModelRef(part)
+		if err != nil {
+			return nil, err
+		}
+		refs = append(refs, ref)
+	}
+	return refs, nil
+}
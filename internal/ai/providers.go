@@ -2,11 +2,14 @@
 package ai
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"sort"
 	"strings"
 	"time"
 )
@@ -19,60 +22,15 @@ type Provider struct {
 	DefaultModel string
 	EnvKey       string
 	BaseURL      string
+	// Pricing maps model name to list price; models without an entry have
+	// unknown cost.
+	Pricing map[string]Price
 }
 
-// Providers lists all registered providers.
-var Providers = []Provider{
-	{
-		ID:           "anthropic",
-		Name:         "Anthropic",
-		Models:       []string{"claude-opus-4-6", "claude-sonnet-4-6", "claude-haiku-4-5-20251001"},
-		DefaultModel: "claude-sonnet-4-6",
-		EnvKey:       "ANTHROPIC_API_KEY",
-		BaseURL:      "https://api.anthropic.com/v1",
-	},
-	{
-		ID:           "openai",
-		Name:         "OpenAI",
-		Models:       []string{"gpt-4o", "gpt-4o-mini", "gpt-4-turbo", "o1", "o3-mini"},
-		DefaultModel: "gpt-4o",
-		EnvKey:       "OPENAI_API_KEY",
-		BaseURL:      "https://api.openai.com/v1",
-	},
-	{
-		ID:           "groq",
-		Name:         "Groq",
-		Models:       []string{"llama-3.3-70b-versatile", "llama-3.1-8b-instant", "mixtral-8x7b-32768", "gemma2-9b-it"},
-		DefaultModel: "llama-3.3-70b-versatile",
-		EnvKey:       "GROQ_API_KEY",
-		BaseURL:      "https://api.groq.com/openai/v1",
-	},
-	{
-		ID:           "moonshot",
-		Name:         "Moonshot (Kimi)",
-		Models:       []string{"kimi-k2-0711-preview", "moonshot-v1-8k", "moonshot-v1-32k", "moonshot-v1-128k"},
-		DefaultModel: "kimi-k2-0711-preview",
-		EnvKey:       "MOONSHOT_API_KEY",
-		BaseURL:      "https://api.moonshot.cn/v1",
-	},
-	{
-		ID:           "ollama",
-		Name:         "Ollama (Local)",
-		Models:       []string{"llama3.3", "llama3.1", "qwen2.5-coder", "mistral", "codellama", "phi4","gemma3:1b"},
-		DefaultModel: "llama3.3",
-		EnvKey:       "",
-		BaseURL:      "http://localhost:11434/v1",
-	},
-}
-
-// GetProvider returns a provider by ID.
-func GetProvider(id string) (Provider, bool) {
-	for _, p := range Providers {
-		if p.ID == id {
-			return p, true
-		}
-	}
-	return Provider{}, false
+// RequiresKey reports whether the provider needs an API key. Local
+// providers such as Ollama leave EnvKey empty.
+func (p Provider) RequiresKey() bool {
+	return p.EnvKey != ""
 }
 
 // OutputFile is a single file produced by the AI.
@@ -80,8 +38,26 @@ type OutputFile struct {
 	Path        string `json:"path"`
 	Content     string `json:"content"`
 	Description string `json:"description"`
+	// Encoding is "utf-8" (the default when empty) or "base64" for binary
+	// content such as images.
+	Encoding string `json:"encoding,omitempty"`
+	// Mode is "executable" for scripts, or an octal permission such as
+	// "0755". Empty means a regular 0644 file.
+	Mode string `json:"mode,omitempty"`
 }
 
+// File encodings.
+const (
+	EncodingUTF8   = "utf-8"
+	EncodingBase64 = "base64"
+)
+
+// ModeExecutable marks an OutputFile as an executable script.
+const ModeExecutable = "executable"
+
+// Binary reports whether the file's content is base64-encoded.
+func (f OutputFile) Binary() bool { return strings.EqualFold(f.Encoding, EncodingBase64) }
+
 // TaskResult is the structured response from the AI.
 type TaskResult struct {
 	OutputType string       `json:"output_type"` // "markdown" | "code_folder" | "mixed"
@@ -103,6 +79,18 @@ Respond ONLY with a valid JSON object (no markdown fences, no preamble):
       "path": "relative/path/to/file.ext",
       "content": "full file content here",
       "description": "what this file does"
+    },
+    {
+      "path": "scripts/setup.sh",
+      "content": "#!/bin/sh\n...",
+      "description": "a script meant to be run directly",
+      "mode": "executable"
+    },
+    {
+      "path": "assets/logo.png",
+      "content": "base64-encoded bytes",
+      "description": "a binary file",
+      "encoding": "base64"
     }
   ],
   "notes": "Any important notes, caveats, or follow-up suggestions"
@@ -113,35 +101,107 @@ Respond ONLY with a valid JSON object (no markdown fences, no preamble):
 - For code tasks: include tests, a README, and proper project structure
 - For writing tasks: produce publication-ready content
 - Make reasonable assumptions and document them in "notes"
-- ALWAYS produce actual file content, never just describe what to do`
+- ALWAYS produce actual file content, never just describe what to do
+- "encoding" and "mode" are optional. Use "encoding": "base64" only for binary files
+  such as PNG images, with the base64 bytes in "content"; text files stay plain UTF-8.
+  Use "mode": "executable" for scripts meant to be run directly (include a shebang).
+- Paths must be relative and stay inside the output folder; files over 10 MB are dropped`
+
+// SystemPrompt returns the default YOLO system prompt.
+func SystemPrompt() string { return yoloSystemPrompt }
 
 // Client sends tasks to AI providers.
 type Client struct {
 	ProviderID string
 	Model      string
 	APIKey     string
+	// System overrides the YOLO system prompt, e.g. to resume a conversation
+	// recorded under an older prompt.
+	System     string
 	httpClient *http.Client
 }
 
+// defaultTransport is used by every Client created after SetTransport; nil
+// means http.DefaultTransport.
+var defaultTransport http.RoundTripper
+
+// SetTransport routes the HTTP traffic of subsequently created clients
+// through rt — e.g. a cassette recorder or replayer.
+func SetTransport(rt http.RoundTripper) {
+	defaultTransport = rt
+}
+
+// Replayer is implemented by transports that answer from recordings instead
+// of calling the provider, such as a cassette in replay mode. Clients using
+// one need no API key.
+type Replayer interface {
+	Replaying() bool
+}
+
 // NewClient creates a new AI client.
 func NewClient(providerID, model, apiKey string) *Client {
 	return &Client{
 		ProviderID: providerID,
 		Model:      model,
 		APIKey:     apiKey,
-		httpClient: &http.Client{Timeout: 180 * time.Second},
+		httpClient: &http.Client{Timeout: 180 * time.Second, Transport: defaultTransport},
+	}
+}
+
+// Execution records a single task run: the parsed result plus the usage and
+// timing needed to compare runs.
+type Execution struct {
+	ProviderID string
+	Model      string
+	Result     *TaskResult
+	Raw        string
+	Usage      Usage
+	Latency    time.Duration
+	// ParseErr is set when the response was not valid JSON and Result holds
+	// the raw-markdown fallback.
+	ParseErr error
+	// Messages is the conversation so far, ending with the model's reply.
+	Messages []Message
+}
+
+// Cost returns the estimated USD cost of the run and whether the model has
+// known pricing.
+func (e *Execution) Cost() (float64, bool) {
+	prov, ok := GetProvider(e.ProviderID)
+	if !ok {
+		return 0, false
 	}
+	return prov.Cost(e.Model, e.Usage)
 }
 
 // ExecuteTask sends a task to the AI and returns structured output.
 // The progress func is called with status strings during execution — these
 // are sent into the TUI live log via a channel.
 func (c *Client) ExecuteTask(taskMarkdown string, progress func(string)) (*TaskResult, error) {
-	userContent := fmt.Sprintf(
+	exec, err := c.Run(context.Background(), taskMarkdown, progress)
+	if err != nil {
+		return nil, err
+	}
+	return exec.Result, nil
+}
+
+// Run is ExecuteTask with cancellation and the full Execution record.
+func (c *Client) Run(ctx context.Context, taskMarkdown string, progress func(string)) (*Execution, error) {
+	return c.Converse(ctx, []Message{TaskMessage(taskMarkdown)}, progress)
+}
+
+// TaskMessage is the opening user turn for a task.
+func TaskMessage(taskMarkdown string) Message {
+	return Message{Role: "user", Content: fmt.Sprintf(
 		"## Asana Task\n\n%s\n\nExecute this task completely. Return valid JSON as specified.",
 		taskMarkdown,
-	)
+	)}
+}
 
+// Converse sends a task conversation under the YOLO system prompt and parses
+// the reply as a TaskResult. Follow-up turns (fix requests, chat) append to
+// the Messages of a previous Execution.
+func (c *Client) Converse(ctx context.Context, messages []Message, progress func(string)) (*Execution, error) {
 	emit := func(s string) {
 		if progress != nil {
 			progress(s)
@@ -150,188 +210,238 @@ func (c *Client) ExecuteTask(taskMarkdown string, progress func(string)) (*TaskR
 
 	emit(fmt.Sprintf("Calling %s / %s…", c.ProviderID, c.Model))
 
-	var (
-		raw string
-		err error
-	)
-
-	switch c.ProviderID {
-	case "anthropic":
-		raw, err = c.callAnthropic(userContent, emit)
-	default:
-		raw, err = c.callOpenAICompat(userContent, emit)
+	if prov, ok := GetProvider(c.ProviderID); ok {
+		emit(fmt.Sprintf("Sending request to %s…", prov.Name))
 	}
+	start := time.Now()
+	resp, err := c.stream(ctx, c.system(), messages, emit)
 	if err != nil {
 		return nil, err
 	}
+	exec := &Execution{
+		ProviderID: c.ProviderID,
+		Model:      c.Model,
+		Raw:        resp.Text,
+		Usage:      resp.Usage,
+		Latency:    time.Since(start),
+		Messages:   append(append([]Message(nil), messages...), Message{Role: "assistant", Content: resp.Text}),
+	}
+	emit(fmt.Sprintf("Received %d input / %d output tokens", resp.Usage.InputTokens, resp.Usage.OutputTokens))
 
 	emit("Parsing response…")
-	result, parseErr := parseResult(raw)
-	if parseErr == nil {
-		emit(fmt.Sprintf("Got %d file(s) — output type: %s", len(result.Files), result.OutputType))
+	exec.Result, exec.ParseErr = ParseResult(resp.Text)
+	if exec.ParseErr != nil {
+		exec.Result = rawFallback(resp.Text, exec.ParseErr)
 	}
-	return result, parseErr
+	emit(fmt.Sprintf("Got %d file(s) — output type: %s", len(exec.Result.Files), exec.Result.OutputType))
+	return exec, nil
 }
 
-// ─── Anthropic ───────────────────────────────────────────────────────────────
-
-type anthropicRequest struct {
-	Model     string             `json:"model"`
-	MaxTokens int                `json:"max_tokens"`
-	System    string             `json:"system"`
-	Messages  []anthropicMessage `json:"messages"`
+// Complete sends an arbitrary conversation to the client's provider and
+// returns the raw response.
+func (c *Client) Complete(ctx context.Context, system string, messages []Message) (*Response, error) {
+	backend, ok := GetBackend(c.ProviderID)
+	if !ok {
+		return nil, fmt.Errorf("unknown provider: %s", c.ProviderID)
+	}
+	if err := c.checkKey(backend.Info()); err != nil {
+		return nil, err
+	}
+	return backend.Complete(ctx, c.httpClient, c.request(system, messages))
 }
 
-type anthropicMessage struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
-}
+// streamReportEvery is how many bytes of a streamed reply arrive between
+// progress lines.
+const streamReportEvery = 4096
 
-type anthropicResponse struct {
-	Content []struct {
-		Type string `json:"type"`
-		Text string `json:"text"`
-	} `json:"content"`
-	Error *struct {
-		Message string `json:"message"`
-	} `json:"error"`
-	Usage struct {
-		InputTokens  int `json:"input_tokens"`
-		OutputTokens int `json:"output_tokens"`
-	} `json:"usage"`
+// stream is Complete through the backend's streaming API, when it has one,
+// reporting progress through emit as the reply arrives.
+func (c *Client) stream(ctx context.Context, system string, messages []Message, emit func(string)) (*Response, error) {
+	backend, ok := GetBackend(c.ProviderID)
+	if !ok {
+		return nil, fmt.Errorf("unknown provider: %s", c.ProviderID)
+	}
+	if err := c.checkKey(backend.Info()); err != nil {
+		return nil, err
+	}
+	req := c.request(system, messages)
+	if !backend.Capabilities().Streaming {
+		return backend.Complete(ctx, c.httpClient, req)
+	}
+	received, reported := 0, 0
+	return backend.Stream(ctx, c.httpClient, req, func(delta string) {
+		received += len(delta)
+		if received-reported >= streamReportEvery {
+			reported = received
+			emit(fmt.Sprintf("Receiving… %d KB so far", received/1024))
+		}
+	})
 }
 
-func (c *Client) callAnthropic(userContent string, progress func(string)) (string, error) {
-	progress("Sending request to Anthropic…")
-	body := anthropicRequest{
-		Model:     c.Model,
-		MaxTokens: 8192,
-		System:    yoloSystemPrompt,
-		Messages:  []anthropicMessage{{Role: "user", Content: userContent}},
+// ListModels asks the provider which models the client's key can use.
+func (c *Client) ListModels(ctx context.Context) ([]string, error) {
+	backend, ok := GetBackend(c.ProviderID)
+	if !ok {
+		return nil, fmt.Errorf("unknown provider: %s", c.ProviderID)
 	}
-	resp, err := c.post("https://api.anthropic.com/v1/messages", map[string]string{
-		"x-api-key":         c.APIKey,
-		"anthropic-version": "2023-06-01",
-	}, body)
-	if err != nil {
-		return "", err
+	if !backend.Capabilities().ListModels {
+		return nil, fmt.Errorf("%s cannot list its models", backend.Info().Name)
 	}
-	var ar anthropicResponse
-	if err := json.Unmarshal(resp, &ar); err != nil {
-		return "", fmt.Errorf("unmarshal anthropic response: %w", err)
+	if err := c.checkKey(backend.Info()); err != nil {
+		return nil, err
 	}
-	if ar.Error != nil {
-		return "", fmt.Errorf("Anthropic API: %s", ar.Error.Message)
+	return backend.ListModels(ctx, c.httpClient, c.APIKey)
+}
+
+// checkKey fails a request that would go out without the key prov needs.
+// Replayed traffic needs none.
+func (c *Client) checkKey(prov Provider) error {
+	if !prov.RequiresKey() || c.APIKey != "" {
+		return nil
 	}
-	if len(ar.Content) == 0 {
-		return "", fmt.Errorf("empty response from Anthropic")
+	if r, ok := c.httpClient.Transport.(Replayer); ok && r.Replaying() {
+		return nil
 	}
-	progress(fmt.Sprintf("Received %d input / %d output tokens", ar.Usage.InputTokens, ar.Usage.OutputTokens))
-	return ar.Content[0].Text, nil
+	return fmt.Errorf("no API key for %s — set %s or api_keys.%s", prov.Name, prov.EnvKey, prov.ID)
 }
 
-// ─── OpenAI-compatible (OpenAI, Groq, Ollama) ────────────────────────────────
-
-type openAIRequest struct {
-	Model       string          `json:"model"`
-	Messages    []openAIMessage `json:"messages"`
-	MaxTokens   int             `json:"max_tokens"`
-	Temperature float64         `json:"temperature"`
+func (c *Client) request(system string, messages []Message) Request {
+	return Request{
+		Model:     c.Model,
+		APIKey:    c.APIKey,
+		System:    system,
+		Messages:  messages,
+		MaxTokens: 8192,
+	}
 }
 
-type openAIMessage struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+func (c *Client) system() string {
+	if c.System != "" {
+		return c.System
+	}
+	return yoloSystemPrompt
 }
 
-type openAIResponse struct {
-	Choices []struct {
-		Message struct {
-			Content string `json:"content"`
-		} `json:"message"`
-	} `json:"choices"`
-	Error *struct {
-		Message string `json:"message"`
-	} `json:"error"`
-	Usage struct {
-		PromptTokens     int `json:"prompt_tokens"`
-		CompletionTokens int `json:"completion_tokens"`
-	} `json:"usage"`
-}
+// ─── HTTP helpers ────────────────────────────────────────────────────────────
 
-func (c *Client) callOpenAICompat(userContent string, progress func(string)) (string, error) {
-	prov, ok := GetProvider(c.ProviderID)
-	if !ok {
-		return "", fmt.Errorf("unknown provider: %s", c.ProviderID)
-	}
-	progress(fmt.Sprintf("Sending request to %s…", prov.Name))
-	body := openAIRequest{
-		Model: c.Model,
-		Messages: []openAIMessage{
-			{Role: "system", Content: yoloSystemPrompt},
-			{Role: "user", Content: userContent},
-		},
-		MaxTokens:   8192,
-		Temperature: 0.7,
-	}
-	apiKey := c.APIKey
-	if c.ProviderID == "ollama" {
-		apiKey = "ollama"
-	}
-	resp, err := c.post(prov.BaseURL+"/chat/completions", map[string]string{
-		"Authorization": "Bearer " + apiKey,
-	}, body)
+func newRequest(ctx context.Context, method, url string, headers map[string]string, body any) (*http.Request, error) {
+	var rd io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		rd = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, rd)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	var or openAIResponse
-	if err := json.Unmarshal(resp, &or); err != nil {
-		return "", fmt.Errorf("unmarshal %s response: %w", c.ProviderID, err)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
 	}
-	if or.Error != nil {
-		return "", fmt.Errorf("%s API: %s", prov.Name, or.Error.Message)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	return req, nil
+}
+
+func do(hc *http.Client, req *http.Request) (*http.Response, error) {
+	resp, err := hc.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("HTTP request to %s: %w", req.URL, err)
 	}
-	if len(or.Choices) == 0 {
-		return "", fmt.Errorf("empty response from %s", prov.Name)
+	if resp.StatusCode >= 400 {
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("HTTP %d from %s: %s", resp.StatusCode, req.URL, string(b))
 	}
-	progress(fmt.Sprintf("Received %d prompt / %d completion tokens",
-		or.Usage.PromptTokens, or.Usage.CompletionTokens))
-	return or.Choices[0].Message.Content, nil
+	return resp, nil
 }
 
-// ─── HTTP helper ─────────────────────────────────────────────────────────────
+func doJSON(hc *http.Client, req *http.Request) ([]byte, error) {
+	resp, err := do(hc, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
 
-func (c *Client) post(url string, extraHeaders map[string]string, body any) ([]byte, error) {
-	data, err := json.Marshal(body)
+func postJSON(ctx context.Context, hc *http.Client, url string, headers map[string]string, body any) ([]byte, error) {
+	req, err := newRequest(ctx, http.MethodPost, url, headers, body)
 	if err != nil {
 		return nil, err
 	}
-	req, err := http.NewRequest("POST", url, bytes.NewReader(data))
+	return doJSON(hc, req)
+}
+
+func getJSON(ctx context.Context, hc *http.Client, url string, headers map[string]string) ([]byte, error) {
+	req, err := newRequest(ctx, http.MethodGet, url, headers, nil)
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Set("Content-Type", "application/json")
-	for k, v := range extraHeaders {
-		req.Header.Set(k, v)
+	return doJSON(hc, req)
+}
+
+// postSSE posts body and calls onData with the payload of every server-sent
+// "data:" line until the stream ends.
+func postSSE(ctx context.Context, hc *http.Client, url string, headers map[string]string, body any, onData func([]byte) error) error {
+	req, err := newRequest(ctx, http.MethodPost, url, headers, body)
+	if err != nil {
+		return err
 	}
-	resp, err := c.httpClient.Do(req)
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err := do(hc, req)
 	if err != nil {
-		return nil, fmt.Errorf("HTTP request to %s: %w", url, err)
+		return err
 	}
 	defer resp.Body.Close()
-	b, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
+	sc := bufio.NewScanner(resp.Body)
+	sc.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for sc.Scan() {
+		line := sc.Bytes()
+		if !bytes.HasPrefix(line, []byte("data:")) {
+			continue
+		}
+		data := bytes.TrimSpace(line[len("data:"):])
+		if len(data) == 0 {
+			continue
+		}
+		if err := onData(data); err != nil {
+			return err
+		}
 	}
-	if resp.StatusCode >= 400 {
-		return nil, fmt.Errorf("HTTP %d from %s: %s", resp.StatusCode, url, string(b))
+	if err := sc.Err(); err != nil {
+		return fmt.Errorf("reading stream from %s: %w", url, err)
+	}
+	return nil
+}
+
+// parseModelList decodes the {"data":[{"id":...}]} shape shared by the
+// Anthropic and OpenAI model listing endpoints, sorted by ID.
+func parseModelList(data []byte) ([]string, error) {
+	var list struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("unmarshal model list: %w", err)
 	}
-	return b, nil
+	models := make([]string, 0, len(list.Data))
+	for _, m := range list.Data {
+		models = append(models, m.ID)
+	}
+	sort.Strings(models)
+	return models, nil
 }
 
 // ─── Response parser ─────────────────────────────────────────────────────────
 
-func parseResult(raw string) (*TaskResult, error) {
+// ParseResult strips markdown fences and unmarshals the model's JSON, e.g.
+// a reply recorded in a conversation.
+func ParseResult(raw string) (*TaskResult, error) {
 	text := strings.TrimSpace(raw)
 	// Strip markdown code fences
 	if strings.HasPrefix(text, "```") {
@@ -344,13 +454,17 @@ func parseResult(raw string) (*TaskResult, error) {
 	}
 	var result TaskResult
 	if err := json.Unmarshal([]byte(text), &result); err != nil {
-		// Fallback: wrap raw as markdown
-		return &TaskResult{
-			OutputType: "markdown",
-			Summary:    "AI response (raw — JSON parsing failed)",
-			Files:      []OutputFile{{Path: "output.md", Content: raw, Description: "Raw AI output"}},
-			Notes:      fmt.Sprintf("JSON parse error: %v", err),
-		}, nil
+		return nil, err
 	}
 	return &result, nil
-}
\ No newline at end of file
+}
+
+// rawFallback wraps an unparseable response as a single markdown file.
+func rawFallback(raw string, err error) *TaskResult {
+	return &TaskResult{
+		OutputType: "markdown",
+		Summary:    "AI response (raw — JSON parsing failed)",
+		Files:      []OutputFile{{Path: "output.md", Content: raw, Description: "Raw AI output"}},
+		Notes:      fmt.Sprintf("JSON parse error: %v", err),
+	}
+}
@@ -0,0 +1,151 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/thecoolrobot/task-agent/internal/cassette"
+)
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+// TestExecuteTaskReplay records a canned Anthropic response, then replays it
+// with no upstream at all.
+func TestExecuteTaskReplay(t *testing.T) {
+	dir := t.TempDir()
+	defer SetTransport(nil)
+
+	rec := cassette.New(cassette.Record, dir)
+	rec.Next = roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		body := `data: {"type":"message_start","message":{"usage":{"input_tokens":10}}}
+
+data: {"type":"content_block_delta","delta":{"type":"text_delta","text":"{\"output_type\":\"markdown\",\"summary\":\"did it\","}}
+
+data: {"type":"content_block_delta","delta":{"type":"text_delta","text":"\"files\":[{\"path\":\"a.md\",\"content\":\"# A\",\"description\":\"doc\"}],\"notes\":\"\"}"}}
+
+data: {"type":"message_delta","usage":{"output_tokens":20}}
+
+`
+		return &http.Response{StatusCode: 200, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(body)), Request: r}, nil
+	})
+	SetTransport(rec)
+	if _, err := NewClient("anthropic", "claude-sonnet-4-6", "sk-test").ExecuteTask("# Task: demo", nil); err != nil {
+		t.Fatalf("record: %v", err)
+	}
+
+	SetTransport(cassette.New(cassette.Replay, dir))
+	exec, err := NewClient("anthropic", "claude-sonnet-4-6", "").Run(context.Background(), "# Task: demo", nil)
+	if err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	if exec.ParseErr != nil {
+		t.Fatalf("parse: %v", exec.ParseErr)
+	}
+	if exec.Result.Summary != "did it" || len(exec.Result.Files) != 1 || exec.Result.Files[0].Path != "a.md" {
+		t.Errorf("unexpected result: %+v", exec.Result)
+	}
+	if exec.Usage.OutputTokens != 20 {
+		t.Errorf("usage not carried through: %+v", exec.Usage)
+	}
+}
+
+func TestDecodeResultFallback(t *testing.T) {
+	if _, err := ParseResult("not json"); err == nil {
+		t.Fatal("want error for non-JSON response")
+	}
+	r, err := ParseResult("```json\n{\"summary\":\"fenced\"}\n```")
+	if err != nil || r.Summary != "fenced" {
+		t.Fatalf("fenced JSON: %v %+v", err, r)
+	}
+}
+
+// TestDryRunMatchesWire checks the dry-run body is byte-for-byte what a real
+// call sends, and that the key is masked.
+func TestDryRunMatchesWire(t *testing.T) {
+	defer SetTransport(nil)
+	for _, id := range []string{"anthropic", "openai"} {
+		var sent []byte
+		SetTransport(roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			sent, _ = io.ReadAll(r.Body)
+			return &http.Response{StatusCode: 500, Header: http.Header{}, Body: io.NopCloser(strings.NewReader("{}")), Request: r}, nil
+		}))
+		c := NewClient(id, "some-model", "sk-secret-key-123456")
+		msgs := []Message{TaskMessage("# Task: demo")}
+		d, err := c.DryRun(msgs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = c.Converse(context.Background(), msgs, nil)
+		if string(d.Body) != string(sent) {
+			t.Errorf("%s: dry-run body differs from wire body:\n%s\n%s", id, d.Body, sent)
+		}
+		for k, v := range d.Headers {
+			if strings.Contains(v, "secret-key") {
+				t.Errorf("%s: header %s not masked: %s", id, k, v)
+			}
+		}
+		if d.EstimatedInputTokens == 0 || !strings.Contains(d.Format(), "# Task: demo") {
+			t.Errorf("%s: incomplete dry run: %+v", id, d)
+		}
+	}
+}
+
+// TestStreamProgress checks task replies are streamed with progress lines
+// and that a backend's temperature default is what goes on the wire.
+func TestStreamProgress(t *testing.T) {
+	defer SetTransport(nil)
+	big := strings.Repeat("x", 3*streamReportEvery)
+	for _, tt := range []struct {
+		id, body string
+		temp     any
+	}{
+		{"anthropic", `data: {"type":"content_block_delta","delta":{"type":"text_delta","text":"` + big + `"}}` + "\n\n", nil},
+		{"openai", `data: {"choices":[{"delta":{"content":"` + big + `"}}]}` + "\n\ndata: [DONE]\n\n", 0.7},
+	} {
+		var wire map[string]any
+		SetTransport(roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			data, _ := io.ReadAll(r.Body)
+			_ = json.Unmarshal(data, &wire)
+			return &http.Response{StatusCode: 200, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(tt.body)), Request: r}, nil
+		}))
+		var progress []string
+		exec, err := NewClient(tt.id, "some-model", "sk-test").Run(context.Background(), "# Task: demo", func(s string) {
+			progress = append(progress, s)
+		})
+		if err != nil {
+			t.Fatalf("%s: %v", tt.id, err)
+		}
+		if exec.Raw != big || wire["stream"] != true || wire["temperature"] != tt.temp {
+			t.Errorf("%s: raw %d bytes, wire stream=%v temperature=%v", tt.id, len(exec.Raw), wire["stream"], wire["temperature"])
+		}
+		if !strings.Contains(strings.Join(progress, "\n"), "Receiving… 12 KB so far") {
+			t.Errorf("%s: progress %q", tt.id, progress)
+		}
+	}
+}
+
+func TestListModels(t *testing.T) {
+	defer SetTransport(nil)
+	var got *http.Request
+	SetTransport(roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		got = r
+		body := `{"data":[{"id":"model-b"},{"id":"model-a"}]}`
+		return &http.Response{StatusCode: 200, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(body)), Request: r}, nil
+	}))
+	models, err := NewClient("openai", "", "sk-test").ListModels(context.Background())
+	if err != nil || strings.Join(models, ",") != "model-a,model-b" {
+		t.Fatalf("models = %v, %v", models, err)
+	}
+	if got.Method != http.MethodGet || !strings.HasSuffix(got.URL.Path, "/models") || got.Header.Get("Authorization") != "Bearer sk-test" {
+		t.Errorf("request = %s %s %v", got.Method, got.URL, got.Header)
+	}
+	if _, err := NewClient("openai", "", "").ListModels(context.Background()); err == nil || !strings.Contains(err.Error(), "no API key") {
+		t.Errorf("without a key: %v", err)
+	}
+}
@@ -163,6 +163,9 @@ func (c *Client) ViewTask(taskGID string) (*Task, error) {
 	if err := json.Unmarshal(data, &resp); err != nil {
 		return nil, err
 	}
+	if !resp.Success {
+		return nil, fmt.Errorf("asana-cli: %s", resp.Error)
+	}
 	var task Task
 	if err := json.Unmarshal(resp.Data, &task); err != nil {
 		return nil, err
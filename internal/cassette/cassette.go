@@ -0,0 +1,221 @@
+// Package cassette records HTTP request/response pairs to disk and replays
+// them, so AI flows can run deterministically offline and in CI.
+package cassette
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Mode selects whether a Transport records or replays.
+type Mode int
+
+const (
+	Record Mode = iota
+	Replay
+)
+
+// Redacted replaces secret header and query values in recordings.
+const Redacted = "REDACTED"
+
+// secretHeaders are never written to disk.
+var secretHeaders = []string{"Authorization", "X-Api-Key", "Api-Key", "Cookie", "Set-Cookie"}
+
+// secretParams are redacted from recorded URLs.
+var secretParams = []string{"key", "api_key", "token"}
+
+// Interaction is one recorded request/response pair.
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// RecordedRequest is the redacted request as sent.
+type RecordedRequest struct {
+	Method  string      `json:"method"`
+	URL     string      `json:"url"`
+	Headers http.Header `json:"headers"`
+	Body    string      `json:"body"`
+}
+
+// RecordedResponse is the response as received.
+type RecordedResponse struct {
+	Status  int         `json:"status"`
+	Headers http.Header `json:"headers"`
+	Body    string      `json:"body"`
+}
+
+// Transport is an http.RoundTripper that records to or replays from Dir.
+// Requests are matched on method, redacted URL and body; identical requests
+// are numbered so repeated calls replay in the order they were recorded.
+type Transport struct {
+	Mode Mode
+	Dir  string
+	// Next performs real requests in Record mode (default
+	// http.DefaultTransport).
+	Next http.RoundTripper
+
+	mu    sync.Mutex
+	calls map[string]int
+}
+
+// New returns a Transport for dir in the given mode.
+func New(mode Mode, dir string) *Transport {
+	return &Transport{Mode: mode, Dir: dir}
+}
+
+// Replaying reports whether t answers from recordings, so callers can skip
+// the API key checks a live provider needs.
+func (t *Transport) Replaying() bool { return t.Mode == Replay }
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	redactedURL := redactURL(req.URL)
+	key := requestKey(req.Method, redactedURL, body)
+
+	t.mu.Lock()
+	if t.calls == nil {
+		t.calls = map[string]int{}
+	}
+	n := t.calls[key]
+	t.calls[key]++
+	t.mu.Unlock()
+
+	if t.Mode == Replay {
+		return t.replay(req, key, n)
+	}
+	return t.record(req, key, n, redactedURL, body)
+}
+
+func (t *Transport) path(key string, n int) string {
+	return filepath.Join(t.Dir, fmt.Sprintf("%s-%03d.json", key, n))
+}
+
+func (t *Transport) record(req *http.Request, key string, n int, redactedURL string, body []byte) (*http.Response, error) {
+	next := t.Next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	resp, err := next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	in := Interaction{
+		Request: RecordedRequest{
+			Method:  req.Method,
+			URL:     redactedURL,
+			Headers: redactHeaders(req.Header),
+			Body:    string(body),
+		},
+		Response: RecordedResponse{
+			Status:  resp.StatusCode,
+			Headers: redactHeaders(resp.Header),
+			Body:    string(respBody),
+		},
+	}
+	data, err := json.MarshalIndent(in, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(t.Dir, 0700); err != nil {
+		return nil, fmt.Errorf("cassette: %w", err)
+	}
+	if err := os.WriteFile(t.path(key, n), data, 0600); err != nil {
+		return nil, fmt.Errorf("cassette: %w", err)
+	}
+	return resp, nil
+}
+
+func (t *Transport) replay(req *http.Request, key string, n int) (*http.Response, error) {
+	data, err := os.ReadFile(t.path(key, n))
+	if os.IsNotExist(err) && n > 0 {
+		// More identical calls than were recorded: keep serving the last one.
+		for i := n - 1; i >= 0 && os.IsNotExist(err); i-- {
+			data, err = os.ReadFile(t.path(key, i))
+		}
+	}
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("cassette: no recording for %s %s in %s", req.Method, redactURL(req.URL), t.Dir)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cassette: %w", err)
+	}
+	var in Interaction
+	if err := json.Unmarshal(data, &in); err != nil {
+		return nil, fmt.Errorf("cassette: parse %s: %w", t.path(key, n), err)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", in.Response.Status, http.StatusText(in.Response.Status)),
+		StatusCode:    in.Response.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        in.Response.Headers,
+		Body:          io.NopCloser(strings.NewReader(in.Response.Body)),
+		ContentLength: int64(len(in.Response.Body)),
+		Request:       req,
+	}, nil
+}
+
+func requestKey(method, url string, body []byte) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s %s\n", method, url)
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+func redactHeaders(h http.Header) http.Header {
+	out := h.Clone()
+	if out == nil {
+		out = http.Header{}
+	}
+	for _, name := range secretHeaders {
+		if out.Get(name) != "" {
+			out.Set(name, Redacted)
+		}
+	}
+	return out
+}
+
+func redactURL(u *url.URL) string {
+	c := *u
+	q := c.Query()
+	changed := false
+	for _, p := range secretParams {
+		if q.Has(p) {
+			q.Set(p, Redacted)
+			changed = true
+		}
+	}
+	if changed {
+		c.RawQuery = q.Encode()
+	}
+	return c.String()
+}
@@ -0,0 +1,73 @@
+package cassette
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecordThenReplay(t *testing.T) {
+	dir := t.TempDir()
+	hits := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"echo":"` + string(body) + `","n":` + string(rune('0'+hits)) + `}`))
+	}))
+
+	rec := &http.Client{Transport: New(Record, dir)}
+	var recorded []string
+	for i := 0; i < 2; i++ {
+		req, _ := http.NewRequest("POST", srv.URL+"/v1/messages?key=sk-secret", strings.NewReader("hello"))
+		req.Header.Set("Authorization", "Bearer sk-secret")
+		req.Header.Set("X-Api-Key", "sk-secret")
+		resp, err := rec.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		recorded = append(recorded, string(b))
+	}
+	srv.Close()
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	if len(files) != 2 {
+		t.Fatalf("want 2 cassette files, got %d", len(files))
+	}
+	for _, f := range files {
+		data, _ := os.ReadFile(f)
+		if strings.Contains(string(data), "sk-secret") {
+			t.Errorf("%s leaks the API key:\n%s", filepath.Base(f), data)
+		}
+	}
+
+	rep := &http.Client{Transport: New(Replay, dir)}
+	for i := 0; i < 3; i++ {
+		req, _ := http.NewRequest("POST", srv.URL+"/v1/messages?key=other-key", strings.NewReader("hello"))
+		req.Header.Set("Authorization", "Bearer other-key")
+		resp, err := rep.Do(req)
+		if err != nil {
+			t.Fatalf("replay %d: %v", i, err)
+		}
+		b, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		want := recorded[min(i, len(recorded)-1)]
+		if string(b) != want {
+			t.Errorf("replay %d: got %s, want %s", i, b, want)
+		}
+	}
+}
+
+func TestReplayMissing(t *testing.T) {
+	c := &http.Client{Transport: New(Replay, t.TempDir())}
+	_, err := c.Post("https://api.example.com/v1/chat", "application/json", strings.NewReader("{}"))
+	if err == nil || !strings.Contains(err.Error(), "no recording") {
+		t.Fatalf("want missing-recording error, got %v", err)
+	}
+}
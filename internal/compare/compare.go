@@ -0,0 +1,257 @@
+// Package compare executes one task on several models in parallel and
+// reports the results side by side.
+package compare
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/thecoolrobot/task-agent/internal/ai"
+	"github.com/thecoolrobot/task-agent/internal/asana"
+	"github.com/thecoolrobot/task-agent/internal/output"
+)
+
+const (
+	reportJSON = "comparison.json"
+	reportMD   = "COMPARISON.md"
+)
+
+// Options controls a comparison run.
+type Options struct {
+	Models    []ai.ModelRef
+	OutputDir string
+	// APIKey resolves the key for a provider ID. An error fails only the
+	// models of that provider.
+	APIKey func(providerID string) (string, error)
+	// Progress receives status lines, tagged with the model they belong to.
+	Progress func(ref ai.ModelRef, msg string)
+}
+
+// Entry is one model's result within a comparison.
+type Entry struct {
+	Provider  string   `json:"provider"`
+	Model     string   `json:"model"`
+	Dir       string   `json:"dir"`
+	Files     int      `json:"files"`
+	Bytes     int      `json:"bytes"`
+	Usage     ai.Usage `json:"usage"`
+	CostUSD   *float64 `json:"cost_usd,omitempty"`
+	LatencyMS int64    `json:"latency_ms"`
+	Parsed    bool     `json:"parsed"`
+	Error     string   `json:"error,omitempty"`
+
+	Result *ai.TaskResult `json:"-"`
+}
+
+// Ref returns the entry's provider/model reference.
+func (e Entry) Ref() ai.ModelRef {
+	return ai.ModelRef{Provider: e.Provider, Model: e.Model}
+}
+
+// Report is the outcome of a comparison run.
+type Report struct {
+	TaskGID  string    `json:"task_gid"`
+	TaskName string    `json:"task_name"`
+	Dir      string    `json:"dir"`
+	Created  time.Time `json:"created"`
+	Entries  []Entry   `json:"entries"`
+	Winner   string    `json:"winner,omitempty"`
+}
+
+// Run executes task on every model in opts concurrently. Each result is
+// written to its own subfolder and a comparison report is written alongside.
+// Individual model failures are recorded in their Entry, not returned.
+func Run(task *asana.Task, opts Options) (*Report, error) {
+	if len(opts.Models) == 0 {
+		return nil, fmt.Errorf("no models to compare")
+	}
+	seen := map[string]bool{}
+	for _, ref := range opts.Models {
+		if seen[ref.String()] {
+			return nil, fmt.Errorf("model %s listed twice", ref)
+		}
+		seen[ref.String()] = true
+	}
+
+	rep := &Report{
+		TaskGID:  task.GetID(),
+		TaskName: task.Name,
+		Created:  time.Now(),
+		Entries:  make([]Entry, len(opts.Models)),
+	}
+	rep.Dir = filepath.Join(opts.OutputDir, time.Now().Format("20060102_150405")+"_compare_"+output.Sanitize(task.Name))
+	if err := os.MkdirAll(rep.Dir, 0755); err != nil {
+		return nil, fmt.Errorf("create comparison dir: %w", err)
+	}
+
+	taskMD := asana.FormatTaskMarkdown(task)
+	var wg sync.WaitGroup
+	for i, ref := range opts.Models {
+		wg.Add(1)
+		go func(i int, ref ai.ModelRef) {
+			defer wg.Done()
+			rep.Entries[i] = runOne(task, taskMD, ref, rep.Dir, opts)
+		}(i, ref)
+	}
+	wg.Wait()
+
+	if err := rep.save(); err != nil {
+		return rep, err
+	}
+	return rep, nil
+}
+
+func runOne(task *asana.Task, taskMD string, ref ai.ModelRef, dir string, opts Options) Entry {
+	e := Entry{
+		Provider: ref.Provider,
+		Model:    ref.Model,
+		Dir:      filepath.Join(dir, output.Sanitize(ref.Provider+"_"+ref.Model)),
+	}
+	progress := func(msg string) {
+		if opts.Progress != nil {
+			opts.Progress(ref, msg)
+		}
+	}
+
+	if _, ok := ai.GetProvider(ref.Provider); !ok {
+		e.Error = "unknown provider"
+		return e
+	}
+	apiKey := ""
+	if opts.APIKey != nil {
+		var err error
+		if apiKey, err = opts.APIKey(ref.Provider); err != nil {
+			e.Error = err.Error()
+			progress(e.Error)
+			return e
+		}
+	}
+
+	exec, err := ai.NewClient(ref.Provider, ref.Model, apiKey).Run(context.Background(), taskMD, progress)
+	if err != nil {
+		e.Error = err.Error()
+		progress("failed: " + e.Error)
+		return e
+	}
+	e.Result = exec.Result
+	e.Usage = exec.Usage
+	e.LatencyMS = exec.Latency.Milliseconds()
+	e.Parsed = exec.ParseErr == nil
+	e.Files = len(exec.Result.Files)
+	e.Bytes = output.Size(exec.Result)
+	if cost, ok := exec.Cost(); ok {
+		e.CostUSD = &cost
+	}
+	if err := output.WriteTo(exec, task, e.Dir); err != nil {
+		e.Error = err.Error()
+	}
+	progress(fmt.Sprintf("done in %s", exec.Latency.Round(time.Millisecond)))
+	return e
+}
+
+// Keep promotes entry i to a regular output folder under outputDir, copies
+// the comparison report into it, and removes the comparison folder with the
+// losing results. It returns the new folder path.
+func (r *Report) Keep(i int, outputDir string) (string, error) {
+	if i < 0 || i >= len(r.Entries) {
+		return "", fmt.Errorf("no comparison entry %d", i)
+	}
+	e := r.Entries[i]
+	if e.Error != "" || !dirExists(e.Dir) {
+		return "", fmt.Errorf("%s has no result to keep", e.Ref())
+	}
+	r.Winner = e.Ref().String()
+	dest := filepath.Join(outputDir, output.FolderName(&asana.Task{Name: r.TaskName}))
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return "", err
+	}
+	if err := os.Rename(e.Dir, dest); err != nil {
+		return "", fmt.Errorf("promote %s: %w", e.Ref(), err)
+	}
+	if err := os.WriteFile(filepath.Join(dest, reportMD), []byte(r.Markdown()), 0644); err != nil {
+		return dest, err
+	}
+	if err := os.RemoveAll(r.Dir); err != nil {
+		return dest, fmt.Errorf("remove comparison dir: %w", err)
+	}
+	return dest, nil
+}
+
+func dirExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+func (r *Report) save() error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(r.Dir, reportJSON), data, 0644); err != nil {
+		return fmt.Errorf("write %s: %w", reportJSON, err)
+	}
+	if err := os.WriteFile(filepath.Join(r.Dir, reportMD), []byte(r.Markdown()), 0644); err != nil {
+		return fmt.Errorf("write %s: %w", reportMD, err)
+	}
+	return nil
+}
+
+// Markdown renders the report as a table.
+func (r *Report) Markdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# ⚖️ Model Comparison\n\n")
+	fmt.Fprintf(&b, "**Task:** %s (`%s`)  \n", r.TaskName, r.TaskGID)
+	fmt.Fprintf(&b, "**Run:** %s\n", r.Created.Format("2006-01-02 15:04:05"))
+	if r.Winner != "" {
+		fmt.Fprintf(&b, "**Winner:** %s\n", r.Winner)
+	}
+	fmt.Fprintf(&b, "\n| Model | Files | Size | Tokens in/out | Cost | Latency | Parsed | Error |\n")
+	fmt.Fprintf(&b, "|-------|------:|-----:|--------------:|-----:|--------:|:------:|-------|\n")
+	for _, e := range r.Entries {
+		fmt.Fprintf(&b, "| %s | %d | %s | %d / %d | %s | %s | %s | %s |\n",
+			e.Ref(), e.Files, HumanBytes(e.Bytes), e.Usage.InputTokens, e.Usage.OutputTokens,
+			e.CostString(), e.LatencyString(), yesNo(e.Parsed), e.Error)
+	}
+	return b.String()
+}
+
+// CostString formats the estimated cost, or "?" when pricing is unknown.
+func (e Entry) CostString() string {
+	if e.CostUSD == nil {
+		return "?"
+	}
+	return fmt.Sprintf("$%.4f", *e.CostUSD)
+}
+
+// LatencyString formats the request latency.
+func (e Entry) LatencyString() string {
+	if e.LatencyMS == 0 {
+		return "-"
+	}
+	return (time.Duration(e.LatencyMS) * time.Millisecond).String()
+}
+
+// HumanBytes formats n as B / KB / MB.
+func HumanBytes(n int) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", n)
+	}
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
@@ -0,0 +1,164 @@
+package compare
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/thecoolrobot/task-agent/internal/ai"
+	"github.com/thecoolrobot/task-agent/internal/asana"
+)
+
+// fakeBackend answers by model name: "broken" fails, "raw" replies with
+// text that is not a task result, anything else with one file.
+type fakeBackend struct{ id string }
+
+func (b fakeBackend) Info() ai.Provider {
+	return ai.Provider{ID: b.id, Name: "Fake " + b.id, Models: []string{"good", "raw", "broken"}}
+}
+
+func (b fakeBackend) Capabilities() ai.Capabilities { return ai.Capabilities{} }
+
+func (b fakeBackend) Complete(ctx context.Context, hc *http.Client, req ai.Request) (*ai.Response, error) {
+	switch req.Model {
+	case "broken":
+		return nil, fmt.Errorf("%s: model overloaded", b.id)
+	case "raw":
+		return &ai.Response{Text: "Sorry, I can't do that.", Usage: ai.Usage{InputTokens: 5, OutputTokens: 7}}, nil
+	}
+	text := fmt.Sprintf(`{"output_type":"markdown","summary":"by %s","files":[{"path":"out.md","content":"# %s\n"}]}`, req.Model, req.Model)
+	return &ai.Response{Text: text, Usage: ai.Usage{InputTokens: 10, OutputTokens: 20}}, nil
+}
+
+func (b fakeBackend) Stream(ctx context.Context, hc *http.Client, req ai.Request, onDelta func(string)) (*ai.Response, error) {
+	return b.Complete(ctx, hc, req)
+}
+
+func (b fakeBackend) ListModels(ctx context.Context, hc *http.Client, apiKey string) ([]string, error) {
+	return b.Info().Models, nil
+}
+
+func (b fakeBackend) Prepare(req ai.Request) ai.WireRequest { return ai.WireRequest{} }
+
+func init() {
+	ai.Register(fakeBackend{id: "fake"})
+	ai.Register(fakeBackend{id: "locked"})
+}
+
+func TestRun(t *testing.T) {
+	type want struct {
+		err    string // substring of Entry.Error; "" for success
+		files  int
+		parsed bool
+	}
+	ok := want{files: 1, parsed: true}
+	tests := []struct {
+		name    string
+		models  string
+		want    []want
+		wantErr string // substring of the Run error
+	}{
+		{"all succeed", "fake/good,fake/better", []want{ok, ok}, ""},
+		{"one errors, others succeed", "fake/good,fake/broken,fake/better", []want{ok, {err: "fake: model overloaded"}, ok}, ""},
+		{"unparsed reply kept as raw output", "fake/good,fake/raw", []want{ok, {files: 1}}, ""},
+		{"key lookup fails for one provider", "fake/good,locked/good", []want{ok, {err: "no key for locked"}}, ""},
+		{"unknown provider", "fake/good,nope/x", []want{ok, {err: "unknown provider"}}, ""},
+		{"duplicate model", "fake/good,fake/good", nil, "listed twice"},
+		{"no models", "", nil, "no models"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var refs []ai.ModelRef
+			for _, s := range strings.Split(tt.models, ",") {
+				if prov, model, ok := strings.Cut(s, "/"); ok {
+					refs = append(refs, ai.ModelRef{Provider: prov, Model: model})
+				}
+			}
+			rep, err := Run(&asana.Task{GID: "7", Name: "Demo task"}, Options{
+				Models:    refs,
+				OutputDir: t.TempDir(),
+				APIKey: func(id string) (string, error) {
+					if id == "locked" {
+						return "", fmt.Errorf("no key for %s", id)
+					}
+					return "", nil
+				},
+			})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(rep.Entries) != len(tt.want) {
+				t.Fatalf("got %d entries, want %d", len(rep.Entries), len(tt.want))
+			}
+			for i, w := range tt.want {
+				e := rep.Entries[i]
+				if e.Ref() != refs[i] {
+					t.Errorf("entry %d is %s, want %s", i, e.Ref(), refs[i])
+				}
+				if w.err != "" {
+					if !strings.Contains(e.Error, w.err) || dirExists(e.Dir) {
+						t.Errorf("%s: error %q, dir written %v", e.Ref(), e.Error, dirExists(e.Dir))
+					}
+					continue
+				}
+				if e.Error != "" || e.Files != w.files || e.Parsed != w.parsed || e.Usage.InputTokens == 0 {
+					t.Errorf("%s: %+v", e.Ref(), e)
+				}
+				if _, err := os.Stat(filepath.Join(e.Dir, "AGENT_MANIFEST.md")); err != nil {
+					t.Errorf("%s: output not written: %v", e.Ref(), err)
+				}
+			}
+			for _, f := range []string{reportJSON, reportMD} {
+				if _, err := os.Stat(filepath.Join(rep.Dir, f)); err != nil {
+					t.Errorf("missing %s: %v", f, err)
+				}
+			}
+		})
+	}
+}
+
+func TestKeep(t *testing.T) {
+	out := t.TempDir()
+	rep, err := Run(&asana.Task{GID: "7", Name: "Demo task"}, Options{
+		Models:    []ai.ModelRef{{Provider: "fake", Model: "good"}, {Provider: "fake", Model: "broken"}},
+		OutputDir: out,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rep.Keep(1, out); err == nil {
+		t.Error("kept a failed entry")
+	}
+	dest, err := rep.Keep(0, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dirExists(rep.Dir) {
+		t.Error("comparison folder not removed")
+	}
+	md, err := os.ReadFile(filepath.Join(dest, reportMD))
+	if err != nil || !strings.Contains(string(md), "**Winner:** fake/good") {
+		t.Errorf("kept report: %v\n%s", err, md)
+	}
+	if _, err := os.Stat(filepath.Join(dest, "out.md")); err != nil {
+		t.Errorf("winning output not promoted: %v", err)
+	}
+}
+
+func TestHumanBytes(t *testing.T) {
+	for n, want := range map[int]string{0: "0 B", 1023: "1023 B", 1536: "1.5 KB", 3 << 20: "3.0 MB"} {
+		if got := HumanBytes(n); got != want {
+			t.Errorf("HumanBytes(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
@@ -3,37 +3,52 @@ package config
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
+
+	"github.com/thecoolrobot/task-agent/internal/ai"
+	"github.com/thecoolrobot/task-agent/internal/output"
+	"github.com/thecoolrobot/task-agent/internal/verify"
 )
 
 // Config holds all user-configurable settings.
 type Config struct {
-	WorkspaceGID      string            `json:"workspace_gid"`
-	ProjectGID        string            `json:"project_gid"`
-	Provider          string            `json:"provider"`
-	Model             string            `json:"model"`
-	OutputDir         string            `json:"output_dir"`
-	APIKeys           map[string]string `json:"api_keys"`
-	AsanaCLIPath      string            `json:"asana_cli_path"`
-	AutoCompleteTasks bool              `json:"auto_complete_tasks"`
-	Theme             string            `json:"theme"`
-}
+	// Version is the schema version of the file; see CurrentVersion.
+	Version           int                 `json:"version"`
+	WorkspaceGID      string              `json:"workspace_gid"`
+	ProjectGID        string              `json:"project_gid"`
+	Provider          string              `json:"provider"`
+	Model             string              `json:"model"`
+	OutputDir         string              `json:"output_dir"`
+	OutputLayout      string              `json:"output_layout,omitempty"` // "timestamp" (default) or "task"
+	APIKeys           map[string]string   `json:"api_keys"`
+	AsanaCLIPath      string              `json:"asana_cli_path"`
+	AutoCompleteTasks bool                `json:"auto_complete_tasks"`
+	Theme             string              `json:"theme"`                     // a theme name, or "auto" to follow the terminal background
+	ThemeOverrides    map[string]string   `json:"theme_overrides,omitempty"` // element → color, applied over the theme
+	Keys              map[string][]string `json:"keys,omitempty"`            // TUI action → keys, replacing its default keys
+	CompareModels     []string            `json:"compare_models,omitempty"`
+	BaseURLs          map[string]string   `json:"base_urls,omitempty"` // provider ID → API base URL override
+	Verify            verify.Config       `json:"verify"`
+	Retention         output.Policy       `json:"retention"`
+	Profiles          map[string]*Profile `json:"profiles,omitempty"`
+	DefaultProfile    string              `json:"default_profile,omitempty"`
 
-var configDir = filepath.Join(mustHomeDir(), ".task-agent")
-var configFile = filepath.Join(configDir, "config.json")
-
-func mustHomeDir() string {
-	h, err := os.UserHomeDir()
-	if err != nil {
-		return "."
-	}
-	return h
+	// Active is the profile applied on top of the base settings, if any.
+	// Saving writes changes to profile fields into that profile.
+	Active string `json:"-"`
+	// base holds the settings as loaded, before the profile was applied.
+	base   *Config
+	layers layers
 }
 
 // Defaults returns a Config with sensible defaults.
 func Defaults() *Config {
 	return &Config{
+		Version:   CurrentVersion,
 		Provider:  "anthropic",
 		Model:     "claude-sonnet-4-6",
 		OutputDir: "./task-outputs",
@@ -42,53 +57,206 @@ func Defaults() *Config {
 	}
 }
 
-// Load reads config from disk, merging with defaults.
+// ErrUnknownProfile is returned when the selected profile does not exist.
+var ErrUnknownProfile = errors.New("unknown profile")
+
+// Load reads the user config, merging it over the defaults, applies the
+// profile named by $TASK_AGENT_PROFILE or the default profile, and merges
+// the project config found from the working directory and then the
+// TASK_AGENT_* environment overrides on top.
 func Load() (*Config, error) {
+	return LoadProfile("")
+}
+
+// LoadProfile is Load with an explicit profile name, e.g. from --profile.
+// An empty name falls back to $TASK_AGENT_PROFILE and then the default.
+func LoadProfile(name string) (*Config, error) {
+	return load(name, true)
+}
+
+// LoadWithoutProfile is Load with no profile applied, whatever the
+// environment or default says.
+func LoadWithoutProfile() (*Config, error) {
+	return load("", false)
+}
+
+func load(name string, withProfile bool) (*Config, error) {
+	cfg, err := LoadBase()
+	if err != nil {
+		return cfg, err
+	}
+	// The project may pick the profile, so peek at it before merging.
+	userDefault := cfg.DefaultProfile
+	if wd, err := os.Getwd(); err == nil {
+		if path := FindProject(wd); path != "" {
+			if tree, err := readTree(path); err == nil {
+				if def, ok := tree["default_profile"].(string); ok && def != "" {
+					cfg.DefaultProfile = def
+				}
+			}
+		}
+	}
+	why := ""
+	if withProfile {
+		name, why, err = SelectProfile(cfg, name)
+	}
+	cfg.DefaultProfile = userDefault
+	if err != nil {
+		return cfg, err
+	}
+	if name != "" {
+		cfg.base = clone(cfg)
+		p := cfg.Profiles[name]
+		p.apply(cfg)
+		cfg.Active = name
+		var tree map[string]any
+		data, _ := json.Marshal(p)
+		_ = json.Unmarshal(data, &tree)
+		cfg.setSources(tree, fmt.Sprintf("%s %s (%s)", SourceProfile, name, why))
+	}
+	if err := loadProject(cfg); err != nil {
+		return cfg, err
+	}
+	return cfg, loadEnv(cfg)
+}
+
+// LoadBase reads the user config without applying any profile or the
+// project config, for editing the profiles themselves. A file from an
+// older version is upgraded and rewritten, after a backup copy is made.
+func LoadBase() (*Config, error) {
 	cfg := Defaults()
-	data, err := os.ReadFile(configFile)
+	path := configFile()
+	data, err := os.ReadFile(path)
 	if os.IsNotExist(err) {
 		return cfg, nil
 	}
 	if err != nil {
 		return cfg, err
 	}
-	if err := json.Unmarshal(data, cfg); err != nil {
-		return cfg, err
+	tree := map[string]any{}
+	if err := json.Unmarshal(data, &tree); err != nil {
+		return cfg, fmt.Errorf("parse %s: %w", path, err)
+	}
+	from, err := migrate(tree)
+	if err != nil {
+		return cfg, fmt.Errorf("%s: %w", path, err)
+	}
+	if from > CurrentVersion {
+		cfg.layers.warnings = append(cfg.layers.warnings, fmt.Sprintf("%s: written by a newer task-agent (config version %d, this build knows %d)", path, from, CurrentVersion))
+	}
+	cfg.keepUnknown(tree, path)
+	merged, _ := json.Marshal(tree)
+	if err := json.Unmarshal(merged, cfg); err != nil {
+		return cfg, fmt.Errorf("parse %s: %w", path, err)
 	}
 	if cfg.APIKeys == nil {
 		cfg.APIKeys = map[string]string{}
 	}
+	cfg.setSources(tree, SourceUser+" "+path)
+	if from < CurrentVersion {
+		if err := writeBackup(path, from, data); err != nil {
+			return cfg, err
+		}
+		if err := Save(cfg); err != nil {
+			return cfg, fmt.Errorf("save migrated config: %w", err)
+		}
+		cfg.layers.upgraded = fmt.Sprintf("%s: upgraded from config version %d to %d (%s); the old file is at %s",
+			path, from, CurrentVersion, migrationSteps(from), backupPath(path, from))
+		cfg.layers.warnings = append(cfg.layers.warnings, cfg.layers.upgraded)
+	}
 	return cfg, nil
 }
 
-// Save writes config to disk with restricted permissions.
+// Save writes the user config to disk with restricted permissions. Values
+// that came from the project config or the environment and were not
+// changed are left out; keys this build does not know are written back as
+// read, so an older task-agent keeps a newer one's settings. With a
+// profile active, the fields the profile set, and any that now differ from
+// the base settings, are saved to the profile and the base is left as
+// loaded. The file is replaced atomically, so a crash never leaves it
+// truncated.
 func Save(cfg *Config) error {
-	if err := os.MkdirAll(configDir, 0700); err != nil {
+	if err := os.MkdirAll(configDir(), 0700); err != nil {
 		return err
 	}
-	data, err := json.MarshalIndent(cfg, "", "  ")
+	out := withoutOverlays(cfg)
+	if cfg.Active != "" && cfg.base != nil {
+		p := diffProfile(cfg.base, cfg.Profiles[cfg.Active], out)
+		restoreBase(out, cfg.base)
+		out.Profiles[cfg.Active] = p
+		cfg.Profiles[cfg.Active] = p
+	}
+	if out.Version < CurrentVersion {
+		out.Version = CurrentVersion
+	}
+	var v any = out
+	if len(cfg.layers.unknown) > 0 {
+		tree := toTree(out)
+		for _, u := range cfg.layers.unknown {
+			setPath(tree, u.path, u.value)
+		}
+		v = tree
+	}
+	data, err := json.MarshalIndent(v, "", "  ")
 	if err != nil {
 		return err
 	}
-	if err := os.WriteFile(configFile, data, 0600); err != nil {
+	return writeFileAtomic(configFile(), append(data, '\n'), 0600)
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames
+// it into place.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
 		return err
 	}
-	return nil
+	tmp := f.Name()
+	defer os.Remove(tmp) // no-op once renamed
+	if err := f.Chmod(perm); err != nil && runtime.GOOS != "windows" {
+		f.Close()
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
 }
 
-// GetAPIKey returns the API key for a provider, checking env vars first.
+// GetAPIKey returns the API key for a provider, checking the provider's
+// registered env var first. Key references in the config are resolved; one
+// that cannot be resolved counts as no key — use LookupAPIKey for the
+// reason.
 func GetAPIKey(cfg *Config, providerID string) string {
-	envVars := map[string]string{
-		"anthropic": "ANTHROPIC_API_KEY",
-		"openai":    "OPENAI_API_KEY",
-		"groq":      "GROQ_API_KEY",
-	}
-	if envKey, ok := envVars[providerID]; ok {
-		if val := os.Getenv(envKey); val != "" {
-			return val
+	key, _ := LookupAPIKey(cfg, providerID)
+	return key
+}
+
+// LookupAPIKey is GetAPIKey, returning the error from a key reference that
+// could not be resolved.
+func LookupAPIKey(cfg *Config, providerID string) (string, error) {
+	if prov, ok := ai.GetProvider(providerID); ok && prov.EnvKey != "" {
+		if val := os.Getenv(prov.EnvKey); val != "" {
+			return val, nil
 		}
 	}
-	return cfg.APIKeys[providerID]
+	v := cfg.APIKeys[providerID]
+	if v == "" {
+		return "", nil
+	}
+	key, err := ResolveKey(v, providerID)
+	if err != nil {
+		return "", fmt.Errorf("api_keys.%s: %w", providerID, err)
+	}
+	return key, nil
 }
 
 // SetAPIKey stores an API key in the config.
@@ -101,5 +269,5 @@ func SetAPIKey(cfg *Config, providerID, key string) {
 
 // ConfigPath returns the path to the config file (for display).
 func ConfigPath() string {
-	return configFile
-}
\ No newline at end of file
+	return configFile()
+}
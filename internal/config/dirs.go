@@ -0,0 +1,91 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// HomeEnv puts task-agent's config, and the keys and themes kept beside
+// it, in one directory, so several isolated setups can share a machine.
+const HomeEnv = "TASK_AGENT_HOME"
+
+// configOverride is the config file named by --config, if any.
+var configOverride string
+
+// SetConfigFile makes path the config file, e.g. from --config. An empty
+// path restores the default location.
+func SetConfigFile(path string) {
+	if path != "" {
+		if abs, err := filepath.Abs(path); err == nil {
+			path = abs
+		}
+	}
+	configOverride = path
+}
+
+// baseConfigDir resolves the config directory on every call so a changed
+// environment (e.g. in tests) is honoured: $TASK_AGENT_HOME, else the
+// legacy ~/.task-agent when it exists, else $XDG_CONFIG_HOME/task-agent
+// (%AppData%\task-agent on Windows).
+func baseConfigDir() string {
+	if home := os.Getenv(HomeEnv); home != "" {
+		return home
+	}
+	if legacy := LegacyDir(); isDir(legacy) {
+		return legacy
+	}
+	if runtime.GOOS == "windows" {
+		cfg, err := os.UserConfigDir()
+		if err != nil {
+			cfg = mustHomeDir()
+		}
+		return filepath.Join(cfg, "task-agent")
+	}
+	return xdgDir("XDG_CONFIG_HOME", ".config")
+}
+
+// xdgDir is task-agent's directory under the base directory in env, or
+// under fallback in the home directory. The spec says to ignore relative
+// paths.
+func xdgDir(env, fallback string) string {
+	base := os.Getenv(env)
+	if !filepath.IsAbs(base) {
+		base = filepath.Join(mustHomeDir(), fallback)
+	}
+	return filepath.Join(base, "task-agent")
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+func mustHomeDir() string {
+	h, err := os.UserHomeDir()
+	if err != nil {
+		return "."
+	}
+	return h
+}
+
+// LegacyDir is where task-agent kept everything before it followed the XDG
+// base directories. It is still used when it exists.
+func LegacyDir() string { return filepath.Join(mustHomeDir(), ".task-agent") }
+
+func configDir() string {
+	if configOverride != "" {
+		return filepath.Dir(configOverride)
+	}
+	return baseConfigDir()
+}
+
+func configFile() string {
+	if configOverride != "" {
+		return configOverride
+	}
+	return filepath.Join(configDir(), "config.json")
+}
+
+// ThemesDir holds user theme files, next to the config file.
+func ThemesDir() string { return filepath.Join(configDir(), "themes") }
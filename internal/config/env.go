@@ -0,0 +1,110 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/thecoolrobot/task-agent/internal/ai"
+)
+
+// EnvPrefix starts the name of every environment override.
+const EnvPrefix = "TASK_AGENT_"
+
+// EnvVar maps an environment variable to the setting it overrides.
+type EnvVar struct {
+	Name string `json:"name"`
+	Key  string `json:"key"`
+}
+
+// EnvName is the environment variable that overrides key, e.g.
+// TASK_AGENT_VERIFY_FIX_ATTEMPTS for verify.fix_attempts.
+func EnvName(key string) string {
+	r := strings.NewReplacer(".", "_", "-", "_")
+	return EnvPrefix + strings.ToUpper(r.Replace(key))
+}
+
+// EnvVars lists every environment override, sorted by name. Plain fields
+// are derived from the Config type; the per-provider entries of api_keys
+// and base_urls come from the provider registry, with API keys under the
+// provider's own variable such as ANTHROPIC_API_KEY. Profiles cannot be
+// set from the environment; select one with TASK_AGENT_PROFILE.
+func EnvVars() []EnvVar {
+	var out []EnvVar
+	var walk func(t reflect.Type, prefix string)
+	walk = func(t reflect.Type, prefix string) {
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			tag, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if !f.IsExported() || tag == "" || tag == "-" {
+				continue
+			}
+			key := prefix + tag
+			switch {
+			case key == "version":
+				// The schema version describes the config file, not a setting,
+				// so there is no TASK_AGENT_VERSION.
+			case key == "profiles", key == "default_profile":
+				// Profiles are picked with TASK_AGENT_PROFILE.
+			case key == "theme_overrides", key == "keys":
+				// Keyed by theme element or TUI action, not provider; set
+				// them in a file.
+			case key == "api_keys":
+				for _, prov := range ai.Providers() {
+					if prov.EnvKey != "" {
+						out = append(out, EnvVar{Name: prov.EnvKey, Key: key + "." + prov.ID})
+					}
+				}
+			case f.Type.Kind() == reflect.Map:
+				for _, prov := range ai.Providers() {
+					out = append(out, EnvVar{Name: EnvName(key + "." + prov.ID), Key: key + "." + prov.ID})
+				}
+			case f.Type.Kind() == reflect.Struct:
+				walk(f.Type, key+".")
+			default:
+				out = append(out, EnvVar{Name: EnvName(key), Key: key})
+			}
+		}
+	}
+	walk(reflect.TypeOf(Config{}), "")
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// loadEnv merges the TASK_AGENT_* overrides set in the environment into
+// cfg. API keys are left to GetAPIKey, which reads them when used so they
+// never end up in a saved config. A value that does not parse is skipped
+// with a warning.
+func loadEnv(cfg *Config) error {
+	tree := map[string]any{}
+	names := map[string]string{}
+	for _, ev := range EnvVars() {
+		v, ok := os.LookupEnv(ev.Name)
+		if !ok || v == "" || strings.HasPrefix(ev.Key, "api_keys.") {
+			continue
+		}
+		t, err := keyType(ev.Key)
+		if err != nil {
+			return err
+		}
+		val, err := parseValue(t, v)
+		if err != nil {
+			cfg.layers.warnings = append(cfg.layers.warnings, fmt.Sprintf("%s: ignoring %q: %v", ev.Name, v, err))
+			continue
+		}
+		setPath(tree, strings.Split(ev.Key, "."), jsonValue(val))
+		names[ev.Key] = ev.Name
+	}
+	if len(tree) == 0 {
+		return nil
+	}
+	if err := cfg.merge(tree); err != nil {
+		return err
+	}
+	for key, name := range names {
+		cfg.setSource(key, SourceEnv+" "+name)
+	}
+	return nil
+}
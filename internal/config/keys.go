@@ -0,0 +1,167 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// ErrUnknownKey is returned for a key that names no setting.
+var ErrUnknownKey = errors.New("unknown config key")
+
+// keyType returns the Go type of the setting at key. Keys are dotted paths
+// of JSON field names, e.g. "model", "verify.fix_attempts" or
+// "api_keys.openai".
+func keyType(key string) (reflect.Type, error) {
+	t := reflect.TypeOf(Config{})
+	for _, part := range strings.Split(key, ".") {
+		for t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+		switch t.Kind() {
+		case reflect.Struct:
+			f, ok := jsonField(t, part)
+			if !ok {
+				return nil, fmt.Errorf("%w %q", ErrUnknownKey, key)
+			}
+			t = f.Type
+		case reflect.Map:
+			t = t.Elem()
+		default:
+			return nil, fmt.Errorf("%w %q", ErrUnknownKey, key)
+		}
+	}
+	return t, nil
+}
+
+// jsonField finds the field of struct type t encoded as name.
+func jsonField(t reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if f.IsExported() && tag == name && tag != "-" {
+			return f, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// parseValue converts the command-line form of a value to type t: plain
+// text for strings, true/false, integers, comma-separated lists of strings,
+// or JSON for anything else.
+func parseValue(t reflect.Type, s string) (any, error) {
+	switch {
+	case t.Kind() == reflect.String:
+		return s, nil
+	case t.Kind() == reflect.Bool:
+		return strconv.ParseBool(s)
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Int64:
+		return strconv.Atoi(s)
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.String && !strings.HasPrefix(strings.TrimSpace(s), "["):
+		var out []string
+		for _, part := range strings.Split(s, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				out = append(out, part)
+			}
+		}
+		return out, nil
+	}
+	v := reflect.New(t)
+	if err := json.Unmarshal([]byte(s), v.Interface()); err != nil {
+		return nil, fmt.Errorf("want JSON for %s: %w", t, err)
+	}
+	return v.Elem().Interface(), nil
+}
+
+// Get returns the effective value at key, in its JSON form.
+func (c *Config) Get(key string) (any, error) {
+	if _, err := keyType(key); err != nil {
+		return nil, err
+	}
+	v, ok := lookup(toTree(c), strings.Split(key, "."))
+	if !ok {
+		return nil, fmt.Errorf("%s is not set", key)
+	}
+	return v, nil
+}
+
+// Set parses value for the setting at key and stores it.
+func (c *Config) Set(key, value string) error {
+	t, err := keyType(key)
+	if err != nil {
+		return err
+	}
+	v, err := parseValue(t, value)
+	if err != nil {
+		return fmt.Errorf("%s: %w", key, err)
+	}
+	return c.update(func(tree map[string]any) {
+		setPath(tree, strings.Split(key, "."), jsonValue(v))
+	})
+}
+
+// SetFlag applies the command-line flag named flag to the setting at key.
+// Like the environment, it is an overlay: Resolved reports it as a flag
+// and Save leaves it out unless it is changed afterwards.
+func (c *Config) SetFlag(key, value, flag string) error {
+	t, err := keyType(key)
+	if err != nil {
+		return err
+	}
+	v, err := parseValue(t, value)
+	if err != nil {
+		return fmt.Errorf("%s: %w", flag, err)
+	}
+	tree := map[string]any{}
+	setPath(tree, strings.Split(key, "."), jsonValue(v))
+	if err := c.merge(tree); err != nil {
+		return err
+	}
+	c.setSource(key, SourceFlag+" "+flag)
+	return nil
+}
+
+// jsonValue converts v to the generic form encoding/json produces.
+func jsonValue(v any) any {
+	data, _ := json.Marshal(v)
+	var out any
+	_ = json.Unmarshal(data, &out)
+	return out
+}
+
+// Unset puts the setting at key back to its default, or removes it when
+// it is a map entry.
+func (c *Config) Unset(key string) error {
+	if _, err := keyType(key); err != nil {
+		return err
+	}
+	path := strings.Split(key, ".")
+	return c.update(func(tree map[string]any) {
+		if v, ok := lookup(toTree(Defaults()), path); ok {
+			setPath(tree, path, v)
+		} else {
+			deletePath(tree, path)
+		}
+	})
+}
+
+// update edits the exported settings of c through their JSON form, keeping
+// the load state Save needs.
+func (c *Config) update(fn func(tree map[string]any)) error {
+	tree := toTree(c)
+	fn(tree)
+	data, err := json.Marshal(tree)
+	if err != nil {
+		return err
+	}
+	next := &Config{}
+	if err := json.Unmarshal(data, next); err != nil {
+		return err
+	}
+	next.Active, next.base, next.layers = c.Active, c.base, c.layers
+	*c = *next
+	return nil
+}
@@ -0,0 +1,112 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// migration upgrades a config tree by one version. Steps work on the
+// generic JSON form so they can rename and restructure fields the Config
+// struct no longer has.
+type migration struct {
+	desc string
+	fn   func(tree map[string]any)
+}
+
+// migrations is the upgrade chain in order: migrations[i] upgrades a
+// version i+1 file to version i+2. To change the schema, append a step.
+var migrations []migration
+
+// CurrentVersion is the config schema version this build writes, one past
+// the last migration. Files without a version field are version 1.
+var CurrentVersion = len(migrations) + 1
+
+// migrate upgrades tree to CurrentVersion and returns the version it
+// started at. A file from a newer task-agent is left as it is.
+func migrate(tree map[string]any) (int, error) {
+	version := 1
+	if v, ok := tree["version"]; ok {
+		f, ok := v.(float64)
+		if !ok || f < 1 || f != float64(int(f)) {
+			return 0, fmt.Errorf("invalid config version %v", v)
+		}
+		version = int(f)
+	}
+	if version >= CurrentVersion {
+		return version, nil
+	}
+	for _, m := range migrations[version-1:] {
+		m.fn(tree)
+	}
+	tree["version"] = float64(CurrentVersion)
+	return version, nil
+}
+
+// migrationSteps describes the steps that upgrade a file from version.
+func migrationSteps(from int) string {
+	var steps []string
+	for _, m := range migrations[from-1:] {
+		steps = append(steps, m.desc)
+	}
+	return strings.Join(steps, "; ")
+}
+
+// backupPath is where the file at path is copied before migrating it from
+// version.
+func backupPath(path string, version int) string {
+	return fmt.Sprintf("%s.v%d.bak", path, version)
+}
+
+// writeBackup copies data, the unmigrated config, next to path.
+func writeBackup(path string, version int, data []byte) error {
+	if err := os.WriteFile(backupPath(path, version), data, 0600); err != nil {
+		return fmt.Errorf("back up %s before migrating: %w", filepath.Base(path), err)
+	}
+	return nil
+}
+
+// unknownKeys lists the paths in tree that name no setting, shortest
+// unknown prefix only, sorted.
+func unknownKeys(tree map[string]any) [][]string {
+	seen := map[string][]string{}
+	for _, path := range leaves(tree, nil) {
+		for i := 1; i <= len(path); i++ {
+			key := strings.Join(path[:i], ".")
+			if _, err := keyType(key); err != nil {
+				seen[key] = path[:i]
+				break
+			}
+		}
+	}
+	keys := make([]string, 0, len(seen))
+	for k := range seen {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	paths := make([][]string, len(keys))
+	for i, k := range keys {
+		paths[i] = seen[k]
+	}
+	return paths
+}
+
+// warnUnknown records a warning for each key in tree that is not a
+// setting.
+func (c *Config) warnUnknown(tree map[string]any, file string) {
+	for _, path := range unknownKeys(tree) {
+		c.layers.warnings = append(c.layers.warnings, fmt.Sprintf("%s: unknown key %q — ignored", file, strings.Join(path, ".")))
+	}
+}
+
+// keepUnknown is warnUnknown for the user config, whose unknown keys Save
+// writes back unchanged: they may be settings of a newer task-agent.
+func (c *Config) keepUnknown(tree map[string]any, file string) {
+	for _, path := range unknownKeys(tree) {
+		v, _ := lookup(tree, path)
+		c.layers.unknown = append(c.layers.unknown, unknownKey{path, v})
+		c.layers.warnings = append(c.layers.warnings, fmt.Sprintf("%s: unknown key %q — not used, kept in the file", file, strings.Join(path, ".")))
+	}
+}
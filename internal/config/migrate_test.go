@@ -0,0 +1,154 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// useMigrations swaps in a migration chain for the rest of the test.
+func useMigrations(t *testing.T, steps ...migration) {
+	t.Helper()
+	m, v := migrations, CurrentVersion
+	t.Cleanup(func() { migrations, CurrentVersion = m, v })
+	migrations, CurrentVersion = steps, len(steps)+1
+}
+
+// testMigrations is a two-step chain: version 1 → 2 → 3.
+var testMigrations = []migration{
+	{desc: "rename colour to theme", fn: func(tree map[string]any) {
+		if v, ok := tree["colour"]; ok {
+			tree["theme"] = v
+			delete(tree, "colour")
+		}
+	}},
+	{desc: "move asana_cli to asana_cli_path", fn: func(tree map[string]any) {
+		if v, ok := tree["asana_cli"]; ok {
+			tree["asana_cli_path"] = v
+			delete(tree, "asana_cli")
+		}
+	}},
+}
+
+func TestMigrate(t *testing.T) {
+	useMigrations(t, testMigrations...)
+
+	tests := []struct {
+		name     string
+		in       string
+		from     int
+		wantKeys map[string]any
+		wantErr  bool
+	}{
+		{"unversioned runs every step", `{"colour": "light", "asana_cli": "/bin/asana"}`, 1,
+			map[string]any{"theme": "light", "asana_cli_path": "/bin/asana", "version": float64(3)}, false},
+		{"version 2 runs the last step", `{"version": 2, "colour": "x", "asana_cli": "/bin/asana"}`, 2,
+			map[string]any{"colour": "x", "asana_cli_path": "/bin/asana", "version": float64(3)}, false},
+		{"current is untouched", `{"version": 3, "colour": "x"}`, 3, map[string]any{"colour": "x"}, false},
+		{"newer is untouched", `{"version": 7, "colour": "x"}`, 7, map[string]any{"colour": "x", "version": float64(7)}, false},
+		{"invalid version", `{"version": "two"}`, 0, nil, true},
+		{"fractional version", `{"version": 1.5}`, 0, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tree := map[string]any{}
+			if err := json.Unmarshal([]byte(tt.in), &tree); err != nil {
+				t.Fatal(err)
+			}
+			from, err := migrate(tree)
+			if (err != nil) != tt.wantErr || from != tt.from {
+				t.Fatalf("migrate = %d, %v", from, err)
+			}
+			for k, want := range tt.wantKeys {
+				if tree[k] != want {
+					t.Errorf("%s = %v, want %v (tree %v)", k, tree[k], want, tree)
+				}
+			}
+		})
+	}
+	if got := migrationSteps(2); got != "move asana_cli to asana_cli_path" {
+		t.Errorf("migrationSteps(2) = %q", got)
+	}
+}
+
+func TestLoadBaseMigrates(t *testing.T) {
+	useMigrations(t, testMigrations...)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+	SetConfigFile(path)
+	defer SetConfigFile("")
+
+	v1 := `{"colour": "light", "asana_cli": "/opt/asana", "model": "gpt-4o", "colr": "typo"}`
+	if err := os.WriteFile(path, []byte(v1), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadBase()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Theme != "light" || cfg.AsanaCLIPath != "/opt/asana" || cfg.Version != 3 {
+		t.Errorf("migrated: theme %q, asana_cli_path %q, version %d", cfg.Theme, cfg.AsanaCLIPath, cfg.Version)
+	}
+	if notice := cfg.Upgraded(); !strings.Contains(notice, "from config version 1 to 3 (rename colour to theme; move asana_cli to asana_cli_path)") {
+		t.Errorf("notice %q", notice)
+	}
+	warnings := strings.Join(cfg.Warnings(), "\n")
+	if !strings.Contains(warnings, `unknown key "colr"`) {
+		t.Errorf("warnings:\n%s", warnings)
+	}
+	if backup, err := os.ReadFile(path + ".v1.bak"); err != nil || string(backup) != v1 {
+		t.Errorf("backup = %q, %v", backup, err)
+	}
+
+	// The file is rewritten at the new version, keeping the unknown key,
+	// through a temporary file that does not linger.
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var onDisk map[string]any
+	if err := json.Unmarshal(data, &onDisk); err != nil {
+		t.Fatal(err)
+	}
+	if onDisk["version"] != float64(3) || onDisk["theme"] != "light" || onDisk["asana_cli_path"] != "/opt/asana" || onDisk["colr"] != "typo" {
+		t.Errorf("rewritten file:\n%s", data)
+	}
+	if _, ok := onDisk["colour"]; ok {
+		t.Errorf("old key left in the file:\n%s", data)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if strings.Join(names, " ") != "config.json config.json.v1.bak" {
+		t.Errorf("files after migrating: %v", names)
+	}
+	if info, err := os.Stat(path); err == nil && runtime.GOOS != "windows" && info.Mode().Perm() != 0600 {
+		t.Errorf("mode %v", info.Mode().Perm())
+	}
+
+	// The rewritten file is current, so the next load upgrades nothing.
+	cfg, err = LoadBase()
+	if err != nil || cfg.Upgraded() != "" || cfg.Theme != "light" {
+		t.Errorf("reload: %v, notice %q, theme %q", err, cfg.Upgraded(), cfg.Theme)
+	}
+
+	// A version 2 file runs only the second step.
+	v2 := `{"version": 2, "asana_cli": "/usr/bin/asana"}`
+	if err := os.WriteFile(path, []byte(v2), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if cfg, err = LoadBase(); err != nil || cfg.AsanaCLIPath != "/usr/bin/asana" || !strings.Contains(cfg.Upgraded(), "version 2 to 3 (move asana_cli to asana_cli_path)") {
+		t.Errorf("from v2: %v, %q", err, cfg.Upgraded())
+	}
+	if backup, err := os.ReadFile(path + ".v2.bak"); err != nil || string(backup) != v2 {
+		t.Errorf("v2 backup = %q, %v", backup, err)
+	}
+}
@@ -0,0 +1,181 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/thecoolrobot/task-agent/internal/ai"
+)
+
+// ProfileEnv selects a profile when no --profile flag is given.
+const ProfileEnv = "TASK_AGENT_PROFILE"
+
+// Profile overrides the workspace-specific part of the configuration, e.g.
+// for one Asana workspace or client. Empty fields inherit the base config;
+// map entries are merged over it.
+type Profile struct {
+	WorkspaceGID  string            `json:"workspace_gid,omitempty"`
+	ProjectGID    string            `json:"project_gid,omitempty"`
+	Provider      string            `json:"provider,omitempty"`
+	Model         string            `json:"model,omitempty"`
+	OutputDir     string            `json:"output_dir,omitempty"`
+	APIKeys       map[string]string `json:"api_keys,omitempty"`
+	BaseURLs      map[string]string `json:"base_urls,omitempty"` // provider routing
+	CompareModels []string          `json:"compare_models,omitempty"`
+}
+
+// apply overlays p on cfg.
+func (p *Profile) apply(cfg *Config) {
+	set := func(dst *string, v string) {
+		if v != "" {
+			*dst = v
+		}
+	}
+	set(&cfg.WorkspaceGID, p.WorkspaceGID)
+	set(&cfg.ProjectGID, p.ProjectGID)
+	set(&cfg.Provider, p.Provider)
+	set(&cfg.Model, p.Model)
+	set(&cfg.OutputDir, p.OutputDir)
+	if len(p.CompareModels) > 0 {
+		cfg.CompareModels = append([]string(nil), p.CompareModels...)
+	}
+	cfg.APIKeys = mergeMap(cfg.APIKeys, p.APIKeys)
+	cfg.BaseURLs = mergeMap(cfg.BaseURLs, p.BaseURLs)
+}
+
+// diffProfile returns the profile that turns base into cfg. Fields prev,
+// the profile as loaded, set stay in the profile even when they now match
+// base, so the profile does not start following later base changes.
+func diffProfile(base *Config, prev *Profile, cfg *Config) *Profile {
+	if prev == nil {
+		prev = &Profile{}
+	}
+	p := &Profile{}
+	diff := func(dst *string, was, b, v string) {
+		if was != "" || v != b {
+			*dst = v
+		}
+	}
+	diff(&p.WorkspaceGID, prev.WorkspaceGID, base.WorkspaceGID, cfg.WorkspaceGID)
+	diff(&p.ProjectGID, prev.ProjectGID, base.ProjectGID, cfg.ProjectGID)
+	diff(&p.Provider, prev.Provider, base.Provider, cfg.Provider)
+	diff(&p.Model, prev.Model, base.Model, cfg.Model)
+	diff(&p.OutputDir, prev.OutputDir, base.OutputDir, cfg.OutputDir)
+	if len(prev.CompareModels) > 0 || fmt.Sprint(base.CompareModels) != fmt.Sprint(cfg.CompareModels) {
+		p.CompareModels = cfg.CompareModels
+	}
+	p.APIKeys = diffMap(prev.APIKeys, base.APIKeys, cfg.APIKeys)
+	p.BaseURLs = diffMap(prev.BaseURLs, base.BaseURLs, cfg.BaseURLs)
+	return p
+}
+
+// restoreBase puts base's values back into the profile-able fields of cfg.
+func restoreBase(cfg, base *Config) {
+	cfg.WorkspaceGID = base.WorkspaceGID
+	cfg.ProjectGID = base.ProjectGID
+	cfg.Provider = base.Provider
+	cfg.Model = base.Model
+	cfg.OutputDir = base.OutputDir
+	cfg.CompareModels = base.CompareModels
+	cfg.APIKeys = base.APIKeys
+	cfg.BaseURLs = base.BaseURLs
+}
+
+func mergeMap(base, over map[string]string) map[string]string {
+	if len(over) == 0 {
+		return base
+	}
+	out := map[string]string{}
+	for k, v := range base {
+		out[k] = v
+	}
+	for k, v := range over {
+		out[k] = v
+	}
+	return out
+}
+
+// diffMap returns the entries of cur that differ from base or that prev
+// already overrode.
+func diffMap(prev, base, cur map[string]string) map[string]string {
+	var out map[string]string
+	for k, v := range cur {
+		if _, set := prev[k]; set || base[k] != v {
+			if out == nil {
+				out = map[string]string{}
+			}
+			out[k] = v
+		}
+	}
+	return out
+}
+
+// clone deep-copies cfg through JSON, dropping unexported state.
+func clone(cfg *Config) *Config {
+	data, _ := json.Marshal(cfg)
+	out := &Config{}
+	_ = json.Unmarshal(data, out)
+	return out
+}
+
+// ProfileNames returns the configured profile names, sorted.
+func (c *Config) ProfileNames() []string {
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// WithProfile returns a copy of the settings with profile name applied.
+func (c *Config) WithProfile(name string) *Config {
+	out := clone(c)
+	if p, ok := c.Profiles[name]; ok {
+		p.apply(out)
+	}
+	return out
+}
+
+// SelectProfile resolves which profile to apply: name if given, else
+// $TASK_AGENT_PROFILE, else the config's default. It also says which of
+// those picked it.
+func SelectProfile(cfg *Config, name string) (string, string, error) {
+	why := "--profile"
+	if name == "" {
+		name, why = os.Getenv(ProfileEnv), ProfileEnv
+	}
+	if name == "" {
+		name, why = cfg.DefaultProfile, "default_profile"
+	}
+	if name == "" {
+		return "", "", nil
+	}
+	if _, ok := cfg.Profiles[name]; !ok {
+		return "", "", fmt.Errorf("%w %q (have: %s)", ErrUnknownProfile, name, strings.Join(cfg.ProfileNames(), ", "))
+	}
+	return name, why, nil
+}
+
+// appliedBaseURLs remembers which providers ApplyBaseURLs redirected, so a
+// later call for another profile can undo them without touching overrides
+// set elsewhere (e.g. by tests).
+var appliedBaseURLs = map[string]bool{}
+
+// ApplyBaseURLs points providers at the base URLs configured in cfg,
+// clearing the ones a previously applied config set.
+func ApplyBaseURLs(cfg *Config) {
+	for id := range appliedBaseURLs {
+		if _, ok := cfg.BaseURLs[id]; !ok {
+			ai.SetBaseURL(id, "")
+		}
+	}
+	appliedBaseURLs = map[string]bool{}
+	for id, url := range cfg.BaseURLs {
+		ai.SetBaseURL(id, url)
+		appliedBaseURLs[id] = true
+	}
+}
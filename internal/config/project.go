@@ -0,0 +1,388 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/thecoolrobot/task-agent/internal/ai"
+)
+
+// ProjectFiles are the project-local config file names, looked up in the
+// working directory and each of its parents. The first directory holding
+// one wins; within a directory the earlier name wins.
+var ProjectFiles = []string{".task-agent.json", ".task-agent.yaml", ".task-agent.yml"}
+
+// projectAllowed are the settings a project file may set, with everything
+// beneath them. A checked-out repository must not be able to supply
+// credentials, redirect API traffic, run commands, or complete tasks and
+// delete output on the user's behalf, so any other setting, including one
+// added later, is ignored.
+var projectAllowed = []string{
+	"workspace_gid", "project_gid", "provider", "model", "output_dir", "output_layout",
+	"compare_models", "theme", "theme_overrides", "keys",
+}
+
+// projectKey reports whether the setting at path may come from a project
+// file and, if not, the key to name in the warning: the shortest part of
+// path that no allowed setting starts with.
+func projectKey(path []string) (string, bool) {
+	key := strings.Join(path, ".")
+	for _, a := range projectAllowed {
+		if key == a || strings.HasPrefix(key, a+".") {
+			return key, true
+		}
+	}
+	for i := 1; i < len(path); i++ {
+		prefix := strings.Join(path[:i], ".")
+		if !slices.ContainsFunc(projectAllowed, func(a string) bool { return strings.HasPrefix(a, prefix+".") }) {
+			return prefix, false
+		}
+	}
+	return key, false
+}
+
+// Sources of a setting, lowest precedence first.
+const (
+	SourceDefault = "default"
+	SourceUser    = "user"
+	SourceProfile = "profile"
+	SourceProject = "project"
+	SourceEnv     = "env"
+	SourceFlag    = "flag"
+)
+
+// Setting is one resolved config value and where it came from.
+type Setting struct {
+	Key    string `json:"key"`
+	Value  any    `json:"value"`
+	Source string `json:"source"`
+}
+
+// IsSecret reports whether the setting at key holds a credential and
+// should be masked when shown.
+func IsSecret(key string) bool {
+	parts := strings.Split(key, ".")
+	for i, p := range parts[:len(parts)-1] {
+		if p == "api_keys" && (i == 0 || parts[0] == "profiles") {
+			return true
+		}
+	}
+	return false
+}
+
+// layers records how a loaded Config was assembled, for Resolved and for
+// Save, which must not write project or environment values into the user
+// config.
+type layers struct {
+	sources  map[string]string // dotted key → source description
+	project  string            // project file path, if any
+	overlays []overlay
+	warnings []string
+	unknown  []unknownKey // user config keys this build does not know
+	upgraded string       // the migration this load did, if any
+}
+
+// unknownKey is a key of the user config that names no setting, with its
+// value as read.
+type unknownKey struct {
+	path  []string
+	value any
+}
+
+// overlay is a set of values merged over the user config: the project
+// file, the environment or a command-line flag.
+type overlay struct {
+	// tree is what the overlay set; before and loaded are the settings just
+	// before and just after it was merged in.
+	tree   map[string]any
+	before map[string]any
+	loaded map[string]any
+}
+
+// FindProject returns the project config file that applies in dir, or "".
+func FindProject(dir string) string {
+	for {
+		for _, name := range ProjectFiles {
+			p := filepath.Join(dir, name)
+			if info, err := os.Stat(p); err == nil && !info.IsDir() {
+				return p
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// readTree parses a JSON or YAML config file into a generic tree with the
+// same shape encoding/json produces.
+func readTree(path string) (map[string]any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	tree := map[string]any{}
+	switch filepath.Ext(path) {
+	case ".yaml", ".yml":
+		var v any
+		if err := yaml.Unmarshal(data, &v); err != nil {
+			return nil, fmt.Errorf("parse %s: %w", path, err)
+		}
+		if v == nil {
+			return tree, nil
+		}
+		// Round-trip through JSON so numbers and nesting match the JSON form.
+		data, err = json.Marshal(v)
+		if err != nil {
+			return nil, fmt.Errorf("parse %s: %w", path, err)
+		}
+	}
+	if err := json.Unmarshal(data, &tree); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return tree, nil
+}
+
+// loadProject merges the project file found from the working directory into
+// cfg.
+func loadProject(cfg *Config) error {
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil
+	}
+	path := FindProject(wd)
+	if path == "" {
+		return nil
+	}
+	tree, err := readTree(path)
+	if err != nil {
+		return err
+	}
+	cfg.warnUnknown(tree, path)
+	tree = projectSafe(cfg, tree, path)
+	// A relative output_dir is relative to the project, not to wherever
+	// task-agent happens to run.
+	if dir, ok := tree["output_dir"].(string); ok && dir != "" && !filepath.IsAbs(dir) {
+		tree["output_dir"] = filepath.Join(filepath.Dir(path), dir)
+	}
+
+	if err := cfg.merge(tree); err != nil {
+		return fmt.Errorf("parse %s: %w", path, err)
+	}
+	cfg.layers.project = path
+	cfg.setSources(tree, SourceProject+" "+path)
+	return nil
+}
+
+// projectSafe returns the settings of the project file at file that
+// projectAllowed lets through, warning once about each key it drops.
+// Unknown keys are dropped silently; warnUnknown has reported them.
+func projectSafe(cfg *Config, tree map[string]any, file string) map[string]any {
+	paths := leaves(tree, nil)
+	sort.Slice(paths, func(i, j int) bool { return strings.Join(paths[i], ".") < strings.Join(paths[j], ".") })
+	safe := map[string]any{}
+	warned := map[string]bool{}
+	for _, p := range paths {
+		v, _ := lookup(tree, p)
+		key, ok := projectKey(p)
+		switch {
+		case ok:
+			setPath(safe, p, v)
+		case !known(p):
+		case !warned[key]:
+			warned[key] = true
+			cfg.layers.warnings = append(cfg.layers.warnings, fmt.Sprintf("%s: ignoring %q (not allowed in a project config)", file, key))
+		}
+	}
+	return safe
+}
+
+// known reports whether every part of path names a setting.
+func known(path []string) bool {
+	for i := 1; i <= len(path); i++ {
+		if _, err := keyType(strings.Join(path[:i], ".")); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// merge overlays tree on cfg, remembering it so Save can take it off again.
+func (c *Config) merge(tree map[string]any) error {
+	o := overlay{tree: tree, before: toTree(c)}
+	data, _ := json.Marshal(tree)
+	if err := json.Unmarshal(data, c); err != nil {
+		return err
+	}
+	o.loaded = toTree(c)
+	c.layers.overlays = append(c.layers.overlays, o)
+	return nil
+}
+
+// withoutOverlays returns a copy of cfg with every value an overlay set,
+// and that has not been changed since, put back to what it was before the
+// overlay was merged in.
+func withoutOverlays(cfg *Config) *Config {
+	cur := toTree(cfg)
+	for i := len(cfg.layers.overlays) - 1; i >= 0; i-- {
+		o := cfg.layers.overlays[i]
+		for _, path := range leaves(o.tree, nil) {
+			now, _ := lookup(cur, path)
+			then, _ := lookup(o.loaded, path)
+			if !reflect.DeepEqual(now, then) {
+				continue
+			}
+			if v, ok := lookup(o.before, path); ok {
+				setPath(cur, path, v)
+			} else {
+				deletePath(cur, path)
+			}
+		}
+	}
+	out := &Config{}
+	data, _ := json.Marshal(cur)
+	_ = json.Unmarshal(data, out)
+	return out
+}
+
+// ProjectPath is the project config file merged into c, or "".
+func (c *Config) ProjectPath() string { return c.layers.project }
+
+// Warnings are problems found while loading that did not stop it.
+func (c *Config) Warnings() []string { return c.layers.warnings }
+
+// Upgraded describes the migration loading the config did, or is empty.
+// Only the first load after an upgrade reports it, since the file is
+// rewritten at the current version.
+func (c *Config) Upgraded() string { return c.layers.upgraded }
+
+// setSources records source for every value set in tree.
+func (c *Config) setSources(tree map[string]any, source string) {
+	for _, path := range leaves(tree, nil) {
+		c.setSource(strings.Join(path, "."), source)
+	}
+}
+
+func (c *Config) setSource(key, source string) {
+	if c.layers.sources == nil {
+		c.layers.sources = map[string]string{}
+	}
+	c.layers.sources[key] = source
+}
+
+// Resolved lists every effective setting, sorted by key, with where its
+// value came from. API keys supplied by environment variables are reported
+// as such even though they are read when used.
+func (c *Config) Resolved() []Setting {
+	tree := toTree(c)
+	var out []Setting
+	for _, path := range leaves(tree, nil) {
+		key := strings.Join(path, ".")
+		v, _ := lookup(tree, path)
+		out = append(out, Setting{Key: key, Value: v, Source: c.source(path)})
+	}
+	for _, prov := range ai.Providers() {
+		if prov.EnvKey == "" || os.Getenv(prov.EnvKey) == "" {
+			continue
+		}
+		key := "api_keys." + prov.ID
+		s := Setting{Key: key, Value: os.Getenv(prov.EnvKey), Source: SourceEnv + " " + prov.EnvKey}
+		out = slices.DeleteFunc(out, func(o Setting) bool {
+			// An empty api_keys object stops being a value once a key is
+			// listed under it.
+			m, empty := o.Value.(map[string]any)
+			return o.Key == key || o.Key == "api_keys" && empty && len(m) == 0
+		})
+		out = append(out, s)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+	return out
+}
+
+// Source returns where the value at key came from, e.g. "default" or
+// "project /repo/.task-agent.yaml".
+func (c *Config) Source(key string) string { return c.source(strings.Split(key, ".")) }
+
+// source returns where the value at path came from: the innermost recorded
+// source on the path, else the defaults.
+func (c *Config) source(path []string) string {
+	for i := len(path); i > 0; i-- {
+		if s, ok := c.layers.sources[strings.Join(path[:i], ".")]; ok {
+			return s
+		}
+	}
+	return SourceDefault
+}
+
+// toTree converts cfg to the generic form encoding/json produces.
+func toTree(cfg *Config) map[string]any {
+	data, _ := json.Marshal(cfg)
+	tree := map[string]any{}
+	_ = json.Unmarshal(data, &tree)
+	return tree
+}
+
+// leaves lists the paths of the values in tree. Lists and empty objects
+// are values; other objects are descended into.
+func leaves(tree map[string]any, prefix []string) [][]string {
+	var out [][]string
+	for k, v := range tree {
+		path := append(append([]string(nil), prefix...), k)
+		if sub, ok := v.(map[string]any); ok && len(sub) > 0 {
+			out = append(out, leaves(sub, path)...)
+			continue
+		}
+		out = append(out, path)
+	}
+	return out
+}
+
+func lookup(tree map[string]any, path []string) (any, bool) {
+	var v any = tree
+	for _, k := range path {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if v, ok = m[k]; !ok {
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+func setPath(tree map[string]any, path []string, v any) {
+	for _, k := range path[:len(path)-1] {
+		sub, ok := tree[k].(map[string]any)
+		if !ok {
+			sub = map[string]any{}
+			tree[k] = sub
+		}
+		tree = sub
+	}
+	tree[path[len(path)-1]] = v
+}
+
+// deletePath removes the value at path and reports whether there was one.
+func deletePath(tree map[string]any, path []string) bool {
+	for _, k := range path[:len(path)-1] {
+		sub, ok := tree[k].(map[string]any)
+		if !ok {
+			return false
+		}
+		tree = sub
+	}
+	_, ok := tree[path[len(path)-1]]
+	delete(tree, path[len(path)-1])
+	return ok
+}
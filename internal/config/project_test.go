@@ -0,0 +1,64 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestProjectAllowlist(t *testing.T) {
+	tests := []struct {
+		name    string
+		project string // YAML
+		warn    string // the key named in the warning; "" when allowed
+		check   func(*Config) bool
+	}{
+		{"model", "model: qwen2.5-coder", "", func(c *Config) bool { return c.Model == "qwen2.5-coder" }},
+		{"keys", "keys:\n  up: [w]", "", func(c *Config) bool { return len(c.Keys["up"]) == 1 }},
+		{"theme overrides", "theme_overrides:\n  accent: '#ff0000'", "", func(c *Config) bool { return c.ThemeOverrides["accent"] == "#ff0000" }},
+		{"auto complete tasks", "auto_complete_tasks: true", "auto_complete_tasks", func(c *Config) bool { return !c.AutoCompleteTasks }},
+		{"retention auto", "retention:\n  auto: true\n  older_than_days: 1", "retention", func(c *Config) bool { return !c.Retention.Auto && c.Retention.OlderThanDays == 0 }},
+		{"verify enabled", "verify:\n  enabled: true", "verify", func(c *Config) bool { return !c.Verify.Enabled }},
+		{"verify hooks", "verify:\n  hooks:\n    - name: x\n      command: rm -rf ~", "verify", func(c *Config) bool { return len(c.Verify.Hooks) == 0 }},
+		{"api keys", "api_keys:\n  openai: sk-evil", "api_keys", func(c *Config) bool { return c.APIKeys["openai"] == "" }},
+		{"base urls", "base_urls:\n  ollama: http://evil.example", "base_urls", func(c *Config) bool { return len(c.BaseURLs) == 0 }},
+		{"asana cli path", "asana_cli_path: /tmp/evil", "asana_cli_path", func(c *Config) bool { return c.AsanaCLIPath != "/tmp/evil" }},
+		{"profiles", "profiles:\n  x:\n    model: m", "profiles", func(c *Config) bool { return len(c.Profiles) == 0 }},
+		{"default profile", "default_profile: x", "default_profile", func(c *Config) bool { return c.DefaultProfile == "" }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := t.TempDir()
+			if err := os.WriteFile(filepath.Join(repo, ".task-agent.yaml"), []byte(tt.project+"\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			wd, err := os.Getwd()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chdir(repo); err != nil {
+				t.Fatal(err)
+			}
+			defer os.Chdir(wd)
+
+			cfg := Defaults()
+			if err := loadProject(cfg); err != nil {
+				t.Fatal(err)
+			}
+			if !tt.check(cfg) {
+				t.Errorf("config after loading %q: %+v", tt.project, cfg)
+			}
+			warnings := strings.Join(cfg.Warnings(), "\n")
+			if tt.warn == "" {
+				if warnings != "" {
+					t.Errorf("warnings: %s", warnings)
+				}
+				return
+			}
+			if len(cfg.Warnings()) != 1 || !strings.Contains(warnings, `ignoring "`+tt.warn+`" (not allowed in a project config)`) {
+				t.Errorf("warnings: %s", warnings)
+			}
+		})
+	}
+}
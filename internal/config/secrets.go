@@ -0,0 +1,273 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zalando/go-keyring"
+	"golang.org/x/sync/singleflight"
+)
+
+// Key references let api_keys hold a pointer to a secret instead of the
+// secret itself:
+//
+//	env:NAME             the environment variable NAME
+//	cmd:<command>        the output of a shell command, e.g. "cmd:pass show anthropic"
+//	file:<path>          the contents of a file
+//	keyring:[<account>]  the OS keyring (Secret Service, Keychain or Credential
+//	                     Manager); the account defaults to the provider ID
+const (
+	RefEnv     = "env:"
+	RefCmd     = "cmd:"
+	RefFile    = "file:"
+	RefKeyring = "keyring:"
+)
+
+// KeyringService is the service name task-agent's keyring entries use.
+const KeyringService = "task-agent"
+
+// cmdTimeout bounds a cmd: reference.
+var cmdTimeout = 30 * time.Second
+
+// IsKeyRef reports whether v is a key reference rather than a literal key.
+func IsKeyRef(v string) bool {
+	for _, p := range []string{RefEnv, RefCmd, RefFile, RefKeyring} {
+		if strings.HasPrefix(v, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolved caches cmd: and keyring: lookups, failed ones included, so a
+// command that prompts for a passphrase or times out runs once per process.
+// resolvedMu guards only the map: lookups run outside it, and resolving
+// joins concurrent lookups of the same reference.
+var (
+	resolvedMu  sync.Mutex
+	resolved    = map[string]resolution{}
+	resolvedGen int // bumped by forgetResolved
+	resolving   singleflight.Group
+)
+
+type resolution struct {
+	key string
+	err error
+}
+
+// forgetResolved drops the cached lookups, e.g. once a key the keyring did
+// not have has been stored.
+func forgetResolved() {
+	resolvedMu.Lock()
+	defer resolvedMu.Unlock()
+	clear(resolved)
+	resolvedGen++
+}
+
+// ResolveKey returns the secret v refers to, or v itself when it is not a
+// reference. account names the keyring entry of a bare "keyring:".
+func ResolveKey(v, account string) (string, error) {
+	if !IsKeyRef(v) {
+		return v, nil
+	}
+	if !strings.HasPrefix(v, RefCmd) && !strings.HasPrefix(v, RefKeyring) {
+		r := lookupKey(v, account)
+		return r.key, r.err
+	}
+	cacheKey := v + "\x00" + account
+	resolvedMu.Lock()
+	r, ok := resolved[cacheKey]
+	gen := resolvedGen
+	resolvedMu.Unlock()
+	if ok {
+		return r.key, r.err
+	}
+	res, _, _ := resolving.Do(cacheKey, func() (any, error) {
+		r := lookupKey(v, account)
+		resolvedMu.Lock()
+		defer resolvedMu.Unlock()
+		// A lookup that started before forgetResolved may be stale.
+		if gen == resolvedGen {
+			resolved[cacheKey] = r
+		}
+		return r, nil
+	})
+	r = res.(resolution)
+	return r.key, r.err
+}
+
+func lookupKey(v, account string) resolution {
+	s, err := resolveKey(v, account)
+	if err == nil && s == "" {
+		err = fmt.Errorf("%s is empty", describeRef(v))
+	}
+	if err != nil {
+		s = ""
+	}
+	return resolution{s, err}
+}
+
+func resolveKey(v, account string) (string, error) {
+	switch {
+	case strings.HasPrefix(v, RefEnv):
+		return os.Getenv(strings.TrimPrefix(v, RefEnv)), nil
+
+	case strings.HasPrefix(v, RefFile):
+		path := expandHome(strings.TrimPrefix(v, RefFile))
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("key file: %w", err)
+		}
+		return strings.TrimSpace(string(data)), nil
+
+	case strings.HasPrefix(v, RefCmd):
+		ctx, cancel := context.WithTimeout(context.Background(), cmdTimeout)
+		defer cancel()
+		var cmd *exec.Cmd
+		if runtime.GOOS == "windows" {
+			cmd = exec.CommandContext(ctx, "cmd", "/C", strings.TrimPrefix(v, RefCmd))
+		} else {
+			cmd = exec.CommandContext(ctx, "sh", "-c", strings.TrimPrefix(v, RefCmd))
+		}
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			msg := strings.TrimSpace(stderr.String())
+			if msg == "" {
+				msg = err.Error()
+			}
+			return "", fmt.Errorf("%s failed: %s", describeRef(v), msg)
+		}
+		// Tools like pass print the secret on the first line.
+		line, _, _ := strings.Cut(string(out), "\n")
+		return strings.TrimSpace(line), nil
+
+	case strings.HasPrefix(v, RefKeyring):
+		if a := strings.TrimPrefix(v, RefKeyring); a != "" {
+			account = a
+		}
+		s, err := keyring.Get(KeyringService, account)
+		if err != nil {
+			return "", fmt.Errorf("keyring %s/%s: %w", KeyringService, account, err)
+		}
+		return s, nil
+	}
+	return v, nil
+}
+
+// describeRef names a reference in errors without echoing a whole command
+// line.
+func describeRef(v string) string {
+	kind, rest, _ := strings.Cut(v, ":")
+	if kind == "cmd" {
+		if fields := strings.Fields(rest); len(fields) > 0 {
+			return "key command " + fields[0]
+		}
+	}
+	return v
+}
+
+func expandHome(path string) string {
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		return filepath.Join(mustHomeDir(), rest)
+	}
+	return path
+}
+
+// Key stores for MigrateKeys.
+const (
+	StoreKeyring = "keyring"
+	StoreFile    = "file"
+)
+
+// KeyStores lists the stores MigrateKeys can move keys into.
+var KeyStores = []string{StoreKeyring, StoreFile}
+
+// keyDir holds the key files of the file store.
+func keyDir() string { return filepath.Join(configDir(), "keys") }
+
+// MovedKey is a plaintext key MigrateKeys moved.
+type MovedKey struct {
+	Key string // config key, e.g. "api_keys.openai"
+	Ref string // the reference that replaced it
+}
+
+// MigrateKeys moves the plaintext API keys in cfg, including those of its
+// profiles, into store and replaces them with references. With dryRun it
+// only reports what it would move. cfg should come from LoadBase; the
+// caller saves it.
+func MigrateKeys(cfg *Config, store string, dryRun bool) ([]MovedKey, error) {
+	var moved []MovedKey
+	move := func(keys map[string]string, prefix, account string, id string) error {
+		v := keys[id]
+		if v == "" || IsKeyRef(v) {
+			return nil
+		}
+		var ref string
+		switch store {
+		case StoreKeyring:
+			ref = RefKeyring + account
+			if account == id {
+				ref = RefKeyring
+			}
+			if !dryRun {
+				if err := keyring.Set(KeyringService, account, v); err != nil {
+					return fmt.Errorf("keyring %s/%s: %w", KeyringService, account, err)
+				}
+				forgetResolved()
+			}
+		case StoreFile:
+			path := filepath.Join(keyDir(), strings.ReplaceAll(account, "/", "_"))
+			ref = RefFile + path
+			if !dryRun {
+				if err := os.MkdirAll(keyDir(), 0700); err != nil {
+					return err
+				}
+				if err := os.WriteFile(path, []byte(v+"\n"), 0600); err != nil {
+					return err
+				}
+			}
+		default:
+			return fmt.Errorf("unknown key store %q (want one of %s)", store, strings.Join(KeyStores, ", "))
+		}
+		if !dryRun {
+			keys[id] = ref
+		}
+		moved = append(moved, MovedKey{Key: prefix + id, Ref: ref})
+		return nil
+	}
+
+	for _, id := range sortedKeys(cfg.APIKeys) {
+		if err := move(cfg.APIKeys, "api_keys.", id, id); err != nil {
+			return moved, err
+		}
+	}
+	for _, name := range cfg.ProfileNames() {
+		p := cfg.Profiles[name]
+		for _, id := range sortedKeys(p.APIKeys) {
+			if err := move(p.APIKeys, "profiles."+name+".api_keys.", name+"/"+id, id); err != nil {
+				return moved, err
+			}
+		}
+	}
+	return moved, nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,57 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestResolveKeyDoesNotBlock(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	defer forgetResolved()
+	t.Setenv("TASK_AGENT_TEST_KEY", "from-env")
+	count := filepath.Join(t.TempDir(), "count")
+	slow := "cmd:echo run >> " + count + "; sleep 1; echo slow-key"
+	fast := "cmd:echo fast-key"
+	if k, err := ResolveKey(fast, "x"); err != nil || k != "fast-key" {
+		t.Fatalf("fast = %q, %v", k, err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if k, err := ResolveKey(slow, "x"); err != nil || k != "slow-key" {
+				t.Errorf("slow = %q, %v", k, err)
+			}
+		}()
+	}
+	time.Sleep(100 * time.Millisecond) // let the command start
+
+	start := time.Now()
+	if k, err := ResolveKey("env:TASK_AGENT_TEST_KEY", "x"); err != nil || k != "from-env" {
+		t.Errorf("env = %q, %v", k, err)
+	}
+	if k, err := ResolveKey(fast, "x"); err != nil || k != "fast-key" {
+		t.Errorf("cached = %q, %v", k, err)
+	}
+	if d := time.Since(start); d > 500*time.Millisecond {
+		t.Errorf("other lookups waited %s for a running command", d)
+	}
+	wg.Wait()
+
+	data, err := os.ReadFile(count)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(data), "run"); n != 1 {
+		t.Errorf("command ran %d times, want 1", n)
+	}
+}
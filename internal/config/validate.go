@@ -0,0 +1,101 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+
+	"github.com/thecoolrobot/task-agent/internal/ai"
+	"github.com/thecoolrobot/task-agent/internal/asana"
+	"github.com/thecoolrobot/task-agent/internal/output"
+)
+
+// Validate checks that the settings can run a task: the provider and model
+// are known, the provider's API key is present, the output directory is
+// writable and asana-cli can be found. It returns one message per problem.
+func (c *Config) Validate() []string {
+	var problems []string
+	add := func(key, format string, args ...any) {
+		problems = append(problems, key+": "+fmt.Sprintf(format, args...))
+	}
+
+	prov, ok := ai.GetProvider(c.Provider)
+	switch {
+	case !ok:
+		add("provider", "unknown provider %q", c.Provider)
+	case c.Model == "":
+		add("model", "not set")
+	case prov.RequiresKey() && !slices.Contains(prov.Models, c.Model):
+		// Local providers run whatever models are installed, so only hosted
+		// providers are checked against the known list.
+		add("model", "%q is not a known %s model", c.Model, prov.Name)
+	}
+	if ok && prov.RequiresKey() {
+		if key, err := LookupAPIKey(c, prov.ID); err != nil {
+			problems = append(problems, err.Error())
+		} else if key == "" {
+			add("api_keys."+prov.ID, "no API key — set %s or api_keys.%s", prov.EnvKey, prov.ID)
+		}
+	}
+	for _, ref := range c.CompareModels {
+		if _, err := ai.ParseModelRef(ref); err != nil {
+			add("compare_models", "%v", err)
+		}
+	}
+
+	if c.OutputDir == "" {
+		add("output_dir", "not set")
+	} else if err := checkWritable(c.OutputDir); err != nil {
+		add("output_dir", "%v", err)
+	}
+	if c.OutputLayout != "" && !slices.Contains(output.Layouts, c.OutputLayout) {
+		add("output_layout", "unknown layout %q (want one of %v)", c.OutputLayout, output.Layouts)
+	}
+
+	if c.AsanaCLIPath != "" {
+		if _, err := exec.LookPath(c.AsanaCLIPath); err != nil {
+			add("asana_cli_path", "%v", err)
+		}
+	} else if _, err := asana.NewClient(""); err != nil {
+		add("asana_cli_path", "%v", err)
+	}
+
+	if c.DefaultProfile != "" {
+		if _, ok := c.Profiles[c.DefaultProfile]; !ok {
+			add("default_profile", "%v %q", ErrUnknownProfile, c.DefaultProfile)
+		}
+	}
+	return problems
+}
+
+// checkWritable reports whether files can be created in dir, or in the
+// nearest existing parent when dir does not exist yet.
+func checkWritable(dir string) error {
+	for {
+		info, err := os.Stat(dir)
+		if errors.Is(err, fs.ErrNotExist) {
+			parent := filepath.Dir(dir)
+			if parent == dir {
+				return err
+			}
+			dir = parent
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("%s is not a directory", dir)
+		}
+		f, err := os.CreateTemp(dir, ".task-agent-write-test-*")
+		if err != nil {
+			return fmt.Errorf("%s is not writable", dir)
+		}
+		f.Close()
+		return os.Remove(f.Name())
+	}
+}
@@ -0,0 +1,80 @@
+// Package editor opens text in the user's $VISUAL / $EDITOR.
+package editor
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// Name returns the configured editor command, falling back to vi (notepad
+// on Windows).
+func Name() string {
+	for _, env := range []string{"VISUAL", "EDITOR"} {
+		if v := strings.TrimSpace(os.Getenv(env)); v != "" {
+			return v
+		}
+	}
+	if runtime.GOOS == "windows" {
+		return "notepad"
+	}
+	return "vi"
+}
+
+// Command returns an unstarted command that edits path. Editors given with
+// arguments, such as "code --wait", are split on whitespace.
+func Command(path string) *exec.Cmd {
+	args := strings.Fields(Name())
+	return exec.Command(args[0], append(args[1:], path)...)
+}
+
+// TempFile writes text to a new temp file named after pattern and returns
+// its path.
+func TempFile(pattern, text string) (string, error) {
+	f, err := os.CreateTemp("", pattern)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+	if _, err := f.WriteString(text); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// ReadBack reads and removes an edited temp file. An empty result is an
+// error so an emptied buffer aborts the operation.
+func ReadBack(path string) (string, error) {
+	defer os.Remove(path)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	text := strings.TrimSpace(string(data))
+	if text == "" {
+		return "", fmt.Errorf("edited text is empty — aborting")
+	}
+	return text, nil
+}
+
+// Edit opens text in the editor attached to the current terminal and
+// returns the edited result.
+func Edit(pattern, text string) (string, error) {
+	path, err := TempFile(pattern, text)
+	if err != nil {
+		return "", err
+	}
+	cmd := Command(path)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		os.Remove(path)
+		return "", fmt.Errorf("%s: %w", Name(), err)
+	}
+	return ReadBack(path)
+}
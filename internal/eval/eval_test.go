@@ -0,0 +1,174 @@
+package eval
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/thecoolrobot/task-agent/internal/ai"
+	"github.com/thecoolrobot/task-agent/internal/testharness"
+)
+
+func writeSuite(t *testing.T, body string) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "task.md"), []byte("Write a README"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "smoke.json")
+	if err := os.WriteFile(path, []byte(body), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadSuite(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		wantErr string
+	}{
+		{"valid", `{"judge": "openai/gpt-4o", "cases": [{"name": "readme", "task_file": "task.md", "assertions": [
+			{"type": "file_exists", "path": "README.md"}, {"type": "regex", "pattern": "Done"},
+			{"type": "go_build"}, {"type": "judge", "rubric": "clear", "min_score": 7}]}]}`, ""},
+		{"not json", `{"cases": [`, "parse suite"},
+		{"no cases", `{"cases": []}`, "no cases"},
+		{"unnamed case", `{"cases": [{"task_file": "task.md"}]}`, "case 1 has no name"},
+		{"duplicate case", `{"cases": [{"name": "a", "task_file": "task.md"}, {"name": "a", "gid": "7"}]}`, `duplicate case name "a"`},
+		{"no task", `{"cases": [{"name": "a"}]}`, "set exactly one of task_file and gid"},
+		{"two tasks", `{"cases": [{"name": "a", "task_file": "task.md", "gid": "7"}]}`, "set exactly one of task_file and gid"},
+		{"file_exists without path", `{"cases": [{"name": "a", "gid": "7", "assertions": [{"type": "file_exists"}]}]}`, "file_exists needs a path"},
+		{"regex without pattern", `{"cases": [{"name": "a", "gid": "7", "assertions": [{"type": "regex"}]}]}`, "regex needs a pattern"},
+		{"judge without rubric", `{"cases": [{"name": "a", "gid": "7", "assertions": [{"type": "judge"}]}]}`, "judge needs a rubric"},
+		{"unknown assertion", `{"cases": [{"name": "a", "gid": "7", "assertions": [{"type": "vibes"}]}]}`, `unknown assertion type "vibes"`},
+		{"judge without provider", `{"judge": "gpt-4o", "cases": [{"name": "a", "gid": "7"}]}`, "judge: invalid model"},
+		{"judge with unknown provider", `{"judge": "acme/big", "cases": [{"name": "a", "gid": "7"}]}`, `judge: unknown provider "acme"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := LoadSuite(writeSuite(t, tt.body))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s.Name != "smoke" || len(s.Cases) != 1 || len(s.Cases[0].Assertions) != 4 {
+				t.Errorf("suite = %+v", s)
+			}
+		})
+	}
+}
+
+func TestScore(t *testing.T) {
+	c := Case{Assertions: []Assertion{
+		{Type: AssertFileExists, Path: "a"},
+		{Type: AssertFileExists, Path: "b", Weight: 3},
+	}}
+	tests := []struct {
+		name       string
+		c          Case
+		res        Result
+		wantScore  float64
+		wantPassed int
+	}{
+		{"all pass", c, Result{Checks: []Check{{Passed: true}, {Passed: true}}}, 1, 2},
+		{"weighted", c, Result{Checks: []Check{{Passed: false}, {Passed: true}}}, 0.75, 1},
+		{"none pass", c, Result{Checks: []Check{{}, {}}}, 0, 0},
+		{"error scores zero", c, Result{Error: "boom", Checks: []Check{{Passed: true}, {Passed: true}}}, 0, 2},
+		{"missing checks fail", c, Result{Error: "boom"}, 0, 0},
+		{"no assertions, parsed", Case{}, Result{Parsed: true}, 1, 0},
+		{"no assertions, unparsed", Case{}, Result{}, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := tt.res
+			res.score(tt.c)
+			if res.Score != tt.wantScore || res.Passed != tt.wantPassed || res.Total != len(tt.c.Assertions) {
+				t.Errorf("score = %g, passed %d/%d", res.Score, res.Passed, res.Total)
+			}
+		})
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	cost := 0.5
+	r := &Report{
+		Models: []string{"anthropic/a", "openai/b"},
+		Results: []Result{
+			{Model: "anthropic/a", Score: 1, Passed: 2, Total: 2, CostUSD: &cost},
+			{Model: "anthropic/a", Score: 0, Total: 2, Error: "boom", CostUSD: &cost},
+			{Model: "openai/b", Score: 1, Passed: 2, Total: 2},
+			{Model: "openai/b", Score: 1, Passed: 2, Total: 2},
+		},
+	}
+	r.summarize()
+	if len(r.Summary) != 2 || r.Summary[0].Model != "openai/b" || r.Summary[0].CostUSD != nil {
+		t.Fatalf("summary = %+v", r.Summary)
+	}
+	a := r.Summary[1]
+	if a.Score != 0.5 || a.Passed != 2 || a.Total != 4 || a.Errors != 1 || a.CostUSD == nil || *a.CostUSD != 1 {
+		t.Errorf("anthropic/a = %+v", a)
+	}
+}
+
+func TestJudgeSelection(t *testing.T) {
+	model := ai.ModelRef{Provider: "anthropic", Model: "claude-sonnet-4-6"}
+	body := `{"cases": [{"name": "readme", "task_file": "task.md", "assertions": [{"type": "judge", "rubric": "clear", "min_score": 7}]}]}`
+	tests := []struct {
+		name      string
+		judge     string
+		wantPath  string
+		wantModel string
+		wantKeys  string // providers whose API key was looked up
+	}{
+		{"defaults to the model under test", "", "/v1/messages", "claude-sonnet-4-6", "anthropic,anthropic"},
+		{"suite judge", "openai/gpt-4o", "/v1/chat/completions", "gpt-4o", "anthropic,openai"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			llm := testharness.NewLLMServer(t)
+			llm.ReplyResult(testharness.DefaultResult)
+			llm.Reply(testharness.LLMReply{Text: `Verdict: {"score": 8, "reason": "clear enough"}`})
+
+			b := body
+			if tt.judge != "" {
+				b = `{"judge": "` + tt.judge + `", ` + b[1:]
+			}
+			suite, err := LoadSuite(writeSuite(t, b))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := suite.judgeFor(model); tt.judge == "" && got != model || tt.judge != "" && got.String() != tt.judge {
+				t.Errorf("judgeFor = %s", got)
+			}
+			var keys []string
+			rep, err := Run(suite, Options{
+				Models:    []ai.ModelRef{model},
+				OutputDir: t.TempDir(),
+				APIKey: func(id string) (string, error) {
+					keys = append(keys, id)
+					return "sk-" + id, nil
+				},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			reqs := llm.Requests()
+			if len(reqs) != 2 || reqs[1].Path != tt.wantPath || reqs[1].Body["model"] != tt.wantModel {
+				t.Fatalf("judge request: %+v", reqs)
+			}
+			if strings.Join(keys, ",") != tt.wantKeys {
+				t.Errorf("keys looked up for %v, want %s", keys, tt.wantKeys)
+			}
+			res := rep.Results[0]
+			if res.Error != "" || len(res.Checks) != 1 || !res.Checks[0].Passed || *res.Checks[0].Score != 8 || res.Score != 1 {
+				t.Errorf("result = %+v", res)
+			}
+		})
+	}
+}
@@ -0,0 +1,233 @@
+package eval
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/thecoolrobot/task-agent/internal/ai"
+)
+
+const (
+	reportJSON = "report.json"
+	reportMD   = "REPORT.md"
+)
+
+// Check is the outcome of one assertion.
+type Check struct {
+	Assertion string   `json:"assertion"`
+	Passed    bool     `json:"passed"`
+	Detail    string   `json:"detail,omitempty"`
+	Score     *float64 `json:"judge_score,omitempty"`
+}
+
+// Result is one case evaluated on one model.
+type Result struct {
+	Case      string   `json:"case"`
+	Model     string   `json:"model"`
+	Dir       string   `json:"dir"`
+	Score     float64  `json:"score"` // weighted pass ratio, 0–1
+	Passed    int      `json:"passed"`
+	Total     int      `json:"total"`
+	Parsed    bool     `json:"parsed"`
+	Usage     ai.Usage `json:"usage"`
+	CostUSD   *float64 `json:"cost_usd,omitempty"`
+	LatencyMS int64    `json:"latency_ms"`
+	Error     string   `json:"error,omitempty"`
+	Checks    []Check  `json:"checks"`
+}
+
+func (r *Result) score(c Case) {
+	r.Total = len(c.Assertions)
+	var got, possible float64
+	for i, a := range c.Assertions {
+		possible += a.weight()
+		if i < len(r.Checks) && r.Checks[i].Passed {
+			r.Passed++
+			got += a.weight()
+		}
+	}
+	switch {
+	case r.Error != "":
+		r.Score = 0
+	case possible == 0:
+		// No assertions: a parsed response is the only signal we have.
+		if r.Parsed {
+			r.Score = 1
+		}
+	default:
+		r.Score = got / possible
+	}
+}
+
+// ModelSummary aggregates a model's results across the suite.
+type ModelSummary struct {
+	Model   string   `json:"model"`
+	Score   float64  `json:"score"` // mean of case scores
+	Passed  int      `json:"passed"`
+	Total   int      `json:"total"`
+	Errors  int      `json:"errors"`
+	CostUSD *float64 `json:"cost_usd,omitempty"`
+}
+
+// Report is the scored outcome of an evaluation run. Results are sorted by
+// case then model so two reports diff cleanly.
+type Report struct {
+	Suite   string         `json:"suite"`
+	Created time.Time      `json:"created"`
+	Dir     string         `json:"dir"`
+	Models  []string       `json:"models"`
+	Summary []ModelSummary `json:"summary"`
+	Results []Result       `json:"results"`
+}
+
+func (r *Report) summarize() {
+	byModel := map[string]*ModelSummary{}
+	cases := map[string]int{}
+	for _, res := range r.Results {
+		s, ok := byModel[res.Model]
+		if !ok {
+			s = &ModelSummary{Model: res.Model}
+			byModel[res.Model] = s
+		}
+		s.Score += res.Score
+		s.Passed += res.Passed
+		s.Total += res.Total
+		cases[res.Model]++
+		if res.Error != "" {
+			s.Errors++
+		}
+		if res.CostUSD != nil {
+			if s.CostUSD == nil {
+				s.CostUSD = new(float64)
+			}
+			*s.CostUSD += *res.CostUSD
+		}
+	}
+	r.Summary = nil
+	for _, m := range r.Models {
+		if s, ok := byModel[m]; ok {
+			s.Score /= float64(cases[m])
+			r.Summary = append(r.Summary, *s)
+		}
+	}
+	sort.SliceStable(r.Summary, func(i, j int) bool { return r.Summary[i].Score > r.Summary[j].Score })
+}
+
+// Save writes report.json and REPORT.md into r.Dir.
+func (r *Report) Save() error {
+	if err := os.MkdirAll(r.Dir, 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(r.Dir, reportJSON), data, 0644); err != nil {
+		return fmt.Errorf("write %s: %w", reportJSON, err)
+	}
+	if err := os.WriteFile(filepath.Join(r.Dir, reportMD), []byte(r.Markdown()), 0644); err != nil {
+		return fmt.Errorf("write %s: %w", reportMD, err)
+	}
+	return nil
+}
+
+// LoadReport reads a report.json, or the report.json inside a run folder.
+func LoadReport(path string) (*Report, error) {
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		path = filepath.Join(path, reportJSON)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var r Report
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return &r, nil
+}
+
+// Markdown renders the summary and per-case results.
+func (r *Report) Markdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# 🧪 Eval: %s\n\n", r.Suite)
+	fmt.Fprintf(&b, "**Run:** %s\n\n", r.Created.Format("2006-01-02 15:04:05"))
+
+	fmt.Fprintf(&b, "## Summary\n\n| Model | Score | Assertions | Errors | Cost |\n|-------|------:|-----------:|-------:|-----:|\n")
+	for _, s := range r.Summary {
+		cost := "?"
+		if s.CostUSD != nil {
+			cost = fmt.Sprintf("$%.4f", *s.CostUSD)
+		}
+		fmt.Fprintf(&b, "| %s | %.1f%% | %d/%d | %d | %s |\n", s.Model, s.Score*100, s.Passed, s.Total, s.Errors, cost)
+	}
+
+	fmt.Fprintf(&b, "\n## Results\n")
+	for _, res := range r.Results {
+		fmt.Fprintf(&b, "\n### %s — %s (%.1f%%)\n\n", res.Case, res.Model, res.Score*100)
+		if res.Error != "" {
+			fmt.Fprintf(&b, "❌ %s\n", res.Error)
+			continue
+		}
+		for _, c := range res.Checks {
+			mark := "✅"
+			if !c.Passed {
+				mark = "❌"
+			}
+			detail := ""
+			if c.Detail != "" {
+				detail = " — " + firstLine(c.Detail)
+			}
+			fmt.Fprintf(&b, "- %s %s%s\n", mark, c.Assertion, detail)
+		}
+	}
+	return b.String()
+}
+
+func firstLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i] + " …"
+	}
+	return s
+}
+
+// Delta is the score change of one case/model pair between two reports.
+type Delta struct {
+	Case   string
+	Model  string
+	Before *float64 // nil when the pair is new
+	After  *float64 // nil when the pair was removed
+}
+
+// Diff compares the per-case scores of two reports.
+func Diff(before, after *Report) []Delta {
+	type key struct{ c, m string }
+	old := map[key]float64{}
+	for _, r := range before.Results {
+		old[key{r.Case, r.Model}] = r.Score
+	}
+	var out []Delta
+	seen := map[key]bool{}
+	for _, r := range after.Results {
+		k := key{r.Case, r.Model}
+		seen[k] = true
+		a := r.Score
+		d := Delta{Case: r.Case, Model: r.Model, After: &a}
+		if b, ok := old[k]; ok {
+			d.Before = &b
+		}
+		out = append(out, d)
+	}
+	for _, r := range before.Results {
+		if k := (key{r.Case, r.Model}); !seen[k] {
+			b := r.Score
+			out = append(out, Delta{Case: r.Case, Model: r.Model, Before: &b})
+		}
+	}
+	return out
+}
@@ -0,0 +1,269 @@
+package eval
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/thecoolrobot/task-agent/internal/ai"
+	"github.com/thecoolrobot/task-agent/internal/asana"
+	"github.com/thecoolrobot/task-agent/internal/output"
+	"github.com/thecoolrobot/task-agent/internal/verify"
+)
+
+// Options controls an evaluation run.
+type Options struct {
+	Models    []ai.ModelRef
+	OutputDir string
+	// APIKey resolves the key for a provider ID.
+	APIKey func(providerID string) (string, error)
+	// Asana fetches cases that reference a task GID; may be nil when the
+	// suite only uses task files.
+	Asana    *asana.Client
+	Progress func(string)
+	// CommandTimeout bounds go_build / go_test (default 5 minutes).
+	CommandTimeout time.Duration
+}
+
+// Run evaluates every case in suite against every model in opts and writes
+// report.json and REPORT.md into a new folder under opts.OutputDir.
+func Run(suite *Suite, opts Options) (*Report, error) {
+	if len(opts.Models) == 0 {
+		return nil, fmt.Errorf("no models to evaluate")
+	}
+	if opts.CommandTimeout == 0 {
+		opts.CommandTimeout = 5 * time.Minute
+	}
+	emit := func(s string) {
+		if opts.Progress != nil {
+			opts.Progress(s)
+		}
+	}
+
+	rep := &Report{
+		Suite:   suite.Name,
+		Created: time.Now(),
+	}
+	rep.Dir = filepath.Join(opts.OutputDir, time.Now().Format("20060102_150405")+"_eval_"+output.Sanitize(suite.Name))
+	for _, ref := range opts.Models {
+		rep.Models = append(rep.Models, ref.String())
+	}
+
+	for _, c := range suite.Cases {
+		task, taskMD, err := suite.loadTask(c, opts.Asana)
+		for _, ref := range opts.Models {
+			res := Result{
+				Case:  c.Name,
+				Model: ref.String(),
+				Dir:   filepath.Join(rep.Dir, output.Sanitize(ref.Provider+"_"+ref.Model), output.Sanitize(c.Name)),
+			}
+			if err != nil {
+				res.Error = err.Error()
+			} else {
+				emit(fmt.Sprintf("[%s] %s", ref, c.Name))
+				runCase(suite, c, task, taskMD, ref, &res, opts)
+			}
+			res.score(c)
+			emit(fmt.Sprintf("[%s] %s — %d/%d passed", ref, c.Name, res.Passed, res.Total))
+			rep.Results = append(rep.Results, res)
+		}
+	}
+
+	sort.SliceStable(rep.Results, func(i, j int) bool {
+		if rep.Results[i].Case != rep.Results[j].Case {
+			return rep.Results[i].Case < rep.Results[j].Case
+		}
+		return rep.Results[i].Model < rep.Results[j].Model
+	})
+	rep.summarize()
+	if err := rep.Save(); err != nil {
+		return rep, err
+	}
+	return rep, nil
+}
+
+func (s *Suite) loadTask(c Case, client *asana.Client) (*asana.Task, string, error) {
+	if c.GID != "" {
+		if client == nil {
+			return nil, "", fmt.Errorf("case %q needs asana-cli to fetch %s", c.Name, c.GID)
+		}
+		task, err := client.ViewTask(c.GID)
+		if err != nil {
+			return nil, "", err
+		}
+		return task, asana.FormatTaskMarkdown(task), nil
+	}
+	path := c.TaskFile
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(s.dir, path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", err
+	}
+	return &asana.Task{ID: "eval:" + c.Name, Name: c.Name, Notes: string(data)}, string(data), nil
+}
+
+func runCase(suite *Suite, c Case, task *asana.Task, taskMD string, ref ai.ModelRef, res *Result, opts Options) {
+	apiKey := ""
+	if opts.APIKey != nil {
+		var err error
+		if apiKey, err = opts.APIKey(ref.Provider); err != nil {
+			res.Error = err.Error()
+			return
+		}
+	}
+	ctx := context.Background()
+	exec, err := ai.NewClient(ref.Provider, ref.Model, apiKey).Run(ctx, taskMD, nil)
+	if err != nil {
+		res.Error = err.Error()
+		return
+	}
+	res.Usage = exec.Usage
+	res.LatencyMS = exec.Latency.Milliseconds()
+	res.Parsed = exec.ParseErr == nil
+	if cost, ok := exec.Cost(); ok {
+		res.CostUSD = &cost
+	}
+	if err := output.WriteTo(exec, task, res.Dir); err != nil {
+		res.Error = err.Error()
+		return
+	}
+
+	for _, a := range c.Assertions {
+		var chk Check
+		switch a.Type {
+		case AssertFileExists:
+			chk = checkFileExists(res.Dir, a)
+		case AssertRegex:
+			chk = checkRegex(res.Dir, a)
+		case AssertGoBuild:
+			chk = checkCommand(ctx, res.Dir, opts.CommandTimeout, "go build ./...")
+		case AssertGoTest:
+			chk = checkCommand(ctx, res.Dir, opts.CommandTimeout, "go test ./...")
+		case AssertJudge:
+			chk = checkJudge(ctx, suite.judgeFor(ref), opts.APIKey, taskMD, exec.Result, a)
+		}
+		chk.Assertion = a.Label()
+		res.Checks = append(res.Checks, chk)
+	}
+}
+
+// ─── Assertions ──────────────────────────────────────────────────────────────
+
+func checkFileExists(dir string, a Assertion) Check {
+	if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(a.Path))); err != nil {
+		return Check{Detail: "missing"}
+	}
+	return Check{Passed: true}
+}
+
+func checkRegex(dir string, a Assertion) Check {
+	re, err := regexp.Compile(a.Pattern)
+	if err != nil {
+		return Check{Detail: "bad pattern: " + err.Error()}
+	}
+	if a.Path != "" {
+		data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(a.Path)))
+		if err != nil {
+			return Check{Detail: "missing " + a.Path}
+		}
+		if re.Match(data) {
+			return Check{Passed: true}
+		}
+		return Check{Detail: "no match"}
+	}
+	var hit string
+	_ = filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() || hit != "" {
+			return nil
+		}
+		if data, err := os.ReadFile(path); err == nil && re.Match(data) {
+			hit, _ = filepath.Rel(dir, path)
+		}
+		return nil
+	})
+	if hit == "" {
+		return Check{Detail: "no match in any file"}
+	}
+	return Check{Passed: true, Detail: "matched " + filepath.ToSlash(hit)}
+}
+
+// checkCommand runs command in dir with the verification hook runner, so a
+// timeout also kills the test binaries it started. A failure keeps the end
+// of the output, where the compiler and go test summarise what went wrong.
+func checkCommand(ctx context.Context, dir string, timeout time.Duration, command string) Check {
+	res := verify.Exec(ctx, dir, command, timeout, verify.Config{})
+	switch res.Status {
+	case verify.Passed:
+		return Check{Passed: true}
+	case verify.TimedOut:
+		return Check{Detail: fmt.Sprintf("timed out after %s", timeout)}
+	}
+	return Check{Detail: verify.Tail(strings.TrimSpace(res.Stdout+res.Stderr), 30)}
+}
+
+const judgeSystemPrompt = `You are a strict reviewer grading the output of an autonomous coding agent.
+Score the deliverable against the rubric from 0 (useless) to 10 (flawless).
+Respond ONLY with a JSON object: {"score": <number>, "reason": "<one or two sentences>"}`
+
+func checkJudge(ctx context.Context, ref ai.ModelRef, apiKey func(string) (string, error), taskMD string, result *ai.TaskResult, a Assertion) Check {
+	key := ""
+	if apiKey != nil {
+		var err error
+		if key, err = apiKey(ref.Provider); err != nil {
+			return Check{Detail: "judge: " + err.Error()}
+		}
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "## Rubric\n\n%s\n\n## Task\n\n%s\n\n## Deliverable\n\nSummary: %s\n\n", a.Rubric, taskMD, result.Summary)
+	budget := 60000
+	for _, f := range result.Files {
+		if f.Binary() {
+			fmt.Fprintf(&b, "### %s\n(binary file)\n\n", f.Path)
+			continue
+		}
+		content := truncate(f.Content, 8000)
+		if budget-len(content) < 0 {
+			fmt.Fprintf(&b, "### %s\n(omitted — size budget exhausted)\n\n", f.Path)
+			continue
+		}
+		budget -= len(content)
+		fmt.Fprintf(&b, "### %s\n```\n%s\n```\n\n", f.Path, content)
+	}
+
+	resp, err := ai.NewClient(ref.Provider, ref.Model, key).Complete(ctx, judgeSystemPrompt, []ai.Message{{Role: "user", Content: b.String()}})
+	if err != nil {
+		return Check{Detail: "judge: " + err.Error()}
+	}
+	var verdict struct {
+		Score  float64 `json:"score"`
+		Reason string  `json:"reason"`
+	}
+	text := resp.Text
+	if i, j := strings.Index(text, "{"), strings.LastIndex(text, "}"); i >= 0 && j > i {
+		text = text[i : j+1]
+	}
+	if err := json.Unmarshal([]byte(text), &verdict); err != nil {
+		return Check{Detail: "judge returned unparseable verdict"}
+	}
+	score := verdict.Score
+	return Check{
+		Passed: score >= a.MinScore,
+		Detail: fmt.Sprintf("%g/10 — %s", score, verdict.Reason),
+		Score:  &score,
+	}
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "\n…(truncated)"
+}
@@ -0,0 +1,156 @@
+// Package eval benchmarks prompts and models against a suite of tasks with
+// assertions, producing a scored report that can be diffed between runs.
+package eval
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/thecoolrobot/task-agent/internal/ai"
+)
+
+// Suite is a set of evaluation cases, loaded from a JSON file.
+type Suite struct {
+	Name string `json:"name"`
+	// Models are provider/model refs to evaluate; overridable from the CLI.
+	Models []string `json:"models,omitempty"`
+	// Judge is the provider/model used for "judge" assertions. Defaults to
+	// the model under test.
+	Judge string `json:"judge,omitempty"`
+	Cases []Case `json:"cases"`
+
+	dir string // directory of the suite file, for resolving task_file
+}
+
+// Case is a single task plus the assertions its output must satisfy.
+// Exactly one of TaskFile and GID must be set.
+type Case struct {
+	Name       string      `json:"name"`
+	TaskFile   string      `json:"task_file,omitempty"`
+	GID        string      `json:"gid,omitempty"`
+	Assertions []Assertion `json:"assertions"`
+}
+
+// Assertion types.
+const (
+	AssertFileExists = "file_exists"
+	AssertRegex      = "regex"
+	AssertGoBuild    = "go_build"
+	AssertGoTest     = "go_test"
+	AssertJudge      = "judge"
+)
+
+// Assertion is one check against a case's output folder.
+type Assertion struct {
+	Type string `json:"type"`
+	// Path is the output-relative file for file_exists and regex. An empty
+	// path makes regex search every file.
+	Path    string `json:"path,omitempty"`
+	Pattern string `json:"pattern,omitempty"`
+	// Rubric and MinScore (0–10) configure the LLM judge.
+	Rubric   string  `json:"rubric,omitempty"`
+	MinScore float64 `json:"min_score,omitempty"`
+	// Weight scales the assertion's contribution to the score (default 1).
+	Weight float64 `json:"weight,omitempty"`
+}
+
+func (a Assertion) weight() float64 {
+	if a.Weight <= 0 {
+		return 1
+	}
+	return a.Weight
+}
+
+// Label is a short human description used in reports.
+func (a Assertion) Label() string {
+	switch a.Type {
+	case AssertFileExists:
+		return "file_exists " + a.Path
+	case AssertRegex:
+		if a.Path == "" {
+			return fmt.Sprintf("regex /%s/", a.Pattern)
+		}
+		return fmt.Sprintf("regex /%s/ in %s", a.Pattern, a.Path)
+	case AssertJudge:
+		return fmt.Sprintf("judge ≥ %g", a.MinScore)
+	default:
+		return a.Type
+	}
+}
+
+// LoadSuite reads and validates a suite file.
+func LoadSuite(path string) (*Suite, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var s Suite
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("parse suite %s: %w", path, err)
+	}
+	s.dir = filepath.Dir(path)
+	if s.Name == "" {
+		s.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	if err := s.validate(); err != nil {
+		return nil, fmt.Errorf("suite %s: %w", path, err)
+	}
+	return &s, nil
+}
+
+func (s *Suite) validate() error {
+	if len(s.Cases) == 0 {
+		return fmt.Errorf("no cases")
+	}
+	if s.Judge != "" {
+		if _, err := ai.ParseModelRef(s.Judge); err != nil {
+			return fmt.Errorf("judge: %w", err)
+		}
+	}
+	names := map[string]bool{}
+	for i, c := range s.Cases {
+		if c.Name == "" {
+			return fmt.Errorf("case %d has no name", i+1)
+		}
+		if names[c.Name] {
+			return fmt.Errorf("duplicate case name %q", c.Name)
+		}
+		names[c.Name] = true
+		if (c.TaskFile == "") == (c.GID == "") {
+			return fmt.Errorf("case %q: set exactly one of task_file and gid", c.Name)
+		}
+		for _, a := range c.Assertions {
+			switch a.Type {
+			case AssertFileExists:
+				if a.Path == "" {
+					return fmt.Errorf("case %q: file_exists needs a path", c.Name)
+				}
+			case AssertRegex:
+				if a.Pattern == "" {
+					return fmt.Errorf("case %q: regex needs a pattern", c.Name)
+				}
+			case AssertJudge:
+				if a.Rubric == "" {
+					return fmt.Errorf("case %q: judge needs a rubric", c.Name)
+				}
+			case AssertGoBuild, AssertGoTest:
+			default:
+				return fmt.Errorf("case %q: unknown assertion type %q", c.Name, a.Type)
+			}
+		}
+	}
+	return nil
+}
+
+// judgeFor returns the model that grades ref's judge assertions: the
+// suite's judge, else ref itself. validate has checked the judge ref.
+func (s *Suite) judgeFor(ref ai.ModelRef) ai.ModelRef {
+	if s.Judge == "" {
+		return ref
+	}
+	judge, _ := ai.ParseModelRef(s.Judge)
+	return judge
+}
@@ -0,0 +1,138 @@
+// Package export packages an output folder for sharing: as a zip or tar.gz
+// archive, or as a single self-contained HTML report.
+package export
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/thecoolrobot/task-agent/internal/output"
+)
+
+// Supported formats.
+const (
+	Zip   = "zip"
+	TarGz = "tar.gz"
+	HTML  = "html"
+)
+
+// Formats lists the supported formats in display order.
+var Formats = []string{Zip, TarGz, HTML}
+
+// DefaultPath is where Export writes when no destination is given: next to
+// the folder, named after it.
+func DefaultPath(dir, format string) string {
+	return filepath.Clean(dir) + "." + format
+}
+
+// Export writes dir in format to dest (DefaultPath when empty) and returns
+// the path written. Run metadata in output.MetaDir is left out; the
+// manifests are included.
+func Export(dir, format, dest string) (string, error) {
+	if info, err := os.Stat(dir); err != nil {
+		return "", err
+	} else if !info.IsDir() {
+		return "", fmt.Errorf("%s is not a directory", dir)
+	}
+	switch format {
+	case Zip, TarGz, HTML:
+	default:
+		return "", fmt.Errorf("unknown format %q (want %s)", format, strings.Join(Formats, ", "))
+	}
+	if dest == "" {
+		dest = DefaultPath(dir, format)
+	}
+	files, err := output.Files(dir)
+	if err != nil {
+		return "", err
+	}
+
+	f, err := os.Create(dest)
+	if err != nil {
+		return "", err
+	}
+	switch format {
+	case Zip:
+		err = writeZip(f, dir, files)
+	case TarGz:
+		err = writeTarGz(f, dir, files)
+	case HTML:
+		err = writeHTML(f, dir, files)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(dest)
+		return "", err
+	}
+	return dest, nil
+}
+
+func writeZip(w io.Writer, dir string, files []string) error {
+	zw := zip.NewWriter(w)
+	root := filepath.Base(dir)
+	for _, rel := range files {
+		info, err := os.Stat(filepath.Join(dir, filepath.FromSlash(rel)))
+		if err != nil {
+			return err
+		}
+		hdr, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return err
+		}
+		hdr.Name = root + "/" + rel
+		hdr.Method = zip.Deflate
+		dst, err := zw.CreateHeader(hdr)
+		if err != nil {
+			return err
+		}
+		if err := copyFile(dst, dir, rel); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+func writeTarGz(w io.Writer, dir string, files []string) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	root := filepath.Base(dir)
+	for _, rel := range files {
+		info, err := os.Stat(filepath.Join(dir, filepath.FromSlash(rel)))
+		if err != nil {
+			return err
+		}
+		hdr, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = root + "/" + rel
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if err := copyFile(tw, dir, rel); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+func copyFile(dst io.Writer, dir, rel string) error {
+	src, err := os.Open(filepath.Join(dir, filepath.FromSlash(rel)))
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	_, err = io.Copy(dst, src)
+	return err
+}
@@ -0,0 +1,130 @@
+package export
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/thecoolrobot/task-agent/internal/ai"
+	"github.com/thecoolrobot/task-agent/internal/asana"
+	"github.com/thecoolrobot/task-agent/internal/output"
+)
+
+func writeRun(t *testing.T) string {
+	t.Helper()
+	dir := filepath.Join(t.TempDir(), "20260101_000000_Demo")
+	exec := &ai.Execution{ProviderID: "anthropic", Model: "claude-sonnet-4-6", Result: &ai.TaskResult{
+		OutputType: "code_folder",
+		Summary:    "Built <the> thing",
+		Notes:      "Remember the tests",
+		Files: []ai.OutputFile{
+			{Path: "main.go", Content: "package main\n\nfunc main() {}\n"},
+			{Path: "docs/guide.md", Content: "# Guide\n"},
+		},
+	}}
+	if err := output.WriteTo(exec, &asana.Task{GID: "7", Name: "Demo"}, dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := output.WriteMeta(dir, "verify.log", []byte("secret log")); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+var wantFiles = []string{"AGENT_MANIFEST.md", "agent-manifest.json", "docs/guide.md", "main.go"}
+
+func TestExportArchives(t *testing.T) {
+	dir := writeRun(t)
+
+	path, err := Export(dir, Zip, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != dir+".zip" {
+		t.Errorf("default path = %s", path)
+	}
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zr.Close()
+	var got []string
+	for _, f := range zr.File {
+		got = append(got, strings.TrimPrefix(f.Name, filepath.Base(dir)+"/"))
+	}
+	if !reflect.DeepEqual(got, wantFiles) {
+		t.Errorf("zip entries = %v, want %v", got, wantFiles)
+	}
+
+	path, err = Export(dir, TarGz, filepath.Join(t.TempDir(), "out.tgz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, _ := os.Open(path)
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gz)
+	got = nil
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, strings.TrimPrefix(hdr.Name, filepath.Base(dir)+"/"))
+	}
+	if !reflect.DeepEqual(got, wantFiles) {
+		t.Errorf("tar entries = %v, want %v", got, wantFiles)
+	}
+}
+
+func TestExportHTML(t *testing.T) {
+	dir := writeRun(t)
+	path, err := Export(dir, HTML, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	page := string(data)
+	for _, want := range []string{
+		"<title>Demo — task-agent</title>",
+		"Built &lt;the&gt; thing",
+		"Remember the tests",
+		"claude-sonnet-4-6",
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("report missing %q", want)
+		}
+	}
+	if !strings.Contains(page, `<li class="dir"><span>docs/</span>`) || !strings.Contains(page, `href="#file-`) {
+		t.Error("report has no file tree")
+	}
+	// Highlighted Go: the keyword gets its own span class.
+	if !strings.Contains(page, `<span class="kn">package</span>`) {
+		t.Error("main.go was not syntax-highlighted")
+	}
+	if strings.Contains(page, "secret log") || strings.Contains(page, "<link") || strings.Contains(page, "<script") {
+		t.Error("report includes metadata or external resources")
+	}
+}
+
+func TestExportUnknownFormat(t *testing.T) {
+	dir := writeRun(t)
+	if _, err := Export(dir, "rar", ""); err == nil {
+		t.Fatal("want error for unknown format")
+	}
+	if _, err := os.Stat(dir + ".rar"); !os.IsNotExist(err) {
+		t.Error("failed export left a file behind")
+	}
+}
@@ -0,0 +1,211 @@
+package export
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/alecthomas/chroma/v2"
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+
+	"github.com/thecoolrobot/task-agent/internal/output"
+)
+
+// maxHighlight caps how much of a single file the HTML report embeds.
+const maxHighlight = 512 << 10
+
+var style = styles.Get("github")
+
+// reportFile is one file section of the HTML report.
+type reportFile struct {
+	Path   string
+	Anchor string
+	Size   int64
+	Note   string // shown instead of, or after, the contents
+	Code   template.HTML
+	Image  template.URL // data URI for images
+}
+
+// imageTypes are the binary formats the report embeds as images.
+var imageTypes = map[string]string{
+	".png":  "image/png",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".gif":  "image/gif",
+	".webp": "image/webp",
+}
+
+// treeNode is a folder or file in the report's file tree.
+type treeNode struct {
+	Name     string
+	Anchor   string // empty for folders
+	Children []*treeNode
+}
+
+type report struct {
+	Title    string
+	Manifest *output.Manifest
+	CSS      template.CSS
+	Tree     []*treeNode
+	Files    []reportFile
+}
+
+// writeHTML renders dir as a single HTML page with inline CSS: the
+// manifest's metadata, summary and notes, a file tree and every file
+// syntax-highlighted.
+func writeHTML(w io.Writer, dir string, files []string) error {
+	m, err := output.LoadManifest(dir)
+	if err != nil {
+		// Folders written before agent-manifest.json existed still export;
+		// they just lack the metadata.
+		m = &output.Manifest{}
+	}
+	r := report{Title: m.Task.Name, Manifest: m}
+	if r.Title == "" {
+		r.Title = filepath.Base(dir)
+	}
+
+	formatter := chromahtml.New(chromahtml.WithClasses(true), chromahtml.TabWidth(4))
+	var css bytes.Buffer
+	if err := formatter.WriteCSS(&css, style); err != nil {
+		return err
+	}
+	r.CSS = template.CSS(css.String())
+
+	for i, rel := range files {
+		f, err := renderFile(formatter, dir, rel)
+		if err != nil {
+			return err
+		}
+		f.Anchor = fmt.Sprintf("file-%d", i+1)
+		r.Files = append(r.Files, f)
+	}
+	r.Tree = buildTree(r.Files)
+	return reportTmpl.Execute(w, r)
+}
+
+func renderFile(formatter *chromahtml.Formatter, dir, rel string) (reportFile, error) {
+	f := reportFile{Path: rel}
+	data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(rel)))
+	if err != nil {
+		return f, err
+	}
+	f.Size = int64(len(data))
+	if typ, ok := imageTypes[strings.ToLower(path.Ext(rel))]; ok && len(data) <= maxHighlight {
+		f.Image = template.URL("data:" + typ + ";base64," + base64.StdEncoding.EncodeToString(data))
+		return f, nil
+	}
+	if bytes.IndexByte(data, 0) >= 0 || !utf8.Valid(data) {
+		f.Note = "Binary file — not shown."
+		return f, nil
+	}
+	if len(data) > maxHighlight {
+		data = data[:maxHighlight]
+		f.Note = fmt.Sprintf("Truncated — showing the first %d KB.", maxHighlight>>10)
+	}
+
+	lexer := lexers.Match(path.Base(rel))
+	if lexer == nil {
+		lexer = lexers.Analyse(string(data))
+	}
+	if lexer == nil {
+		lexer = lexers.Fallback
+	}
+	it, err := chroma.Coalesce(lexer).Tokenise(nil, string(data))
+	if err != nil {
+		return f, fmt.Errorf("highlight %s: %w", rel, err)
+	}
+	var b bytes.Buffer
+	if err := formatter.Format(&b, style, it); err != nil {
+		return f, fmt.Errorf("highlight %s: %w", rel, err)
+	}
+	f.Code = template.HTML(b.String())
+	return f, nil
+}
+
+// buildTree nests the sorted file list into folders.
+func buildTree(files []reportFile) []*treeNode {
+	root := &treeNode{}
+	for _, f := range files {
+		node := root
+		parts := strings.Split(f.Path, "/")
+		for _, dir := range parts[:len(parts)-1] {
+			var next *treeNode
+			for _, c := range node.Children {
+				if c.Name == dir && c.Anchor == "" {
+					next = c
+				}
+			}
+			if next == nil {
+				next = &treeNode{Name: dir}
+				node.Children = append(node.Children, next)
+			}
+			node = next
+		}
+		node.Children = append(node.Children, &treeNode{Name: parts[len(parts)-1], Anchor: f.Anchor})
+	}
+	return root.Children
+}
+
+var reportTmpl = template.Must(template.New("report").Funcs(template.FuncMap{
+	"size": output.FormatSize,
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}} — task-agent</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; max-width: 1000px; margin: 2rem auto; padding: 0 1rem; color: #1f2328; line-height: 1.5; }
+h1 { border-bottom: 1px solid #d0d7de; padding-bottom: .3rem; }
+table.meta td { padding: 2px 12px 2px 0; vertical-align: top; }
+table.meta td:first-child { color: #656d76; }
+.summary, .notes { white-space: pre-wrap; background: #f6f8fa; border-radius: 6px; padding: .75rem 1rem; }
+ul.tree { list-style: none; padding-left: 1.2rem; font-family: ui-monospace, Menlo, Consolas, monospace; font-size: 90%; }
+ul.tree > li.dir > span { color: #656d76; }
+section.file { margin: 1.5rem 0; border: 1px solid #d0d7de; border-radius: 6px; overflow: hidden; }
+section.file h3 { margin: 0; padding: .5rem 1rem; background: #f6f8fa; border-bottom: 1px solid #d0d7de; font-family: ui-monospace, Menlo, Consolas, monospace; font-size: 90%; }
+section.file h3 small { color: #656d76; font-weight: normal; }
+section.file pre { margin: 0; padding: .75rem 1rem; overflow-x: auto; font-size: 85%; }
+.note { padding: .5rem 1rem; color: #656d76; font-style: italic; }
+.status-verified { color: #1a7f37; } .status-failed { color: #cf222e; }
+{{.CSS}}
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+{{with .Manifest}}<table class="meta">
+{{if .Task.GID}}<tr><td>Task</td><td>{{.Task.GID}}</td></tr>{{end}}
+{{if .Model}}<tr><td>Model</td><td>{{.Provider}} / {{.Model}}</td></tr>{{end}}
+{{if not .CreatedAt.IsZero}}<tr><td>Generated</td><td>{{.CreatedAt.Format "2006-01-02 15:04 MST"}}</td></tr>{{end}}
+{{if .Usage.InputTokens}}<tr><td>Tokens</td><td>{{.Usage.InputTokens}} in / {{.Usage.OutputTokens}} out</td></tr>{{end}}
+{{if .OutputType}}<tr><td>Output type</td><td>{{.OutputType}}</td></tr>{{end}}
+{{with .Verification}}<tr><td>Verification</td><td class="status-{{.Status}}">{{.Status}}</td></tr>{{end}}
+</table>
+{{if .Summary}}<h2>Summary</h2>
+<div class="summary">{{.Summary}}</div>{{end}}
+{{if .Notes}}<h2>Notes</h2>
+<div class="notes">{{.Notes}}</div>{{end}}
+{{if .Rejected}}<h2>Rejected files</h2>
+<ul>{{range .Rejected}}<li><code>{{.Path}}</code> — {{.Reason}}</li>{{end}}</ul>{{end}}
+{{end}}
+<h2>Files</h2>
+{{define "tree"}}<ul class="tree">{{range .}}{{if .Anchor}}<li><a href="#{{.Anchor}}">{{.Name}}</a></li>{{else}}<li class="dir"><span>{{.Name}}/</span>{{template "tree" .Children}}</li>{{end}}{{end}}</ul>{{end}}
+{{template "tree" .Tree}}
+{{range .Files}}<section class="file" id="{{.Anchor}}">
+<h3>{{.Path}} <small>{{size .Size}}</small></h3>
+{{with .Image}}<img src="{{.}}" alt="" style="max-width:100%; padding:1rem">{{end}}{{.Code}}{{with .Note}}<div class="note">{{.}}</div>{{end}}
+</section>
+{{end}}
+</body>
+</html>
+`))
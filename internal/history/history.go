@@ -0,0 +1,145 @@
+// Package history records the conversation behind each output folder so a
+// past run can be listed, resumed and revised.
+package history
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/thecoolrobot/task-agent/internal/ai"
+	"github.com/thecoolrobot/task-agent/internal/output"
+)
+
+// File is the conversation record inside an output folder's MetaDir.
+const File = "conversation.json"
+
+// RevisionsDir holds superseded revisions, one numbered folder each, inside
+// output.MetaDir.
+const RevisionsDir = "revisions"
+
+// Conversation is everything needed to resume a run: the task, the system
+// prompt, the model and every turn so far.
+type Conversation struct {
+	TaskGID  string         `json:"task_gid"`
+	TaskName string         `json:"task_name"`
+	Provider string         `json:"provider"`
+	Model    string         `json:"model"`
+	System   string         `json:"system"`
+	Messages []ai.Message   `json:"messages"`
+	Result   *ai.TaskResult `json:"result"`
+	// Revision is the number of the files currently in the folder; earlier
+	// ones live in .agent/revisions/<n>/.
+	Revision  int        `json:"revision"`
+	Revisions []Revision `json:"revisions"`
+	Created   time.Time  `json:"created"`
+	Updated   time.Time  `json:"updated"`
+}
+
+// Revision records how one revision of the output was produced.
+type Revision struct {
+	N       int       `json:"n"`
+	Created time.Time `json:"created"`
+	// Prompt is the follow-up message that produced the revision; empty for
+	// the original run.
+	Prompt string   `json:"prompt,omitempty"`
+	Usage  ai.Usage `json:"usage"`
+	Status string   `json:"verification,omitempty"`
+}
+
+// New starts a conversation from a finished execution.
+func New(taskGID, taskName string, exec *ai.Execution) *Conversation {
+	now := time.Now()
+	return &Conversation{
+		TaskGID:   taskGID,
+		TaskName:  taskName,
+		Provider:  exec.ProviderID,
+		Model:     exec.Model,
+		System:    ai.SystemPrompt(),
+		Messages:  exec.Messages,
+		Result:    exec.Result,
+		Revision:  1,
+		Revisions: []Revision{{N: 1, Created: now, Usage: exec.Usage}},
+		Created:   now,
+		Updated:   now,
+	}
+}
+
+// Current returns the latest revision record.
+func (c *Conversation) Current() *Revision {
+	return &c.Revisions[len(c.Revisions)-1]
+}
+
+// Load reads the conversation of the output folder dir.
+func Load(dir string) (*Conversation, error) {
+	data, err := os.ReadFile(filepath.Join(dir, output.MetaDir, File))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%s has no recorded conversation", dir)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var c Conversation
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", File, err)
+	}
+	if len(c.Revisions) == 0 {
+		c.Revisions = []Revision{{N: c.Revision, Created: c.Created}}
+	}
+	return &c, nil
+}
+
+// Save writes the conversation into dir.
+func (c *Conversation) Save(dir string) error {
+	c.Updated = time.Now()
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return output.WriteMeta(dir, File, data)
+}
+
+// Run is a past run found in the output directory.
+type Run struct {
+	ID           string // folder path relative to the output directory
+	Dir          string
+	Conversation *Conversation
+}
+
+// List returns the runs under outputDir that have a recorded conversation,
+// newest first.
+func List(outputDir string) ([]Run, error) {
+	dirs, err := output.RunDirs(outputDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var runs []Run
+	for _, dir := range dirs {
+		conv, err := Load(dir)
+		if err != nil {
+			continue
+		}
+		id, _ := filepath.Rel(outputDir, dir)
+		runs = append(runs, Run{ID: filepath.ToSlash(id), Dir: dir, Conversation: conv})
+	}
+	sort.SliceStable(runs, func(i, j int) bool {
+		return runs[i].Conversation.Updated.After(runs[j].Conversation.Updated)
+	})
+	return runs, nil
+}
+
+// Resolve finds a run folder from a folder name under outputDir or a path.
+func Resolve(outputDir, id string) (string, error) {
+	for _, dir := range []string{id, filepath.Join(outputDir, id)} {
+		if _, err := os.Stat(filepath.Join(dir, output.MetaDir, File)); err == nil {
+			return dir, nil
+		}
+	}
+	return "", fmt.Errorf("no run %q in %s", id, outputDir)
+}
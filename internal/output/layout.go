@@ -0,0 +1,381 @@
+package output
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aymanbagabas/go-udiff"
+
+	"github.com/thecoolrobot/task-agent/internal/ai"
+	"github.com/thecoolrobot/task-agent/internal/asana"
+)
+
+// Output folder layouts.
+const (
+	// LayoutTimestamp writes each run to <OutputDir>/<timestamp>_<task-name>/.
+	LayoutTimestamp = "timestamp"
+	// LayoutTask writes each run to <OutputDir>/<task-gid>/rev-<N>/, with a
+	// revision index and a latest pointer, so reruns of a task stay together
+	// even if it is renamed.
+	LayoutTask = "task"
+)
+
+// Layouts lists the supported layouts, default first.
+var Layouts = []string{LayoutTimestamp, LayoutTask}
+
+// IndexFile lists the revisions of a task in the task layout.
+const IndexFile = "revisions.json"
+
+// Latest points at the newest revision of a task: a symlink where the
+// platform allows one, otherwise a file holding the revision's folder name.
+const Latest = "latest"
+
+// Index is the revision index of one task's folder.
+type Index struct {
+	TaskGID   string       `json:"task_gid"`
+	TaskName  string       `json:"task_name"`
+	Latest    int          `json:"latest"`
+	Revisions []IndexEntry `json:"revisions"`
+}
+
+// IndexEntry describes one revision.
+type IndexEntry struct {
+	N        int       `json:"n"`
+	Dir      string    `json:"dir"` // relative to the task folder
+	Created  time.Time `json:"created"`
+	Provider string    `json:"provider,omitempty"`
+	Model    string    `json:"model,omitempty"`
+	Summary  string    `json:"summary,omitempty"`
+	// Status is the verification status once known.
+	Status string `json:"status,omitempty"`
+}
+
+// TaskDir is the folder holding every revision of a task in the task layout.
+func TaskDir(outputDir, gid string) string {
+	return filepath.Join(outputDir, Sanitize(gid))
+}
+
+// RevisionName is the folder name of revision n.
+func RevisionName(n int) string { return "rev-" + strconv.Itoa(n) }
+
+func revisionNumber(name string) (int, bool) {
+	s, ok := strings.CutPrefix(name, "rev-")
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.Atoi(s)
+	return n, err == nil && n > 0
+}
+
+// LoadIndex reads the revision index in taskDir.
+func LoadIndex(taskDir string) (*Index, error) {
+	data, err := os.ReadFile(filepath.Join(taskDir, IndexFile))
+	if err != nil {
+		return nil, fmt.Errorf("read revision index: %w", err)
+	}
+	var ix Index
+	if err := json.Unmarshal(data, &ix); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", IndexFile, err)
+	}
+	return &ix, nil
+}
+
+// Save writes the index into taskDir.
+func (ix *Index) Save(taskDir string) error {
+	data, err := json.MarshalIndent(ix, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(taskDir, IndexFile), append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("write %s: %w", IndexFile, err)
+	}
+	return nil
+}
+
+// Entry returns revision n, or nil.
+func (ix *Index) Entry(n int) *IndexEntry {
+	for i := range ix.Revisions {
+		if ix.Revisions[i].N == n {
+			return &ix.Revisions[i]
+		}
+	}
+	return nil
+}
+
+// Resolve turns a revision reference — "", "latest", "3" or "rev-3" — into
+// a revision number.
+func (ix *Index) Resolve(ref string) (int, error) {
+	if ref == "" || ref == Latest {
+		if ix.Latest == 0 {
+			return 0, fmt.Errorf("task %s has no revisions", ix.TaskGID)
+		}
+		return ix.Latest, nil
+	}
+	n, ok := revisionNumber(ref)
+	if !ok {
+		n, ok = revisionNumber("rev-" + ref)
+	}
+	if !ok || ix.Entry(n) == nil {
+		return 0, fmt.Errorf("task %s has no revision %q", ix.TaskGID, ref)
+	}
+	return n, nil
+}
+
+// Previous returns the revision before n, or 0.
+func (ix *Index) Previous(n int) int {
+	prev := 0
+	for _, e := range ix.Revisions {
+		if e.N < n && e.N > prev {
+			prev = e.N
+		}
+	}
+	return prev
+}
+
+// WriteRevision saves the result of exec as the next revision of task under
+// outputDir in the task layout and returns the revision folder.
+func WriteRevision(exec *ai.Execution, task *asana.Task, outputDir string) (string, error) {
+	taskDir := TaskDir(outputDir, task.GetID())
+	if err := os.MkdirAll(taskDir, 0755); err != nil {
+		return "", fmt.Errorf("create output dir: %w", err)
+	}
+	ix, err := LoadIndex(taskDir)
+	if errors.Is(err, fs.ErrNotExist) {
+		ix, err = &Index{TaskGID: task.GetID()}, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	// Number after both the index and the folders on disk so a stale index
+	// never reuses a folder.
+	n := 0
+	for _, e := range ix.Revisions {
+		n = max(n, e.N)
+	}
+	if entries, err := os.ReadDir(taskDir); err == nil {
+		for _, e := range entries {
+			if k, ok := revisionNumber(e.Name()); ok {
+				n = max(n, k)
+			}
+		}
+	}
+	n++
+
+	name := RevisionName(n)
+	dir := filepath.Join(taskDir, name)
+	if err := WriteTo(exec, task, dir); err != nil {
+		return "", err
+	}
+	ix.TaskName = task.Name
+	ix.Latest = n
+	ix.Revisions = append(ix.Revisions, IndexEntry{
+		N:        n,
+		Dir:      name,
+		Created:  time.Now().UTC(),
+		Provider: exec.ProviderID,
+		Model:    exec.Model,
+		Summary:  exec.Result.Summary,
+	})
+	if err := ix.Save(taskDir); err != nil {
+		return "", err
+	}
+	return dir, setLatest(taskDir, n)
+}
+
+// setLatest points taskDir/latest at revision n, or removes it when n is 0.
+func setLatest(taskDir string, n int) error {
+	link := filepath.Join(taskDir, Latest)
+	if info, err := os.Lstat(link); err == nil && !info.IsDir() {
+		if err := os.Remove(link); err != nil {
+			return fmt.Errorf("update %s: %w", Latest, err)
+		}
+	}
+	if n == 0 {
+		return nil
+	}
+	if os.Symlink(RevisionName(n), link) == nil {
+		return nil
+	}
+	if err := os.WriteFile(link, []byte(RevisionName(n)+"\n"), 0644); err != nil {
+		return fmt.Errorf("update %s: %w", Latest, err)
+	}
+	return nil
+}
+
+// SetRevisionStatus records the verification status of the revision in
+// dir. It does nothing for folders outside the task layout.
+func SetRevisionStatus(dir, status string) error {
+	taskDir := filepath.Dir(dir)
+	n, ok := revisionNumber(filepath.Base(dir))
+	if !ok {
+		return nil
+	}
+	ix, err := LoadIndex(taskDir)
+	if err != nil {
+		return nil
+	}
+	if e := ix.Entry(n); e != nil && e.Status != status {
+		e.Status = status
+		return ix.Save(taskDir)
+	}
+	return nil
+}
+
+// dropRevision removes a deleted revision folder from its task's index and
+// moves the latest pointer back if needed. The task folder itself goes once
+// its last revision is gone.
+func dropRevision(dir string) error {
+	taskDir := filepath.Dir(dir)
+	n, ok := revisionNumber(filepath.Base(dir))
+	if !ok {
+		return nil
+	}
+	ix, err := LoadIndex(taskDir)
+	if err != nil {
+		return nil
+	}
+	kept := ix.Revisions[:0]
+	for _, e := range ix.Revisions {
+		if e.N != n {
+			kept = append(kept, e)
+		}
+	}
+	ix.Revisions = kept
+	if ix.Latest == n {
+		ix.Latest = ix.Previous(n)
+		if err := setLatest(taskDir, ix.Latest); err != nil {
+			return err
+		}
+	}
+	if len(ix.Revisions) == 0 {
+		if err := os.Remove(filepath.Join(taskDir, IndexFile)); err != nil {
+			return err
+		}
+		os.Remove(taskDir) // only if nothing else is left in it
+		return nil
+	}
+	return ix.Save(taskDir)
+}
+
+// revisionDirs lists the revision folders of a task-layout folder.
+func revisionDirs(taskDir string) []string {
+	entries, err := os.ReadDir(taskDir)
+	if err != nil {
+		return nil
+	}
+	var dirs []string
+	for _, e := range entries {
+		if _, ok := revisionNumber(e.Name()); ok && e.IsDir() {
+			dirs = append(dirs, filepath.Join(taskDir, e.Name()))
+		}
+	}
+	return dirs
+}
+
+// RunDirs lists the run folders directly inside outputDir and, for the task
+// layout, the revision folders one level down. Other entries are returned
+// too; callers check them for a manifest or conversation.
+func RunDirs(outputDir string) ([]string, error) {
+	entries, err := os.ReadDir(outputDir)
+	if err != nil {
+		return nil, err
+	}
+	var dirs []string
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		dir := filepath.Join(outputDir, e.Name())
+		if _, err := os.Stat(filepath.Join(dir, IndexFile)); err == nil {
+			dirs = append(dirs, revisionDirs(dir)...)
+			continue
+		}
+		dirs = append(dirs, dir)
+	}
+	return dirs, nil
+}
+
+// Files lists the regular files of dir as sorted slash-separated relative
+// paths, skipping MetaDir.
+func Files(dir string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && d.Name() == MetaDir {
+			return filepath.SkipDir
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		files = append(files, filepath.ToSlash(rel))
+		return nil
+	})
+	sort.Strings(files)
+	return files, err
+}
+
+// Diff returns a unified diff of the deliverables in folders a and b,
+// labelled with labelA and labelB, and the number of files that differ.
+// The manifests are left out: they always differ in their timestamps.
+func Diff(a, b, labelA, labelB string) (string, int, error) {
+	filesA, err := Files(a)
+	if err != nil {
+		return "", 0, err
+	}
+	filesB, err := Files(b)
+	if err != nil {
+		return "", 0, err
+	}
+	paths := map[string]bool{}
+	for _, f := range append(filesA, filesB...) {
+		if f != ManifestFile && f != "AGENT_MANIFEST.md" {
+			paths[f] = true
+		}
+	}
+	sorted := make([]string, 0, len(paths))
+	for p := range paths {
+		sorted = append(sorted, p)
+	}
+	sort.Strings(sorted)
+
+	var out strings.Builder
+	changed := 0
+	for _, p := range sorted {
+		before, okA := readText(filepath.Join(a, filepath.FromSlash(p)))
+		after, okB := readText(filepath.Join(b, filepath.FromSlash(p)))
+		if before == after {
+			continue
+		}
+		changed++
+		if !okA || !okB {
+			fmt.Fprintf(&out, "Binary files %s/%s and %s/%s differ\n", labelA, p, labelB, p)
+			continue
+		}
+		out.WriteString(udiff.Unified(labelA+"/"+p, labelB+"/"+p, before, after))
+	}
+	return out.String(), changed, nil
+}
+
+// readText returns a file's contents and whether it is text. A missing file
+// reads as empty text.
+func readText(path string) (string, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", true
+	}
+	return string(data), !strings.ContainsRune(string(data), 0)
+}
@@ -0,0 +1,128 @@
+package output
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/thecoolrobot/task-agent/internal/ai"
+	"github.com/thecoolrobot/task-agent/internal/verify"
+)
+
+func TestWriteRevision(t *testing.T) {
+	out := t.TempDir()
+	first, err := WriteRevision(demoExec(
+		ai.OutputFile{Path: "main.go", Content: "package main\n"},
+		ai.OutputFile{Path: "old.txt", Content: "gone soon\n"},
+	), demoTask, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	taskDir := TaskDir(out, demoTask.GetID())
+	if first != filepath.Join(taskDir, "rev-1") {
+		t.Errorf("first revision = %s", first)
+	}
+	// A folder left on disk without an index entry is never reused.
+	if err := os.Mkdir(filepath.Join(taskDir, "rev-2"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	second, err := WriteRevision(demoExec(
+		ai.OutputFile{Path: "main.go", Content: "package main\n\nfunc main() {}\n"},
+	), demoTask, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second != filepath.Join(taskDir, "rev-3") {
+		t.Errorf("second revision = %s", second)
+	}
+
+	ix, err := LoadIndex(taskDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ix.TaskGID != "7" || ix.TaskName != "Demo task" || ix.Latest != 3 || len(ix.Revisions) != 2 {
+		t.Fatalf("index = %+v", ix)
+	}
+	if e := ix.Entry(3); e == nil || e.Dir != "rev-3" || e.Provider != "anthropic" || e.Summary != "Built the thing" {
+		t.Errorf("entry 3 = %+v", e)
+	}
+	if target, err := os.Readlink(filepath.Join(taskDir, Latest)); err == nil {
+		if target != "rev-3" {
+			t.Errorf("latest -> %s", target)
+		}
+	} else if data, err := os.ReadFile(filepath.Join(taskDir, Latest)); err != nil || strings.TrimSpace(string(data)) != "rev-3" {
+		t.Errorf("latest = %q, %v", data, err)
+	}
+
+	for ref, want := range map[string]int{"": 3, "latest": 3, "1": 1, "rev-1": 1, "rev-3": 3} {
+		if n, err := ix.Resolve(ref); err != nil || n != want {
+			t.Errorf("Resolve(%q) = %d, %v; want %d", ref, n, err, want)
+		}
+	}
+	for _, ref := range []string{"2", "rev-9", "rev-0", "x"} {
+		if _, err := ix.Resolve(ref); err == nil {
+			t.Errorf("Resolve(%q) succeeded", ref)
+		}
+	}
+	if p := ix.Previous(3); p != 1 {
+		t.Errorf("Previous(3) = %d", p)
+	}
+	if p := ix.Previous(1); p != 0 {
+		t.Errorf("Previous(1) = %d", p)
+	}
+
+	if err := SetRevisionStatus(second, verify.StatusFailed); err != nil {
+		t.Fatal(err)
+	}
+	if ix, err = LoadIndex(taskDir); err != nil || ix.Entry(3).Status != verify.StatusFailed {
+		t.Errorf("status not recorded: %+v, %v", ix, err)
+	}
+	if err := SetRevisionStatus(filepath.Join(out, "20260101_000000_Demo"), verify.StatusFailed); err != nil {
+		t.Errorf("timestamp folder: %v", err)
+	}
+
+	dirs, err := RunDirs(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dirs) != 3 {
+		t.Errorf("RunDirs = %v", dirs)
+	}
+
+	diff, changed, err := Diff(first, second, "rev-1", "rev-3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed != 2 {
+		t.Errorf("changed = %d, want 2", changed)
+	}
+	for _, want := range []string{"--- rev-1/main.go", "+++ rev-3/main.go", "+func main() {}", "--- rev-1/old.txt", "-gone soon"} {
+		if !strings.Contains(diff, want) {
+			t.Errorf("diff missing %q:\n%s", want, diff)
+		}
+	}
+	if strings.Contains(diff, ManifestFile) {
+		t.Errorf("diff includes the manifest:\n%s", diff)
+	}
+}
+
+func TestFiles(t *testing.T) {
+	dir := t.TempDir()
+	if err := WriteTo(demoExec(
+		ai.OutputFile{Path: "b/c.txt", Content: "c"},
+		ai.OutputFile{Path: "a.txt", Content: "a"},
+	), demoTask, dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteMeta(dir, "verify.log", []byte("log")); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Files(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "AGENT_MANIFEST.md a.txt agent-manifest.json b/c.txt"; strings.Join(got, " ") != want {
+		t.Errorf("Files = %v, want %s", got, want)
+	}
+}
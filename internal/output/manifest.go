@@ -0,0 +1,227 @@
+package output
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/thecoolrobot/task-agent/internal/ai"
+	"github.com/thecoolrobot/task-agent/internal/asana"
+	"github.com/thecoolrobot/task-agent/internal/verify"
+)
+
+// ManifestFile is the machine-readable manifest written next to
+// AGENT_MANIFEST.md.
+const ManifestFile = "agent-manifest.json"
+
+// ManifestVersion is the schema version of agent-manifest.json. Bump it on
+// incompatible changes.
+const ManifestVersion = 1
+
+// Manifest describes an output folder: where it came from and exactly which
+// files it holds.
+type Manifest struct {
+	Version      int                   `json:"version"`
+	Task         ManifestTask          `json:"task"`
+	Provider     string                `json:"provider,omitempty"`
+	Model        string                `json:"model,omitempty"`
+	CreatedAt    time.Time             `json:"created_at"`
+	UpdatedAt    time.Time             `json:"updated_at"`
+	LatencyMS    int64                 `json:"latency_ms,omitempty"`
+	Usage        ai.Usage              `json:"usage"`
+	OutputType   string                `json:"output_type"`
+	Summary      string                `json:"summary"`
+	Notes        string                `json:"notes,omitempty"`
+	Files        []ManifestEntry       `json:"files"`
+	Rejected     []RejectedFile        `json:"rejected,omitempty"`
+	Verification *ManifestVerification `json:"verification,omitempty"`
+}
+
+// ManifestTask identifies the Asana task a folder was generated for.
+type ManifestTask struct {
+	GID      string   `json:"gid"`
+	Name     string   `json:"name"`
+	Priority string   `json:"priority,omitempty"`
+	DueDate  string   `json:"due_date,omitempty"`
+	Assignee string   `json:"assignee,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+}
+
+// ManifestEntry is a file written to the folder.
+type ManifestEntry struct {
+	Path        string `json:"path"` // slash-separated, relative to the folder
+	Size        int64  `json:"size"`
+	SHA256      string `json:"sha256"`
+	Description string `json:"description,omitempty"`
+	// Encoding is "base64" when the model sent the content encoded; Size and
+	// SHA256 always describe the decoded bytes on disk.
+	Encoding string `json:"encoding,omitempty"`
+	// Mode is the octal permission when it isn't the default 0644.
+	Mode string `json:"mode,omitempty"`
+}
+
+// RejectedFile is a file the model returned that was not written.
+type RejectedFile struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+// ManifestVerification summarises the verification hooks run on the folder.
+// Captured output stays in .agent/verify.log.
+type ManifestVerification struct {
+	Status    string          `json:"status"`
+	Languages []string        `json:"languages"`
+	Results   []verify.Result `json:"results"`
+}
+
+// Verification converts a report for the manifest, dropping captured output.
+func Verification(rep *verify.Report) *ManifestVerification {
+	v := &ManifestVerification{Status: rep.Status(), Languages: rep.Languages}
+	for _, r := range rep.Results {
+		r.Stdout, r.Stderr = "", ""
+		v.Results = append(v.Results, r)
+	}
+	return v
+}
+
+// reject reports why a file path from the model may not be written, or ""
+// when it is safe: paths must stay inside the folder and not clobber the
+// manifests or run metadata.
+func reject(p string) string {
+	if strings.TrimSpace(p) == "" {
+		return "empty path"
+	}
+	slash := filepath.ToSlash(p)
+	if path.IsAbs(slash) || filepath.IsAbs(p) || filepath.VolumeName(p) != "" {
+		return "absolute path"
+	}
+	clean := path.Clean(slash)
+	if clean == ".." || strings.HasPrefix(clean, "../") {
+		return "escapes the output folder"
+	}
+	if clean == "." {
+		return "empty path"
+	}
+	if clean == MetaDir || strings.HasPrefix(clean, MetaDir+"/") {
+		return "reserved for run metadata"
+	}
+	if clean == ManifestFile || clean == "AGENT_MANIFEST.md" {
+		return "reserved for the manifest"
+	}
+	return ""
+}
+
+// newManifest starts a manifest for exec; files are added as they are
+// written.
+func newManifest(exec *ai.Execution, task *asana.Task) *Manifest {
+	now := time.Now().UTC()
+	return &Manifest{
+		Version:    ManifestVersion,
+		Task:       manifestTask(task),
+		Provider:   exec.ProviderID,
+		Model:      exec.Model,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+		LatencyMS:  exec.Latency.Milliseconds(),
+		Usage:      exec.Usage,
+		OutputType: exec.Result.OutputType,
+		Summary:    exec.Result.Summary,
+		Notes:      exec.Result.Notes,
+		Files:      []ManifestEntry{},
+	}
+}
+
+func manifestTask(task *asana.Task) ManifestTask {
+	t := ManifestTask{GID: task.GetID(), Name: task.Name, Priority: task.Priority, DueDate: task.DueDate, Assignee: task.Assignee.Name}
+	for _, tag := range task.Tags {
+		t.Tags = append(t.Tags, tag.Name)
+	}
+	return t
+}
+
+func hashBytes(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// LoadManifest reads agent-manifest.json from dir.
+func LoadManifest(dir string) (*Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, ManifestFile))
+	if err != nil {
+		return nil, fmt.Errorf("read manifest: %w", err)
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", ManifestFile, err)
+	}
+	return &m, nil
+}
+
+// Save writes the manifest into dir.
+func (m *Manifest) Save(dir string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, ManifestFile), append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("write %s: %w", ManifestFile, err)
+	}
+	return nil
+}
+
+// UpdateManifest loads the manifest in dir, applies fn and saves it with a
+// fresh UpdatedAt.
+func UpdateManifest(dir string, fn func(*Manifest)) error {
+	m, err := LoadManifest(dir)
+	if err != nil {
+		return err
+	}
+	fn(m)
+	m.UpdatedAt = time.Now().UTC()
+	return m.Save(dir)
+}
+
+// Validate checks the files on disk in dir against the manifest and returns
+// one message per problem; nil means the folder is intact.
+func (m *Manifest) Validate(dir string) []string {
+	var problems []string
+	if m.Version < 1 || m.Version > ManifestVersion {
+		problems = append(problems, fmt.Sprintf("unsupported manifest version %d (this build understands up to %d)", m.Version, ManifestVersion))
+	}
+	for _, f := range m.Files {
+		if reason := reject(f.Path); reason != "" {
+			problems = append(problems, fmt.Sprintf("%s: invalid path (%s)", f.Path, reason))
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(f.Path)))
+		if err != nil {
+			if os.IsNotExist(err) {
+				problems = append(problems, fmt.Sprintf("%s: missing", f.Path))
+			} else {
+				problems = append(problems, fmt.Sprintf("%s: %v", f.Path, err))
+			}
+			continue
+		}
+		if int64(len(data)) != f.Size {
+			problems = append(problems, fmt.Sprintf("%s: size %d, manifest says %d", f.Path, len(data), f.Size))
+		}
+		if got := hashBytes(data); got != f.SHA256 {
+			problems = append(problems, fmt.Sprintf("%s: sha256 mismatch", f.Path))
+		}
+		if f.Mode != "" && runtime.GOOS != "windows" {
+			want, _ := strconv.ParseUint(f.Mode, 8, 32)
+			if info, err := os.Stat(filepath.Join(dir, filepath.FromSlash(f.Path))); err == nil && uint64(info.Mode().Perm()) != want {
+				problems = append(problems, fmt.Sprintf("%s: mode %04o, manifest says %s", f.Path, info.Mode().Perm(), f.Mode))
+			}
+		}
+	}
+	return problems
+}
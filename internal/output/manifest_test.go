@@ -0,0 +1,125 @@
+package output
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/thecoolrobot/task-agent/internal/ai"
+)
+
+func TestManifestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(t *testing.T, dir string, m *Manifest)
+		want   []string // substrings, one per problem; nil means intact
+	}{
+		{"intact", func(*testing.T, string, *Manifest) {}, nil},
+		{"missing file", func(t *testing.T, dir string, _ *Manifest) {
+			if err := os.Remove(filepath.Join(dir, "main.go")); err != nil {
+				t.Fatal(err)
+			}
+		}, []string{"main.go: missing"}},
+		{"edited file", func(t *testing.T, dir string, _ *Manifest) {
+			if err := os.WriteFile(filepath.Join(dir, "docs", "guide.md"), []byte("# Changed guide\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}, []string{"docs/guide.md: size", "docs/guide.md: sha256 mismatch"}},
+		{"same size, different content", func(t *testing.T, dir string, _ *Manifest) {
+			if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte("package mian\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}, []string{"main.go: sha256 mismatch"}},
+		{"escaping entry", func(_ *testing.T, _ string, m *Manifest) {
+			m.Files = append(m.Files, ManifestEntry{Path: "../../etc/passwd"})
+		}, []string{"../../etc/passwd: invalid path (escapes the output folder)"}},
+		{"absolute entry", func(_ *testing.T, _ string, m *Manifest) {
+			m.Files = append(m.Files, ManifestEntry{Path: "/etc/passwd"})
+		}, []string{"/etc/passwd: invalid path (absolute path)"}},
+		{"future version", func(_ *testing.T, _ string, m *Manifest) {
+			m.Version = ManifestVersion + 1
+		}, []string{"unsupported manifest version"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := filepath.Join(t.TempDir(), "run")
+			exec := demoExec(
+				ai.OutputFile{Path: "main.go", Content: "package main\n"},
+				ai.OutputFile{Path: "docs/guide.md", Content: "# Guide\n"},
+			)
+			if err := WriteTo(exec, demoTask, dir); err != nil {
+				t.Fatal(err)
+			}
+			m, err := LoadManifest(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.change(t, dir, m)
+			problems := m.Validate(dir)
+			if len(problems) != len(tt.want) {
+				t.Fatalf("problems = %q, want %d", problems, len(tt.want))
+			}
+			for i, want := range tt.want {
+				if !strings.Contains(problems[i], want) {
+					t.Errorf("problem %d = %q, want %q", i, problems[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestManifestValidateMode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file modes are not checked on Windows")
+	}
+	dir := t.TempDir()
+	if err := WriteTo(demoExec(ai.OutputFile{Path: "run.sh", Content: "#!/bin/sh\n", Mode: ai.ModeExecutable}), demoTask, dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(filepath.Join(dir, "run.sh"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	m, err := LoadManifest(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if problems := m.Validate(dir); len(problems) != 1 || !strings.Contains(problems[0], "run.sh: mode 0644, manifest says 0755") {
+		t.Errorf("problems = %q", problems)
+	}
+}
+
+func TestUpdateManifest(t *testing.T) {
+	dir := t.TempDir()
+	if err := WriteTo(demoExec(), demoTask, dir); err != nil {
+		t.Fatal(err)
+	}
+	before, err := LoadManifest(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := UpdateManifest(dir, func(m *Manifest) { m.Notes = "checked" }); err != nil {
+		t.Fatal(err)
+	}
+	after, err := LoadManifest(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.Notes != "checked" || after.UpdatedAt.Before(before.UpdatedAt) || !after.CreatedAt.Equal(before.CreatedAt) {
+		t.Errorf("after update: %+v", after)
+	}
+	if after.Task.GID != "7" || after.Provider != "anthropic" {
+		t.Errorf("manifest lost fields: %+v", after)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, ManifestFile), []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadManifest(dir); err == nil {
+		t.Error("loaded a corrupt manifest")
+	}
+	if _, err := LoadManifest(t.TempDir()); err == nil {
+		t.Error("loaded a missing manifest")
+	}
+}
@@ -0,0 +1,220 @@
+package output
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/thecoolrobot/task-agent/internal/verify"
+)
+
+// Policy selects output folders to delete. Zero fields are off; a folder is
+// pruned when any enabled rule matches it.
+type Policy struct {
+	// KeepPerTask keeps the newest N folders of each task.
+	KeepPerTask int `json:"keep_per_task,omitempty"`
+	// OlderThanDays prunes folders created more than this many days ago.
+	OlderThanDays int `json:"older_than_days,omitempty"`
+	// MaxTotalMB prunes the oldest folders until the task-agent folders in
+	// the output directory fit in this many megabytes.
+	MaxTotalMB int `json:"max_total_mb,omitempty"`
+	// FailedOnly restricts pruning to runs whose verification failed. On its
+	// own it prunes every failed run.
+	FailedOnly bool `json:"failed_only,omitempty"`
+	// Auto prunes after each run.
+	Auto bool `json:"auto,omitempty"`
+}
+
+// Enabled reports whether the policy selects anything.
+func (p Policy) Enabled() bool {
+	return p.KeepPerTask > 0 || p.OlderThanDays > 0 || p.MaxTotalMB > 0 || p.FailedOnly
+}
+
+// Folder is a task-agent output folder found in an output directory.
+type Folder struct {
+	Dir      string
+	Manifest *Manifest
+	Size     int64
+}
+
+// Created is when the folder was written.
+func (f Folder) Created() time.Time { return f.Manifest.CreatedAt }
+
+// Failed reports whether the folder's verification failed.
+func (f Folder) Failed() bool {
+	return f.Manifest.Verification != nil && f.Manifest.Verification.Status == verify.StatusFailed
+}
+
+// Pruned is a folder selected by a Policy.
+type Pruned struct {
+	Folder
+	Reason string
+}
+
+// Folders lists the task-agent output folders in outputDir, newest first:
+// the run folders at the top and, for the task layout, the revision folders
+// inside each task folder (see RunDirs). Only folders whose
+// agent-manifest.json parses and has a version count; anything else is
+// ignored. A missing outputDir has no folders.
+func Folders(outputDir string) ([]Folder, error) {
+	dirs, err := RunDirs(outputDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var folders []Folder
+	for _, dir := range dirs {
+		m, err := LoadManifest(dir)
+		if err != nil || m.Version < 1 {
+			continue
+		}
+		size, err := dirSize(dir)
+		if err != nil {
+			return nil, err
+		}
+		folders = append(folders, Folder{Dir: dir, Manifest: m, Size: size})
+	}
+	sort.SliceStable(folders, func(i, j int) bool { return folders[i].Created().After(folders[j].Created()) })
+	return folders, nil
+}
+
+func dirSize(dir string) (int64, error) {
+	var n int64
+	err := filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			n += info.Size()
+		}
+		return nil
+	})
+	return n, err
+}
+
+// Select returns the folders p would prune, oldest first. Folders in keep
+// are never selected.
+func (p Policy) Select(folders []Folder, now time.Time, keep ...string) []Pruned {
+	protected := map[string]bool{}
+	for _, k := range keep {
+		protected[filepath.Clean(k)] = true
+	}
+	matches := func(f Folder) bool { return !p.FailedOnly || f.Failed() }
+	eligible := func(f Folder) bool { return matches(f) && !protected[filepath.Clean(f.Dir)] }
+
+	reasons := map[string]string{}
+	var total int64
+	perTask := map[string]int{}
+	for _, f := range folders { // newest first
+		total += f.Size
+		if !matches(f) {
+			continue
+		}
+		// Protected folders still count towards KeepPerTask.
+		perTask[f.Manifest.Task.GID]++
+		if !eligible(f) {
+			continue
+		}
+		switch {
+		case p.KeepPerTask > 0 && perTask[f.Manifest.Task.GID] > p.KeepPerTask:
+			reasons[f.Dir] = fmt.Sprintf("more than %d run(s) of this task", p.KeepPerTask)
+		case p.OlderThanDays > 0 && now.Sub(f.Created()) > time.Duration(p.OlderThanDays)*24*time.Hour:
+			reasons[f.Dir] = fmt.Sprintf("older than %d day(s)", p.OlderThanDays)
+		case p.FailedOnly && p.KeepPerTask == 0 && p.OlderThanDays == 0 && p.MaxTotalMB == 0:
+			reasons[f.Dir] = "verification failed"
+		}
+	}
+	for _, f := range folders {
+		if reasons[f.Dir] != "" {
+			total -= f.Size
+		}
+	}
+	if limit := int64(p.MaxTotalMB) << 20; p.MaxTotalMB > 0 {
+		for i := len(folders) - 1; i >= 0 && total > limit; i-- { // oldest first
+			f := folders[i]
+			if reasons[f.Dir] == "" && eligible(f) {
+				reasons[f.Dir] = fmt.Sprintf("over the %d MB total", p.MaxTotalMB)
+				total -= f.Size
+			}
+		}
+	}
+
+	var out []Pruned
+	for i := len(folders) - 1; i >= 0; i-- {
+		if r := reasons[folders[i].Dir]; r != "" {
+			out = append(out, Pruned{Folder: folders[i], Reason: r})
+		}
+	}
+	return out
+}
+
+// Prune deletes the folders in outputDir selected by p, except those in
+// keep, and returns them. With dryRun nothing is deleted.
+func Prune(outputDir string, p Policy, dryRun bool, keep ...string) ([]Pruned, error) {
+	if !p.Enabled() {
+		return nil, nil
+	}
+	folders, err := Folders(outputDir)
+	if err != nil {
+		return nil, err
+	}
+	selected := p.Select(folders, time.Now(), keep...)
+	if dryRun {
+		return selected, nil
+	}
+	for i, f := range selected {
+		// Re-check right before deleting: never remove a folder task-agent
+		// did not write.
+		if _, err := LoadManifest(f.Dir); err != nil {
+			return selected[:i], fmt.Errorf("refusing to delete %s: %w", f.Dir, err)
+		}
+		if err := os.RemoveAll(f.Dir); err != nil {
+			return selected[:i], fmt.Errorf("delete %s: %w", f.Dir, err)
+		}
+		if err := dropRevision(f.Dir); err != nil {
+			return selected[:i+1], err
+		}
+	}
+	return selected, nil
+}
+
+// FormatSize renders a byte count for display.
+func FormatSize(n int64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.1f GB", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", n)
+}
+
+// Describe summarises p for display.
+func (p Policy) Describe() string {
+	var parts []string
+	if p.KeepPerTask > 0 {
+		parts = append(parts, fmt.Sprintf("keep %d per task", p.KeepPerTask))
+	}
+	if p.OlderThanDays > 0 {
+		parts = append(parts, fmt.Sprintf("older than %dd", p.OlderThanDays))
+	}
+	if p.MaxTotalMB > 0 {
+		parts = append(parts, fmt.Sprintf("max %d MB", p.MaxTotalMB))
+	}
+	if p.FailedOnly {
+		parts = append(parts, "failed runs only")
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,164 @@
+package output
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/thecoolrobot/task-agent/internal/asana"
+	"github.com/thecoolrobot/task-agent/internal/verify"
+)
+
+var now = time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+// folder builds a Folder for task gid created daysAgo days before now.
+func folder(name, gid string, daysAgo int, size int64, failed bool) Folder {
+	m := &Manifest{Version: ManifestVersion, Task: ManifestTask{GID: gid}, CreatedAt: now.AddDate(0, 0, -daysAgo)}
+	if failed {
+		m.Verification = &ManifestVerification{Status: verify.StatusFailed}
+	}
+	return Folder{Dir: name, Manifest: m, Size: size}
+}
+
+func TestPolicySelect(t *testing.T) {
+	// Newest first, as Folders returns them.
+	folders := []Folder{
+		folder("a3", "A", 1, 1<<20, false),
+		folder("b2", "B", 2, 1<<20, true),
+		folder("a2", "A", 5, 1<<20, true),
+		folder("b1", "B", 20, 1<<20, false),
+		folder("a1", "A", 40, 1<<20, false),
+	}
+	tests := []struct {
+		name   string
+		policy Policy
+		keep   []string
+		want   string // selected dirs, oldest first
+	}{
+		{"off", Policy{}, nil, ""},
+		{"keep per task", Policy{KeepPerTask: 1}, nil, "a1 b1 a2"},
+		{"keep per task protects", Policy{KeepPerTask: 1}, []string{"a2"}, "a1 b1"},
+		{"older than", Policy{OlderThanDays: 10}, nil, "a1 b1"},
+		{"failed only", Policy{FailedOnly: true}, nil, "a2 b2"},
+		{"failed and older", Policy{FailedOnly: true, OlderThanDays: 3}, nil, "a2"},
+		{"max total", Policy{MaxTotalMB: 3}, nil, "a1 b1"},
+		{"max total after age", Policy{MaxTotalMB: 3, OlderThanDays: 30}, nil, "a1 b1"},
+		{"max total skips kept", Policy{MaxTotalMB: 3}, []string{"a1"}, "b1 a2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, p := range tt.policy.Select(folders, now, tt.keep...) {
+				if p.Reason == "" {
+					t.Errorf("%s selected without a reason", p.Dir)
+				}
+				got = append(got, p.Dir)
+			}
+			if s := strings.Join(got, " "); s != tt.want {
+				t.Errorf("selected %q, want %q", s, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrune(t *testing.T) {
+	out := t.TempDir()
+	rev1, err := WriteRevision(demoExec(), demoTask, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rev2, err := WriteRevision(demoExec(), demoTask, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := filepath.Join(out, "20260101_000000_Other")
+	if err := WriteTo(demoExec(), &asana.Task{GID: "8", Name: "Other"}, other); err != nil {
+		t.Fatal(err)
+	}
+	for dir, days := range map[string]int{rev1: 30, rev2: 20, other: 10} {
+		created := time.Now().AddDate(0, 0, -days)
+		if err := UpdateManifest(dir, func(m *Manifest) { m.CreatedAt = created }); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Not written by task-agent: never listed or pruned.
+	stray := filepath.Join(out, "notes")
+	if err := os.MkdirAll(stray, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	folders, err := Folders(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, f := range folders {
+		if f.Size == 0 {
+			t.Errorf("%s: size 0", f.Dir)
+		}
+		got = append(got, f.Dir)
+	}
+	if want := []string{other, rev2, rev1}; strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("Folders = %v, want %v", got, want)
+	}
+	if f, err := Folders(filepath.Join(out, "missing")); err != nil || f != nil {
+		t.Errorf("missing dir: %v, %v", f, err)
+	}
+
+	p := Policy{KeepPerTask: 1}
+	pruned, err := Prune(out, p, true)
+	if err != nil || len(pruned) != 1 || pruned[0].Dir != rev1 {
+		t.Fatalf("dry run = %+v, %v", pruned, err)
+	}
+	if _, err := os.Stat(rev1); err != nil {
+		t.Error("dry run deleted a folder")
+	}
+	if _, err := Prune(out, p, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(rev1); !os.IsNotExist(err) {
+		t.Error("rev-1 not deleted")
+	}
+	taskDir := TaskDir(out, demoTask.GetID())
+	ix, err := LoadIndex(taskDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ix.Revisions) != 1 || ix.Revisions[0].N != 2 || ix.Latest != 2 {
+		t.Errorf("index after prune = %+v", ix)
+	}
+
+	// Dropping the last revision removes the task folder.
+	pruned, err = Prune(out, Policy{OlderThanDays: 15}, false)
+	if err != nil || len(pruned) != 1 || pruned[0].Dir != rev2 {
+		t.Fatalf("pruned = %+v, %v", pruned, err)
+	}
+	if _, err := os.Stat(taskDir); !os.IsNotExist(err) {
+		t.Errorf("task folder left behind: %v", err)
+	}
+	for _, dir := range []string{other, stray} {
+		if _, err := os.Stat(dir); err != nil {
+			t.Errorf("%s removed", dir)
+		}
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	for n, want := range map[int64]string{0: "0 B", 1023: "1023 B", 1536: "1.5 KB", 10 << 20: "10.0 MB", 3 << 30: "3.0 GB"} {
+		if got := FormatSize(n); got != want {
+			t.Errorf("FormatSize(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
+
+func TestPolicyDescribe(t *testing.T) {
+	p := Policy{KeepPerTask: 3, OlderThanDays: 30, MaxTotalMB: 500, FailedOnly: true}
+	if got, want := p.Describe(), "keep 3 per task, older than 30d, max 500 MB, failed runs only"; got != want {
+		t.Errorf("Describe() = %q, want %q", got, want)
+	}
+	if (Policy{Auto: true}).Enabled() {
+		t.Error("Auto alone enables pruning")
+	}
+}
@@ -2,10 +2,14 @@
 package output
 
 import (
+	"encoding/base64"
 	"fmt"
+	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
@@ -15,7 +19,8 @@ import (
 
 var safeName = regexp.MustCompile(`[^a-zA-Z0-9\-_. ]`)
 
-func sanitize(name string) string {
+// Sanitize makes name safe to use as a single path element.
+func Sanitize(name string) string {
 	safe := safeName.ReplaceAllString(name, "_")
 	safe = strings.TrimSpace(safe)
 	safe = strings.ReplaceAll(safe, " ", "_")
@@ -25,38 +30,245 @@ func sanitize(name string) string {
 	return safe
 }
 
-// Write saves an AI TaskResult to disk and returns the output folder path.
-func Write(result *ai.TaskResult, task *asana.Task, outputDir string) (string, error) {
+// FolderName returns the timestamped folder name used for a task's output.
+func FolderName(task *asana.Task) string {
 	timestamp := time.Now().Format("20060102_150405")
-	folderName := fmt.Sprintf("%s_%s", timestamp, sanitize(task.Name))
-	outPath := filepath.Join(outputDir, folderName)
+	return fmt.Sprintf("%s_%s", timestamp, Sanitize(task.Name))
+}
+
+// Write saves the result of exec to disk and returns the output folder path.
+func Write(exec *ai.Execution, task *asana.Task, outputDir string) (string, error) {
+	outPath := filepath.Join(outputDir, FolderName(task))
+	if err := WriteTo(exec, task, outPath); err != nil {
+		return "", err
+	}
+	return outPath, nil
+}
 
+// Size limits for decoded file content. Files over MaxFileBytes, or that
+// would take the folder over MaxTotalBytes, are rejected.
+var (
+	MaxFileBytes  = 10 << 20
+	MaxTotalBytes = 50 << 20
+)
+
+// decode returns the bytes to write for f.
+func decode(f ai.OutputFile) ([]byte, error) {
+	switch strings.ToLower(f.Encoding) {
+	case "", ai.EncodingUTF8, "utf8", "text":
+		return []byte(f.Content), nil
+	case ai.EncodingBase64:
+		// Models wrap long base64 lines; ignore the whitespace.
+		clean := strings.Join(strings.Fields(f.Content), "")
+		data, err := base64.StdEncoding.DecodeString(clean)
+		if err != nil {
+			data, err = base64.RawStdEncoding.DecodeString(strings.TrimRight(clean, "="))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid base64")
+		}
+		return data, nil
+	}
+	return nil, fmt.Errorf("unknown encoding %q", f.Encoding)
+}
+
+// fileMode returns the permissions for f: 0644 unless Mode asks for an
+// executable or gives an octal mode, which must leave the file readable
+// and writable by its owner.
+func fileMode(f ai.OutputFile) (fs.FileMode, error) {
+	switch strings.ToLower(f.Mode) {
+	case "", "regular":
+		return 0644, nil
+	case ai.ModeExecutable:
+		return 0755, nil
+	}
+	n, err := strconv.ParseUint(f.Mode, 8, 32)
+	if err != nil || n > 0777 || n&0600 != 0600 {
+		return 0, fmt.Errorf("invalid mode %q", f.Mode)
+	}
+	return fs.FileMode(n), nil
+}
+
+// WriteTo saves the result of exec into an exact folder, creating it if
+// needed, along with AGENT_MANIFEST.md and agent-manifest.json. Files are
+// skipped and listed as rejected when their paths would escape the folder
+// or overwrite run metadata, their encoding or mode is invalid, or they
+// exceed the size limits.
+func WriteTo(exec *ai.Execution, task *asana.Task, outPath string) error {
 	if err := os.MkdirAll(outPath, 0755); err != nil {
-		return "", fmt.Errorf("create output dir: %w", err)
+		return fmt.Errorf("create output dir: %w", err)
 	}
 
 	// Write each file
+	result := exec.Result
+	m := newManifest(exec, task)
+	total := 0
 	for _, f := range result.Files {
+		reason := reject(f.Path)
+		var data []byte
+		var mode fs.FileMode
+		if reason == "" {
+			var err error
+			if data, err = decode(f); err != nil {
+				reason = err.Error()
+			} else if mode, err = fileMode(f); err != nil {
+				reason = err.Error()
+			} else if len(data) > MaxFileBytes {
+				reason = fmt.Sprintf("%s exceeds the %s file limit", FormatSize(int64(len(data))), FormatSize(int64(MaxFileBytes)))
+			} else if total+len(data) > MaxTotalBytes {
+				reason = fmt.Sprintf("output exceeds the %s total limit", FormatSize(int64(MaxTotalBytes)))
+			}
+		}
+		if reason != "" {
+			m.Rejected = append(m.Rejected, RejectedFile{Path: f.Path, Reason: reason})
+			continue
+		}
+		total += len(data)
+
 		filePath := filepath.Join(outPath, filepath.FromSlash(f.Path))
 		if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
-			return "", fmt.Errorf("create dir for %s: %w", f.Path, err)
+			return fmt.Errorf("create dir for %s: %w", f.Path, err)
+		}
+		if err := os.WriteFile(filePath, data, mode); err != nil {
+			return fmt.Errorf("write %s: %w", f.Path, err)
+		}
+		// WriteFile's mode is filtered by the umask; set it exactly.
+		if err := os.Chmod(filePath, mode); err != nil {
+			return fmt.Errorf("chmod %s: %w", f.Path, err)
 		}
-		if err := os.WriteFile(filePath, []byte(f.Content), 0644); err != nil {
-			return "", fmt.Errorf("write %s: %w", f.Path, err)
+		entry := ManifestEntry{
+			Path:        path.Clean(filepath.ToSlash(f.Path)),
+			Size:        int64(len(data)),
+			SHA256:      hashBytes(data),
+			Description: f.Description,
 		}
+		if f.Binary() {
+			entry.Encoding = ai.EncodingBase64
+		}
+		if mode != 0644 {
+			entry.Mode = fmt.Sprintf("%04o", mode)
+		}
+		m.Files = append(m.Files, entry)
 	}
 
 	// Write manifest
-	manifest := buildManifest(result, task)
+	manifest := buildManifest(result, task, m)
 	manifestPath := filepath.Join(outPath, "AGENT_MANIFEST.md")
 	if err := os.WriteFile(manifestPath, []byte(manifest), 0644); err != nil {
-		return "", fmt.Errorf("write manifest: %w", err)
+		return fmt.Errorf("write manifest: %w", err)
 	}
+	return m.Save(outPath)
+}
 
-	return outPath, nil
+// MetaDir is the hidden folder inside an output folder that holds run
+// metadata (logs, conversation history) rather than deliverables.
+const MetaDir = ".agent"
+
+// WriteMeta writes a metadata file into the output folder's MetaDir.
+func WriteMeta(outPath, name string, data []byte) error {
+	dir := filepath.Join(outPath, MetaDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("create %s: %w", MetaDir, err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+		return fmt.Errorf("write %s: %w", name, err)
+	}
+	return nil
+}
+
+// AppendManifest adds a markdown section to AGENT_MANIFEST.md, before the
+// footer.
+func AppendManifest(outPath, section string) error {
+	manifestPath := filepath.Join(outPath, "AGENT_MANIFEST.md")
+	data, err := os.ReadFile(manifestPath)
+	if err != nil {
+		return fmt.Errorf("read manifest: %w", err)
+	}
+	body, footer, _ := strings.Cut(string(data), manifestFooter)
+	manifest := strings.TrimRight(body, "\n") + "\n\n" + strings.TrimRight(section, "\n") + "\n" + manifestFooter + footer
+	if err := os.WriteFile(manifestPath, []byte(manifest), 0644); err != nil {
+		return fmt.Errorf("write manifest: %w", err)
+	}
+	return nil
 }
 
-func buildManifest(result *ai.TaskResult, task *asana.Task) string {
+// Archive moves the files listed in outPath's manifest, and the manifests
+// themselves, into dest, leaving outPath ready for a revised result.
+// Metadata files named in meta move from MetaDir to the top of dest.
+// Missing files are ignored. Anything else left outside MetaDir, such as
+// build artifacts from verification, is removed so it is not mistaken for
+// part of the next result. Without a readable manifest everything outside
+// MetaDir is archived.
+func Archive(outPath, dest string, meta ...string) error {
+	moves := map[string]string{} // from → to, relative
+	if m, err := LoadManifest(outPath); err == nil {
+		moves["AGENT_MANIFEST.md"], moves[ManifestFile] = "AGENT_MANIFEST.md", ManifestFile
+		for _, f := range m.Files {
+			// The manifest is a plain file in the folder; never let an
+			// edited entry move something from outside it or from MetaDir.
+			if reject(f.Path) != "" {
+				continue
+			}
+			p := filepath.FromSlash(path.Clean(f.Path))
+			moves[p] = p
+		}
+	} else {
+		entries, err := os.ReadDir(outPath)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if e.Name() != MetaDir {
+				moves[e.Name()] = e.Name()
+			}
+		}
+	}
+	for _, name := range meta {
+		moves[filepath.Join(MetaDir, name)] = name
+	}
+	for p, rel := range moves {
+		src := filepath.Join(outPath, p)
+		if _, err := os.Stat(src); os.IsNotExist(err) {
+			continue
+		}
+		to := filepath.Join(dest, rel)
+		if err := os.MkdirAll(filepath.Dir(to), 0755); err != nil {
+			return fmt.Errorf("archive %s: %w", p, err)
+		}
+		if err := os.Rename(src, to); err != nil {
+			return fmt.Errorf("archive %s: %w", p, err)
+		}
+		removeEmptyParents(filepath.Dir(src), outPath)
+	}
+	entries, err := os.ReadDir(outPath)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if e.Name() == MetaDir {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(outPath, e.Name())); err != nil {
+			return fmt.Errorf("clean up %s: %w", e.Name(), err)
+		}
+	}
+	return nil
+}
+
+// removeEmptyParents deletes dir and its ancestors up to (not including)
+// root while they are empty.
+func removeEmptyParents(dir, root string) {
+	for dir != root && strings.HasPrefix(dir, root) {
+		if os.Remove(dir) != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+const manifestFooter = "\n---\n*Generated by task-agent YOLO mode*\n"
+
+func buildManifest(result *ai.TaskResult, task *asana.Task, m *Manifest) string {
 	var b strings.Builder
 	now := time.Now().Format("2006-01-02 15:04:05")
 
@@ -70,18 +282,50 @@ func buildManifest(result *ai.TaskResult, task *asana.Task) string {
 	fmt.Fprintf(&b, "## Summary\n\n%s\n\n", result.Summary)
 
 	fmt.Fprintf(&b, "## Files Generated\n\n")
-	for _, f := range result.Files {
-		fmt.Fprintf(&b, "- **`%s`** — %s\n", f.Path, f.Description)
+	for _, f := range m.Files {
+		var tags []string
+		if f.Encoding == ai.EncodingBase64 {
+			tags = append(tags, "binary, "+FormatSize(f.Size))
+		}
+		if f.Mode != "" {
+			tags = append(tags, "mode "+f.Mode)
+		}
+		extra := ""
+		if len(tags) > 0 {
+			extra = " _(" + strings.Join(tags, ", ") + ")_"
+		}
+		fmt.Fprintf(&b, "- **`%s`** — %s%s\n", f.Path, f.Description, extra)
+	}
+
+	if len(m.Rejected) > 0 {
+		fmt.Fprintf(&b, "\n## Rejected Files\n\n")
+		for _, r := range m.Rejected {
+			fmt.Fprintf(&b, "- `%s` — %s\n", r.Path, r.Reason)
+		}
 	}
 
 	if result.Notes != "" {
 		fmt.Fprintf(&b, "\n## Agent Notes\n\n%s\n", result.Notes)
 	}
 
-	fmt.Fprintf(&b, "\n---\n*Generated by task-agent YOLO mode*\n")
+	b.WriteString(manifestFooter)
 	return b.String()
 }
 
+// Size returns the total byte size of the files in result, decoding
+// base64 content.
+func Size(result *ai.TaskResult) int {
+	n := 0
+	for _, f := range result.Files {
+		if f.Binary() {
+			n += base64.StdEncoding.DecodedLen(len(f.Content))
+			continue
+		}
+		n += len(f.Content)
+	}
+	return n
+}
+
 // Preview returns a short preview string for display in the TUI.
 func Preview(result *ai.TaskResult) string {
 	var b strings.Builder
@@ -0,0 +1,260 @@
+package output
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/thecoolrobot/task-agent/internal/ai"
+	"github.com/thecoolrobot/task-agent/internal/asana"
+)
+
+var demoTask = &asana.Task{GID: "7", Name: "Demo task"}
+
+func demoExec(files ...ai.OutputFile) *ai.Execution {
+	return &ai.Execution{ProviderID: "anthropic", Model: "claude-sonnet-4-6", Result: &ai.TaskResult{
+		OutputType: "code_folder",
+		Summary:    "Built the thing",
+		Files:      files,
+	}}
+}
+
+func TestWriteToRejectsUnsafePaths(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "run")
+	exec := demoExec(
+		ai.OutputFile{Path: "main.go", Content: "package main\n"},
+		ai.OutputFile{Path: "docs/../README.md", Content: "# Demo\n"},
+		ai.OutputFile{Path: "../escape.txt", Content: "out"},
+		ai.OutputFile{Path: "docs/../../escape.txt", Content: "out"},
+		ai.OutputFile{Path: "/tmp/abs.txt", Content: "out"},
+		ai.OutputFile{Path: "", Content: "out"},
+		ai.OutputFile{Path: ".agent/conversation.json", Content: "{}"},
+		ai.OutputFile{Path: ManifestFile, Content: "{}"},
+		ai.OutputFile{Path: "AGENT_MANIFEST.md", Content: "# no"},
+		ai.OutputFile{Path: "run.sh", Content: "#!/bin/sh\n", Mode: ai.ModeExecutable},
+		ai.OutputFile{Path: "logo.png", Content: "iVBORw0K\nGgo=", Encoding: ai.EncodingBase64},
+		ai.OutputFile{Path: "bad.bin", Content: "!!!", Encoding: ai.EncodingBase64},
+		ai.OutputFile{Path: "odd.txt", Content: "x", Encoding: "rot13"},
+		ai.OutputFile{Path: "locked.txt", Content: "x", Mode: "0444"},
+	)
+	if err := WriteTo(exec, demoTask, dir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(dir), "escape.txt")); err == nil {
+		t.Error("a ../ path was written outside the folder")
+	}
+
+	m, err := LoadManifest(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var written []string
+	for _, f := range m.Files {
+		written = append(written, f.Path)
+	}
+	wantWritten := []string{"main.go", "README.md", "run.sh", "logo.png"}
+	if len(written) != len(wantWritten) {
+		t.Fatalf("written = %v, want %v", written, wantWritten)
+	}
+	for i, p := range wantWritten {
+		if written[i] != p {
+			t.Errorf("written[%d] = %s, want %s", i, written[i], p)
+		}
+	}
+	reasons := map[string]string{}
+	for _, r := range m.Rejected {
+		reasons[r.Path] = r.Reason
+	}
+	for p, want := range map[string]string{
+		"../escape.txt":            "escapes the output folder",
+		"docs/../../escape.txt":    "escapes the output folder",
+		"/tmp/abs.txt":             "absolute path",
+		"":                         "empty path",
+		".agent/conversation.json": "reserved for run metadata",
+		ManifestFile:               "reserved for the manifest",
+		"AGENT_MANIFEST.md":        "reserved for the manifest",
+		"bad.bin":                  "invalid base64",
+		"odd.txt":                  `unknown encoding "rot13"`,
+		"locked.txt":               `invalid mode "0444"`,
+	} {
+		if got := reasons[p]; got != want {
+			t.Errorf("rejected %q: reason %q, want %q", p, got, want)
+		}
+	}
+
+	for _, f := range m.Files {
+		switch f.Path {
+		case "logo.png":
+			if f.Encoding != ai.EncodingBase64 || f.Size != 8 {
+				t.Errorf("logo.png entry = %+v", f)
+			}
+		case "run.sh":
+			if f.Mode != "0755" {
+				t.Errorf("run.sh mode = %q", f.Mode)
+			}
+		}
+	}
+	if runtime.GOOS != "windows" {
+		if info, err := os.Stat(filepath.Join(dir, "run.sh")); err != nil || info.Mode().Perm() != 0755 {
+			t.Errorf("run.sh: %v %v", info, err)
+		}
+	}
+	if problems := m.Validate(dir); len(problems) != 0 {
+		t.Errorf("fresh folder does not validate: %v", problems)
+	}
+}
+
+func TestArchive(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "run")
+	exec := demoExec(
+		ai.OutputFile{Path: "main.go", Content: "package main\n"},
+		ai.OutputFile{Path: "pkg/util/util.go", Content: "package util\n"},
+	)
+	if err := WriteTo(exec, demoTask, dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteMeta(dir, "verify.log", []byte("ok")); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteMeta(dir, "conversation.json", []byte("[]")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "demo.bin"), []byte("build artifact"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	dest := filepath.Join(dir, MetaDir, "iterations", "1")
+	if err := Archive(dir, dest, "verify.log"); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Files(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"AGENT_MANIFEST.md", ManifestFile, "main.go", "pkg/util/util.go", "verify.log"}
+	if len(got) != len(want) {
+		t.Fatalf("archived %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("archived[%d] = %s, want %s", i, got[i], want[i])
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != MetaDir {
+		t.Errorf("left behind: %v", entries)
+	}
+	if _, err := os.Stat(filepath.Join(dir, MetaDir, "conversation.json")); err != nil {
+		t.Errorf("unarchived metadata removed: %v", err)
+	}
+}
+
+func TestWriteToSizeLimits(t *testing.T) {
+	if MaxFileBytes != 10<<20 {
+		t.Fatalf("MaxFileBytes = %d; the system prompt promises 10 MB", MaxFileBytes)
+	}
+	limit := strings.Repeat("x", MaxFileBytes)
+	dir := t.TempDir()
+	exec := demoExec(
+		ai.OutputFile{Path: "limit.txt", Content: limit},
+		ai.OutputFile{Path: "over.txt", Content: limit + "x"},
+		ai.OutputFile{Path: "over.bin", Content: base64.StdEncoding.EncodeToString([]byte(limit + "x")), Encoding: ai.EncodingBase64},
+	)
+	if err := WriteTo(exec, demoTask, dir); err != nil {
+		t.Fatal(err)
+	}
+	m, err := LoadManifest(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Files) != 1 || m.Files[0].Path != "limit.txt" || m.Files[0].Size != int64(MaxFileBytes) {
+		t.Errorf("written = %+v", m.Files)
+	}
+	if len(m.Rejected) != 2 {
+		t.Fatalf("rejected = %+v", m.Rejected)
+	}
+	for _, r := range m.Rejected {
+		if r.Reason != "10.0 MB exceeds the 10.0 MB file limit" {
+			t.Errorf("%s: reason %q", r.Path, r.Reason)
+		}
+		if _, err := os.Stat(filepath.Join(dir, r.Path)); err == nil {
+			t.Errorf("%s written", r.Path)
+		}
+	}
+
+	defer func(n int) { MaxTotalBytes = n }(MaxTotalBytes)
+	MaxTotalBytes = 10
+	dir = t.TempDir()
+	exec = demoExec(
+		ai.OutputFile{Path: "a.txt", Content: "123456"},
+		ai.OutputFile{Path: "b.txt", Content: "123456"},
+		ai.OutputFile{Path: "c.txt", Content: "1234"},
+	)
+	if err := WriteTo(exec, demoTask, dir); err != nil {
+		t.Fatal(err)
+	}
+	if m, err = LoadManifest(dir); err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Files) != 2 || m.Files[1].Path != "c.txt" || len(m.Rejected) != 1 || m.Rejected[0].Reason != "output exceeds the 10 B total limit" {
+		t.Errorf("files = %+v, rejected = %+v", m.Files, m.Rejected)
+	}
+}
+
+func TestArchiveStaysInFolder(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "run")
+	if err := WriteTo(demoExec(ai.OutputFile{Path: "main.go", Content: "package main\n"}), demoTask, dir); err != nil {
+		t.Fatal(err)
+	}
+	victim := filepath.Join(root, "victim.txt")
+	if err := os.WriteFile(victim, []byte("keep me"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteMeta(dir, "conversation.json", []byte("[]")); err != nil {
+		t.Fatal(err)
+	}
+	// An edited manifest lists files outside the folder and in MetaDir.
+	if err := UpdateManifest(dir, func(m *Manifest) {
+		m.Files = append(m.Files, ManifestEntry{Path: "../victim.txt"}, ManifestEntry{Path: ".agent/conversation.json"})
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	dest := filepath.Join(dir, MetaDir, "iterations", "1")
+	if err := Archive(dir, dest); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(victim); err != nil || string(data) != "keep me" {
+		t.Errorf("file outside the folder moved: %q, %v", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, MetaDir, "conversation.json")); err != nil {
+		t.Errorf("metadata moved: %v", err)
+	}
+	got, err := Files(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "AGENT_MANIFEST.md agent-manifest.json main.go"; strings.Join(got, " ") != want {
+		t.Errorf("archived %v, want %s", got, want)
+	}
+
+	// Without a manifest everything outside MetaDir is archived.
+	if err := os.WriteFile(filepath.Join(dir, "notes.md"), []byte("# Notes\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	dest = filepath.Join(dir, MetaDir, "iterations", "2")
+	if err := Archive(dir, dest); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := Files(dest); err != nil || strings.Join(got, " ") != "notes.md" {
+		t.Errorf("archived %v, %v", got, err)
+	}
+}
@@ -0,0 +1,129 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/thecoolrobot/task-agent/internal/ai"
+	"github.com/thecoolrobot/task-agent/internal/asana"
+	"github.com/thecoolrobot/task-agent/internal/history"
+	"github.com/thecoolrobot/task-agent/internal/output"
+	"github.com/thecoolrobot/task-agent/internal/verify"
+)
+
+// ChatOptions controls a follow-up turn on a past run.
+type ChatOptions struct {
+	APIKey   string
+	Verify   verify.Config
+	Progress func(string)
+}
+
+// chatInstruction is appended to follow-up messages so every reply is still
+// a complete deliverable that can replace the folder's files.
+const chatInstruction = "\n\nReply with the complete revised deliverable as the same JSON object, including unchanged files. If no changes are needed, return the current files and answer in \"notes\"."
+
+// Chat sends a follow-up message in the conversation recorded in dir, using
+// the run's original model and system prompt. The reply's files become a
+// new revision of the folder; the previous one is archived under
+// .agent/revisions/<n>/.
+func Chat(ctx context.Context, dir, message string, opts ChatOptions) (*Outcome, error) {
+	conv, err := history.Load(dir)
+	if err != nil {
+		return nil, err
+	}
+	client := ai.NewClient(conv.Provider, conv.Model, opts.APIKey)
+	client.System = conv.System
+
+	turns := append(append([]ai.Message(nil), conv.Messages...), ai.Message{Role: "user", Content: message + chatInstruction})
+	exec, err := client.Converse(ctx, turns, opts.Progress)
+	if err != nil {
+		return nil, err
+	}
+	conv.Messages = exec.Messages
+	if exec.ParseErr != nil {
+		if err := conv.Save(dir); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("reply was not a valid result (%v) — files unchanged, reply kept in the conversation", exec.ParseErr)
+	}
+
+	// The conversation only records the task's name; keep the rest of its
+	// metadata from the previous manifest.
+	prevManifest, _ := output.LoadManifest(dir)
+	archive := filepath.Join(dir, output.MetaDir, history.RevisionsDir, strconv.Itoa(conv.Revision))
+	if err := output.Archive(dir, archive, "verify.log", "attempts.json"); err != nil {
+		return nil, err
+	}
+	task := &asana.Task{GID: conv.TaskGID, Name: conv.TaskName}
+	if err := output.WriteTo(exec, task, dir); err != nil {
+		return nil, fmt.Errorf("saving revision: %w", err)
+	}
+	if prevManifest != nil {
+		if err := output.UpdateManifest(dir, func(m *output.Manifest) { m.Task = prevManifest.Task }); err != nil {
+			return nil, err
+		}
+	}
+
+	out := &Outcome{Execution: exec, Result: exec.Result, OutPath: dir, Revision: conv.Revision + 1}
+	var verifyErr error
+	if opts.Verify.Enabled {
+		out.Verification, verifyErr = Verify(ctx, dir, exec.Result, opts.Verify, opts.Progress)
+	}
+
+	// The new files are in place whatever verification made of them, so the
+	// conversation must record the revision either way.
+	conv.Result = exec.Result
+	conv.Revision = out.Revision
+	conv.Revisions = append(conv.Revisions, history.Revision{
+		N:       out.Revision,
+		Created: time.Now(),
+		Prompt:  message,
+		Usage:   exec.Usage,
+		Status:  out.Status(),
+	})
+	if err := conv.Save(dir); err != nil {
+		return out, err
+	}
+	return out, verifyErr
+}
+
+// Transcript is conv's conversation as a chat pane shows it: the opening
+// task by name, follow-ups without the reply-format reminder, fix requests
+// by their heading, and replies as their summary and files instead of raw
+// JSON.
+func Transcript(conv *history.Conversation) []ai.Message {
+	out := make([]ai.Message, 0, len(conv.Messages))
+	for i, m := range conv.Messages {
+		text := m.Content
+		switch {
+		case m.Role == "user" && i == 0:
+			text = "Task: " + conv.TaskName
+		case m.Role == "user" && strings.HasPrefix(text, "## Verification failed"):
+			text = "Verification failed — asked for a fix"
+		case m.Role == "user":
+			text = strings.TrimSuffix(text, chatInstruction)
+		default:
+			if r, err := ai.ParseResult(text); err == nil {
+				text = describeReply(r)
+			}
+		}
+		out = append(out, ai.Message{Role: m.Role, Content: text})
+	}
+	return out
+}
+
+func describeReply(r *ai.TaskResult) string {
+	var b strings.Builder
+	b.WriteString(r.Summary)
+	for _, f := range r.Files {
+		b.WriteString("\n  📄 " + f.Path)
+	}
+	if r.Notes != "" {
+		b.WriteString("\n" + r.Notes)
+	}
+	return b.String()
+}
@@ -0,0 +1,68 @@
+package runner
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/thecoolrobot/task-agent/internal/ai"
+	"github.com/thecoolrobot/task-agent/internal/asana"
+	"github.com/thecoolrobot/task-agent/internal/history"
+	"github.com/thecoolrobot/task-agent/internal/output"
+	"github.com/thecoolrobot/task-agent/internal/testharness"
+)
+
+func TestChatArchiveStaysInFolder(t *testing.T) {
+	llm := testharness.NewLLMServer(t)
+	root := t.TempDir()
+	dir := filepath.Join(root, "run")
+	exec := &ai.Execution{ProviderID: "anthropic", Model: "claude-sonnet-4-6",
+		Messages: []ai.Message{{Role: "user", Content: "Do it"}, {Role: "assistant", Content: "{}"}},
+		Result:   &ai.TaskResult{OutputType: "code_folder", Summary: "v1", Files: []ai.OutputFile{{Path: "main.go", Content: "package main\n"}}},
+	}
+	if err := output.WriteTo(exec, &asana.Task{GID: "7", Name: "Demo"}, dir); err != nil {
+		t.Fatal(err)
+	}
+	victim := filepath.Join(root, "victim.txt")
+	if err := os.WriteFile(victim, []byte("keep me"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// conversation.json is a plain file in the folder: an edited one may
+	// name files anywhere.
+	conv := history.New("7", "Demo", exec)
+	conv.Result = &ai.TaskResult{Files: []ai.OutputFile{
+		{Path: "main.go"}, {Path: "../victim.txt"}, {Path: filepath.Join(root, "victim.txt")}, {Path: ".agent/" + history.File},
+	}}
+	if err := conv.Save(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	llm.ReplyResult(ai.TaskResult{OutputType: "code_folder", Summary: "v2", Files: []ai.OutputFile{{Path: "main.go", Content: "package main\n\nfunc main() {}\n"}}})
+	out, err := Chat(context.Background(), dir, "Add a main func", ChatOptions{APIKey: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Revision != 2 {
+		t.Errorf("revision = %d", out.Revision)
+	}
+	if data, err := os.ReadFile(victim); err != nil || string(data) != "keep me" {
+		t.Errorf("file outside the folder moved: %q, %v", data, err)
+	}
+	archived, err := output.Files(filepath.Join(dir, output.MetaDir, history.RevisionsDir, "1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"AGENT_MANIFEST.md", output.ManifestFile, "main.go"}
+	if len(archived) != len(want) {
+		t.Fatalf("archived %v, want %v", archived, want)
+	}
+	for i := range want {
+		if archived[i] != want[i] {
+			t.Errorf("archived[%d] = %s, want %s", i, archived[i], want[i])
+		}
+	}
+	if conv, err := history.Load(dir); err != nil || conv.Revision != 2 {
+		t.Errorf("conversation after chat: %+v, %v", conv, err)
+	}
+}
@@ -0,0 +1,138 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/thecoolrobot/task-agent/internal/ai"
+	"github.com/thecoolrobot/task-agent/internal/asana"
+	"github.com/thecoolrobot/task-agent/internal/output"
+	"github.com/thecoolrobot/task-agent/internal/verify"
+)
+
+// IterationsDir holds earlier versions of a fixed output, one numbered
+// folder per attempt, inside output.MetaDir.
+const IterationsDir = "iterations"
+
+// Attempt records one pass through verification.
+type Attempt struct {
+	N      int      `json:"n"` // 1 is the original generation
+	Status string   `json:"status"`
+	Usage  ai.Usage `json:"usage"`
+	Dir    string   `json:"dir,omitempty"` // archived copy, empty for the final attempt
+}
+
+// fileBudget caps how much file content a fix request carries.
+const fileBudget = 60000
+
+// FixPrompt builds the follow-up turn asking the model to repair a failing
+// output: the failing command output plus the current files.
+func FixPrompt(rep *verify.Report, result *ai.TaskResult) string {
+	var b strings.Builder
+	b.WriteString("## Verification failed\n\nYour output was written to disk and checked. These commands failed:\n\n")
+	for _, f := range rep.Failures() {
+		status := fmt.Sprintf("exit %d", f.ExitCode)
+		if f.Status == verify.TimedOut {
+			status = "timed out"
+		}
+		fmt.Fprintf(&b, "### `%s` (%s)\n\n```\n%s\n```\n\n", f.Command, status, verify.Tail(f.Stdout+f.Stderr, 80))
+	}
+	b.WriteString("## Current files\n\n")
+	budget := fileBudget
+	for _, f := range result.Files {
+		if f.Binary() {
+			// Return binary files unchanged; their bytes would only use up
+			// the budget.
+			fmt.Fprintf(&b, "### %s\n(binary, base64-encoded — omitted; return it unchanged unless it must be fixed)\n\n", f.Path)
+			continue
+		}
+		if budget-len(f.Content) < 0 {
+			fmt.Fprintf(&b, "### %s\n(omitted — size budget exhausted)\n\n", f.Path)
+			continue
+		}
+		budget -= len(f.Content)
+		fmt.Fprintf(&b, "### %s\n```\n%s\n```\n\n", f.Path, f.Content)
+	}
+	b.WriteString("Fix the problems so every command passes. Return the complete revised deliverable as the same JSON object, including unchanged files.")
+	return b.String()
+}
+
+// fix runs the repair loop until verification passes or the configured
+// attempts are used up, archiving each failed version under
+// .agent/iterations/<n>/.
+func fix(ctx context.Context, task *asana.Task, out *Outcome, opts Options) error {
+	emit := func(s string) {
+		if opts.Progress != nil {
+			opts.Progress(s)
+		}
+	}
+	client := ai.NewClient(opts.ProviderID, opts.Model, opts.APIKey)
+	out.Attempts = []Attempt{{N: 1, Status: out.Status(), Usage: out.Execution.Usage}}
+
+	for n := 1; out.Status() == verify.StatusFailed && n <= opts.Verify.FixAttempts; n++ {
+		emit(fmt.Sprintf("🔧 Fix attempt %d/%d — sending %d failure(s) back to the model", n, opts.Verify.FixAttempts, len(out.Verification.Failures())))
+		// Keep the request bounded: the task, the latest reply and the fix
+		// request rather than every earlier attempt. The saved conversation
+		// still gets every turn.
+		msgs := out.Execution.Messages
+		ask := ai.Message{Role: "user", Content: FixPrompt(out.Verification, out.Result)}
+		exec, err := client.Converse(ctx, []ai.Message{msgs[0], msgs[len(msgs)-1], ask}, opts.Progress)
+		if err != nil {
+			return fmt.Errorf("fix attempt %d: %w", n, err)
+		}
+		exec.Messages = append(append(append([]ai.Message(nil), msgs...), ask), exec.Messages[len(exec.Messages)-1])
+
+		dir := filepath.Join(out.OutPath, output.MetaDir, IterationsDir, strconv.Itoa(n))
+		if err := output.Archive(out.OutPath, dir, "verify.log"); err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(dir, "response.txt"), []byte(out.Execution.Raw), 0644); err != nil {
+			return fmt.Errorf("archive response: %w", err)
+		}
+		out.Attempts[len(out.Attempts)-1].Dir = dir
+
+		if err := output.WriteTo(exec, task, out.OutPath); err != nil {
+			return fmt.Errorf("saving fix attempt %d: %w", n, err)
+		}
+		out.Execution, out.Result = exec, exec.Result
+		rep, err := Verify(ctx, out.OutPath, exec.Result, opts.Verify, opts.Progress)
+		out.Verification = rep
+		if err != nil {
+			return err
+		}
+		out.Attempts = append(out.Attempts, Attempt{N: n + 1, Status: rep.Status(), Usage: exec.Usage})
+	}
+	if len(out.Attempts) == 1 {
+		out.Attempts = nil
+		return nil
+	}
+	return recordAttempts(out)
+}
+
+// recordAttempts writes the attempt history to .agent/attempts.json and the
+// manifest.
+func recordAttempts(out *Outcome) error {
+	data, err := json.MarshalIndent(out.Attempts, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := output.WriteMeta(out.OutPath, "attempts.json", data); err != nil {
+		return err
+	}
+	var b strings.Builder
+	b.WriteString("## Fix Loop\n\n| Attempt | Verification | Tokens in/out | Archived |\n|--------:|--------------|--------------:|----------|\n")
+	for _, a := range out.Attempts {
+		dir := "(current)"
+		if a.Dir != "" {
+			rel, _ := filepath.Rel(out.OutPath, a.Dir)
+			dir = "`" + filepath.ToSlash(rel) + "/`"
+		}
+		fmt.Fprintf(&b, "| %d | %s | %d/%d | %s |\n", a.N, a.Status, a.Usage.InputTokens, a.Usage.OutputTokens, dir)
+	}
+	return output.AppendManifest(out.OutPath, b.String())
+}
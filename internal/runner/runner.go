@@ -0,0 +1,160 @@
+// Package runner drives a complete task run — execute, write, verify — so
+// the CLI and the TUI share one pipeline.
+package runner
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/thecoolrobot/task-agent/internal/ai"
+	"github.com/thecoolrobot/task-agent/internal/asana"
+	"github.com/thecoolrobot/task-agent/internal/history"
+	"github.com/thecoolrobot/task-agent/internal/output"
+	"github.com/thecoolrobot/task-agent/internal/verify"
+)
+
+// Options controls a run.
+type Options struct {
+	ProviderID string
+	Model      string
+	APIKey     string
+	OutputDir  string
+	// Layout is output.LayoutTimestamp (the default) or output.LayoutTask.
+	Layout string
+	// Prompt replaces the rendered task message, e.g. after editing it in
+	// $EDITOR.
+	Prompt string
+	Verify verify.Config
+	// Retention prunes old output folders after the run when Auto is set.
+	Retention output.Policy
+	Progress  func(string)
+}
+
+// Outcome is the result of a run.
+type Outcome struct {
+	Execution *ai.Execution
+	Result    *ai.TaskResult
+	OutPath   string
+	// Verification is nil when verification is disabled. After a fix loop it
+	// describes the final attempt.
+	Verification *verify.Report
+	// Attempts lists every verification pass when the fix loop ran.
+	Attempts []Attempt
+	// Revision is the revision number of the files now in OutPath.
+	Revision int
+}
+
+// Status is the verification status of the run: "verified", "failed",
+// "unverified" or "" when verification is disabled.
+func (o *Outcome) Status() string {
+	if o.Verification == nil {
+		return ""
+	}
+	return o.Verification.Status()
+}
+
+// PromptFile is the final user prompt saved in an output folder's MetaDir.
+const PromptFile = "prompt.md"
+
+// Prompt renders the user message sent for task.
+func Prompt(task *asana.Task) string {
+	return ai.TaskMessage(asana.FormatTaskMarkdown(task)).Content
+}
+
+// Preview returns the exact request Run would send for task, without
+// calling the provider.
+func Preview(task *asana.Task, opts Options) (*ai.DryRun, error) {
+	prompt := Prompt(task)
+	if opts.Prompt != "" {
+		prompt = opts.Prompt
+	}
+	return ai.NewClient(opts.ProviderID, opts.Model, opts.APIKey).DryRun([]ai.Message{{Role: "user", Content: prompt}})
+}
+
+// Run executes task, writes the result under opts.OutputDir and, when
+// enabled, verifies it — sending failures back to the model for up to
+// Verify.FixAttempts repairs. A failed verification is reported in the
+// Outcome, not as an error.
+func Run(ctx context.Context, task *asana.Task, opts Options) (*Outcome, error) {
+	client := ai.NewClient(opts.ProviderID, opts.Model, opts.APIKey)
+	prompt := Prompt(task)
+	if opts.Prompt != "" {
+		prompt = opts.Prompt
+	}
+	exec, err := client.Converse(ctx, []ai.Message{{Role: "user", Content: prompt}}, opts.Progress)
+	if err != nil {
+		return nil, err
+	}
+	write := output.Write
+	if opts.Layout == output.LayoutTask {
+		write = output.WriteRevision
+	}
+	outPath, err := write(exec, task, opts.OutputDir)
+	if err != nil {
+		return nil, fmt.Errorf("saving output: %w", err)
+	}
+	// Keep the exact prompt so the run can be reproduced.
+	if err := output.WriteMeta(outPath, PromptFile, []byte(prompt)); err != nil {
+		return nil, err
+	}
+	out := &Outcome{Execution: exec, Result: exec.Result, OutPath: outPath, Revision: 1}
+	if opts.Verify.Enabled {
+		rep, err := Verify(ctx, outPath, exec.Result, opts.Verify, opts.Progress)
+		out.Verification = rep
+		if err != nil {
+			return out, err
+		}
+		if rep.Status() == verify.StatusFailed && opts.Verify.FixAttempts > 0 {
+			if err := fix(ctx, task, out, opts); err != nil {
+				return out, err
+			}
+		}
+	}
+	conv := history.New(task.GetID(), task.Name, out.Execution)
+	conv.Current().Status = out.Status()
+	if err := conv.Save(outPath); err != nil {
+		return out, err
+	}
+	if err := output.SetRevisionStatus(outPath, out.Status()); err != nil {
+		return out, err
+	}
+	if opts.Retention.Auto {
+		autoPrune(opts.OutputDir, opts.Retention, outPath, opts.Progress)
+	}
+	return out, nil
+}
+
+// autoPrune applies the retention policy after a run, sparing the folder
+// just written. Failures are reported, not returned: the run itself worked.
+func autoPrune(outputDir string, p output.Policy, keep string, progress func(string)) {
+	pruned, err := output.Prune(outputDir, p, false, keep)
+	if progress == nil {
+		return
+	}
+	if err != nil {
+		progress("⚠️  Auto-prune: " + err.Error())
+	}
+	if len(pruned) > 0 {
+		var freed int64
+		for _, f := range pruned {
+			freed += f.Size
+		}
+		progress(fmt.Sprintf("🧹 Pruned %d old output folder(s), freed %s", len(pruned), output.FormatSize(freed)))
+	}
+}
+
+// Verify runs the verification hooks in outPath and records the captured
+// output in the folder's verify.log and both manifests.
+func Verify(ctx context.Context, outPath string, result *ai.TaskResult, cfg verify.Config, progress func(string)) (*verify.Report, error) {
+	rep := verify.Run(ctx, outPath, result, cfg, progress)
+	if err := output.WriteMeta(outPath, "verify.log", []byte(rep.Log())); err != nil {
+		return rep, err
+	}
+	if err := output.AppendManifest(outPath, rep.Markdown()); err != nil {
+		return rep, err
+	}
+	if err := output.UpdateManifest(outPath, func(m *output.Manifest) { m.Verification = output.Verification(rep) }); err != nil {
+		return rep, err
+	}
+	return rep, nil
+}
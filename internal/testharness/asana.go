@@ -0,0 +1,130 @@
+// Package testharness provides fakes for end-to-end tests: a scripted
+// asana-cli binary and an httptest server that speaks the Anthropic and
+// OpenAI APIs.
+package testharness
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/thecoolrobot/task-agent/internal/asana"
+)
+
+// Reply is the fake asana-cli's answer to one invocation. By default it is
+// printed as the usual {"success", "data", "error"} envelope; Raw replaces
+// the envelope verbatim and a non-zero Exit makes the command fail.
+type Reply struct {
+	Success bool            `json:"success"`
+	Data    json.RawMessage `json:"data,omitempty"`
+	Error   string          `json:"error,omitempty"`
+	Raw     string          `json:"raw,omitempty"`
+	Stderr  string          `json:"stderr,omitempty"`
+	Exit    int             `json:"exit,omitempty"`
+}
+
+// Fixtures maps an asana-cli invocation, minus the --json flag and joined by
+// spaces (e.g. "view 42", "list proj-1"), to its reply.
+type Fixtures map[string]Reply
+
+// OK returns a successful reply wrapping data.
+func OK(data any) Reply {
+	b, err := json.Marshal(data)
+	if err != nil {
+		panic(err)
+	}
+	return Reply{Success: true, Data: b}
+}
+
+// Fail returns a success:false reply with the given error message.
+func Fail(msg string) Reply {
+	return Reply{Success: false, Error: msg}
+}
+
+// Tasks builds a list of tasks for use in fixtures.
+func Tasks(names ...string) []asana.Task {
+	tasks := make([]asana.Task, len(names))
+	for i, n := range names {
+		tasks[i] = asana.Task{GID: strconv.Itoa(i + 1), Name: n}
+	}
+	return tasks
+}
+
+var (
+	buildOnce sync.Once
+	buildPath string
+	buildErr  error
+)
+
+// FakeAsana is a built fake asana-cli wired to a fixture file.
+type FakeAsana struct {
+	Path    string // binary to use as asana-cli
+	logPath string
+}
+
+// NewFakeAsana builds the fake asana-cli (once per test binary), writes
+// fixtures to a temp file and points the fake at it via the environment.
+func NewFakeAsana(t testing.TB, fixtures Fixtures) *FakeAsana {
+	t.Helper()
+	buildOnce.Do(func() {
+		dir, err := os.MkdirTemp("", "fakeasana")
+		if err != nil {
+			buildErr = err
+			return
+		}
+		bin := filepath.Join(dir, "asana-cli")
+		if runtime.GOOS == "windows" {
+			bin += ".exe"
+		}
+		cmd := exec.Command("go", "build", "-o", bin, "github.com/thecoolrobot/task-agent/internal/testharness/fakeasana")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			buildErr = &buildError{err: err, out: string(out)}
+			return
+		}
+		buildPath = bin
+	})
+	if buildErr != nil {
+		t.Fatalf("build fake asana-cli: %v", buildErr)
+	}
+
+	dir := t.TempDir()
+	data, err := json.Marshal(fixtures)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fixturePath := filepath.Join(dir, "fixtures.json")
+	if err := os.WriteFile(fixturePath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	f := &FakeAsana{Path: buildPath, logPath: filepath.Join(dir, "calls.log")}
+	t.Setenv("FAKE_ASANA_FIXTURES", fixturePath)
+	t.Setenv("FAKE_ASANA_LOG", f.logPath)
+	return f
+}
+
+// Client returns an asana.Client that execs the fake.
+func (f *FakeAsana) Client() *asana.Client {
+	return &asana.Client{CLIPath: f.Path}
+}
+
+// Calls returns the invocations seen so far, in order.
+func (f *FakeAsana) Calls() []string {
+	data, err := os.ReadFile(f.logPath)
+	if err != nil {
+		return nil
+	}
+	return strings.Split(strings.TrimSpace(string(data)), "\n")
+}
+
+type buildError struct {
+	err error
+	out string
+}
+
+func (e *buildError) Error() string { return e.err.Error() + "\n" + e.out }
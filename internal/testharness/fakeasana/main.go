@@ -0,0 +1,66 @@
+// Command fakeasana is a scripted stand-in for asana-cli used by tests. It
+// answers each invocation from the fixture file named by FAKE_ASANA_FIXTURES
+// and appends the invocation to FAKE_ASANA_LOG when set.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// reply mirrors testharness.Reply; duplicated to keep this binary free of
+// test-only imports.
+type reply struct {
+	Success bool            `json:"success"`
+	Data    json.RawMessage `json:"data,omitempty"`
+	Error   string          `json:"error,omitempty"`
+	Raw     string          `json:"raw,omitempty"`
+	Stderr  string          `json:"stderr,omitempty"`
+	Exit    int             `json:"exit,omitempty"`
+}
+
+func main() {
+	var args []string
+	for _, a := range os.Args[1:] {
+		if a != "--json" {
+			args = append(args, a)
+		}
+	}
+	key := strings.Join(args, " ")
+
+	if logPath := os.Getenv("FAKE_ASANA_LOG"); logPath != "" {
+		if f, err := os.OpenFile(logPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644); err == nil {
+			fmt.Fprintln(f, key)
+			f.Close()
+		}
+	}
+
+	data, err := os.ReadFile(os.Getenv("FAKE_ASANA_FIXTURES"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "fakeasana: read fixtures: %v\n", err)
+		os.Exit(2)
+	}
+	var fixtures map[string]reply
+	if err := json.Unmarshal(data, &fixtures); err != nil {
+		fmt.Fprintf(os.Stderr, "fakeasana: parse fixtures: %v\n", err)
+		os.Exit(2)
+	}
+	r, ok := fixtures[key]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "fakeasana: no fixture for %q\n", key)
+		os.Exit(1)
+	}
+
+	if r.Stderr != "" {
+		fmt.Fprint(os.Stderr, r.Stderr)
+	}
+	if r.Raw != "" {
+		fmt.Print(r.Raw)
+	} else if r.Exit == 0 {
+		out, _ := json.Marshal(r)
+		fmt.Println(string(out))
+	}
+	os.Exit(r.Exit)
+}
@@ -0,0 +1,17 @@
+package testharness
+
+import "testing"
+
+// Home points $HOME at a fresh temp directory and clears the variables
+// that would move task-agent's config elsewhere, so a test never touches
+// the real one. It returns the new home.
+func Home(t testing.TB) string {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home) // os.UserHomeDir on Windows
+	for _, v := range []string{"TASK_AGENT_HOME", "XDG_CONFIG_HOME"} {
+		t.Setenv(v, "")
+	}
+	return home
+}
@@ -0,0 +1,182 @@
+package testharness
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/thecoolrobot/task-agent/internal/ai"
+)
+
+// LLMReply scripts one response from the fake LLM server.
+type LLMReply struct {
+	// Text is the assistant message content.
+	Text string
+	// Status, when non-zero and >= 400, makes the server return an API
+	// error with Text as the message.
+	Status       int
+	InputTokens  int
+	OutputTokens int
+}
+
+// LLMRequest is a request received by the fake LLM server.
+type LLMRequest struct {
+	Path    string
+	Headers http.Header
+	Body    map[string]any
+}
+
+// LLMServer emulates the Anthropic Messages and OpenAI chat completions
+// APIs, including SSE streaming, serving scripted replies in order. Once the
+// script is exhausted it answers with DefaultResult.
+type LLMServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	replies  []LLMReply
+	requests []LLMRequest
+}
+
+// DefaultResult is served when no reply is scripted.
+var DefaultResult = ai.TaskResult{
+	OutputType: "markdown",
+	Summary:    "Fake summary",
+	Files:      []ai.OutputFile{{Path: "README.md", Content: "# Done\n", Description: "Readme"}},
+	Notes:      "fake notes",
+}
+
+// ResultJSON marshals a TaskResult the way a well-behaved model would reply.
+func ResultJSON(r ai.TaskResult) string {
+	b, err := json.Marshal(r)
+	if err != nil {
+		panic(err)
+	}
+	return string(b)
+}
+
+// NewLLMServer starts a fake LLM server, points every registered provider at
+// it for the duration of the test, and shuts it down on cleanup.
+func NewLLMServer(t testing.TB) *LLMServer {
+	t.Helper()
+	s := &LLMServer{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/messages", s.handleAnthropic)
+	mux.HandleFunc("/v1/chat/completions", s.handleOpenAI)
+	mux.HandleFunc("/v1/models", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]any{"data": []map[string]string{{"id": "fake-model"}}})
+	})
+	s.Server = httptest.NewServer(mux)
+
+	for _, p := range ai.Providers() {
+		ai.SetBaseURL(p.ID, s.URL+"/v1")
+	}
+	t.Cleanup(func() {
+		for _, p := range ai.Providers() {
+			ai.SetBaseURL(p.ID, "")
+		}
+		s.Close()
+	})
+	return s
+}
+
+// Reply queues replies to be served in order.
+func (s *LLMServer) Reply(replies ...LLMReply) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.replies = append(s.replies, replies...)
+}
+
+// ReplyResult queues a reply whose text is r encoded as JSON.
+func (s *LLMServer) ReplyResult(r ai.TaskResult) {
+	s.Reply(LLMReply{Text: ResultJSON(r), InputTokens: 100, OutputTokens: 200})
+}
+
+// Requests returns the requests received so far.
+func (s *LLMServer) Requests() []LLMRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]LLMRequest, len(s.requests))
+	copy(out, s.requests)
+	return out
+}
+
+func (s *LLMServer) next(r *http.Request) (LLMReply, map[string]any) {
+	data, _ := io.ReadAll(r.Body)
+	var body map[string]any
+	_ = json.Unmarshal(data, &body)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, LLMRequest{Path: r.URL.Path, Headers: r.Header.Clone(), Body: body})
+	if len(s.replies) == 0 {
+		return LLMReply{Text: ResultJSON(DefaultResult), InputTokens: 100, OutputTokens: 200}, body
+	}
+	reply := s.replies[0]
+	s.replies = s.replies[1:]
+	return reply, body
+}
+
+func (s *LLMServer) handleAnthropic(w http.ResponseWriter, r *http.Request) {
+	reply, body := s.next(r)
+	if reply.Status >= 400 {
+		writeJSON(w, reply.Status, map[string]any{"type": "error", "error": map[string]string{"type": "api_error", "message": reply.Text}})
+		return
+	}
+	if stream, _ := body["stream"].(bool); stream {
+		sse(w, []any{
+			map[string]any{"type": "message_start", "message": map[string]any{"usage": map[string]int{"input_tokens": reply.InputTokens}}},
+			map[string]any{"type": "content_block_delta", "delta": map[string]string{"type": "text_delta", "text": reply.Text}},
+			map[string]any{"type": "message_delta", "usage": map[string]int{"output_tokens": reply.OutputTokens}},
+			map[string]any{"type": "message_stop"},
+		}, false)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"content": []map[string]string{{"type": "text", "text": reply.Text}},
+		"usage":   map[string]int{"input_tokens": reply.InputTokens, "output_tokens": reply.OutputTokens},
+	})
+}
+
+func (s *LLMServer) handleOpenAI(w http.ResponseWriter, r *http.Request) {
+	reply, body := s.next(r)
+	if reply.Status >= 400 {
+		writeJSON(w, reply.Status, map[string]any{"error": map[string]string{"message": reply.Text}})
+		return
+	}
+	usage := map[string]int{"prompt_tokens": reply.InputTokens, "completion_tokens": reply.OutputTokens}
+	if stream, _ := body["stream"].(bool); stream {
+		sse(w, []any{
+			map[string]any{"choices": []map[string]any{{"delta": map[string]string{"content": reply.Text}}}},
+			map[string]any{"choices": []any{}, "usage": usage},
+		}, true)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"choices": []map[string]any{{"message": map[string]string{"role": "assistant", "content": reply.Text}}},
+		"usage":   usage,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func sse(w http.ResponseWriter, events []any, done bool) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	for _, ev := range events {
+		b, _ := json.Marshal(ev)
+		fmt.Fprintf(w, "data: %s\n\n", b)
+	}
+	if done {
+		io.WriteString(w, "data: [DONE]\n\n")
+	}
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,150 @@
+package tui
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/bubbles/help"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/thecoolrobot/task-agent/internal/config"
+)
+
+// Version is shown in the help overlay; main sets it to the build version.
+var Version = "dev"
+
+// full is b labelled with all of its keys, for the help overlay; desc
+// replaces its help text when the action means something else on the
+// focused screen.
+func full(b key.Binding, desc string) key.Binding {
+	if desc == "" {
+		desc = b.Help().Desc
+	}
+	return key.NewBinding(key.WithKeys(b.Keys()...), key.WithHelp(keysLabel(b.Keys()), desc))
+}
+
+// fixed is a key that text entry always uses, whatever the keymap says.
+func fixed(k, desc string) key.Binding {
+	return key.NewBinding(key.WithKeys(k), key.WithHelp(prettyKey(k), desc))
+}
+
+// typingHelp is the help binding where text is typed, which only its
+// non-printable keys open.
+func (m Model) typingHelp() key.Binding {
+	var keys []string
+	for _, k := range m.keys.Help.Keys() {
+		if utf8.RuneCountInString(k) > 1 {
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) == 0 {
+		return key.NewBinding(key.WithKeys(m.keys.Help.Keys()...), key.WithHelp(keysLabel(m.keys.Help.Keys()), "help (not while typing)"), key.WithDisabled())
+	}
+	return key.NewBinding(key.WithKeys(keys...), key.WithHelp(keysLabel(keys), "help"))
+}
+
+// fullHelp names the focused screen and lists its bindings in columns.
+func (m Model) fullHelp() (string, [][]key.Binding) {
+	k := m.keys
+	global := [][]key.Binding{
+		{full(k.Search, "search tasks"), full(k.Compare, "compare models"), full(k.FollowUp, "follow up on last run"), full(k.History, "run history"), full(k.Profile, "next profile"), full(k.Edit, "edit prompt + run"), full(k.Preview, "preview request")},
+		{full(k.Config, "config screen"), full(k.Log, "execution log"), full(k.Theme, "next theme"), full(k.Refresh, "refresh tasks"), full(k.Help, "toggle help"), full(k.Quit, "quit (saves config)")},
+	}
+	switch {
+	case m.searching:
+		return "Search", [][]key.Binding{{fixed("enter", "search Asana"), fixed("esc", "cancel")}, {m.typingHelp()}}
+	case m.chatting:
+		return "Follow-up", [][]key.Binding{
+			{fixed("enter", "send"), fixed("esc", "close")},
+			{fixed("up", "scroll up"), fixed("down", "scroll down"), fixed("pgup", "page up"), fixed("pgdown", "page down")},
+			{m.typingHelp()},
+		}
+	case m.activePane == paneConfig:
+		return "Config", [][]key.Binding{
+			{full(k.NextField, ""), full(k.PrevField, ""), full(k.Execute, "cycle option · save field")},
+			{full(k.Left, ""), full(k.Right, ""), full(k.Save, "save and close"), full(k.Back, "discard changes")},
+			{m.typingHelp()},
+		}
+	case m.activePane == paneHistory:
+		return "History", [][]key.Binding{
+			{full(k.Up, ""), full(k.Down, ""), full(k.Execute, "follow up"), full(k.FollowUp, "follow up")},
+			{full(k.Export, "export, then:"), full(k.ExportZip, "as zip"), full(k.ExportTarGz, "as tar.gz"), full(k.ExportHTML, "as html")},
+			{full(k.Refresh, "reload"), full(k.Back, "close"), full(k.History, "close")},
+			{full(k.Help, "toggle help"), full(k.Quit, "quit")},
+		}
+	case m.activePane == paneCompare && m.compareReport != nil:
+		return "Comparison", [][]key.Binding{
+			{full(k.Left, "previous result"), full(k.Right, "next result"), full(k.Execute, "keep winner")},
+			{full(k.Back, "close (keeps all results)"), full(k.Help, "toggle help"), full(k.Quit, "quit")},
+		}
+	case m.activePane == paneModel:
+		return "Models", append([][]key.Binding{{
+			full(k.Up, "previous provider/model"), full(k.Down, "next provider/model"),
+			full(k.Execute, "open provider · use model"), full(k.NextPane, "providers → models → log"), full(k.Back, "tasks pane"),
+		}}, global...)
+	case m.activePane == paneLog:
+		return "Log", append([][]key.Binding{{
+			full(k.Up, "scroll up"), full(k.Down, "scroll down"), full(k.NextPane, "next pane"), full(k.Back, "tasks pane"),
+		}}, global...)
+	}
+	return "Tasks", append([][]key.Binding{{
+		full(k.Up, ""), full(k.Down, ""), full(k.Execute, "execute task"), full(k.NextPane, "next pane"), full(k.Back, "tasks pane"),
+	}}, global...)
+}
+
+// viewHelpOverlay is the modal key reference for the focused screen, with
+// the model in use, the config file and the version.
+func (m Model) viewHelpOverlay() string {
+	boxW := min(m.width-4, 96)
+	title, groups := m.fullHelp()
+
+	h := help.New()
+	h.FullSeparator = "   "
+	h.Styles.FullKey = lipgloss.NewStyle().Foreground(colorAccent).Background(colorBg).Bold(true)
+	h.Styles.FullDesc = lipgloss.NewStyle().Foreground(colorText).Background(colorBg)
+	h.Styles.FullSeparator = lipgloss.NewStyle().Background(colorBg)
+
+	// Columns that do not fit move to a row of their own.
+	var keyRows []string
+	for len(groups) > 0 {
+		n := len(groups)
+		for n > 1 && lipgloss.Width(h.FullHelpView(groups[:n])) > panelInnerW(boxW) {
+			n--
+		}
+		keyRows = append(keyRows, h.FullHelpView(groups[:n]))
+		groups = groups[n:]
+	}
+
+	label := lipgloss.NewStyle().Foreground(colorMuted).Background(colorBg).Width(10)
+	value := lipgloss.NewStyle().Foreground(colorText).Background(colorBg)
+	info := []string{
+		label.Render("Model") + value.Render(m.cfg.Provider+" / "+m.cfg.Model),
+	}
+	if m.cfg.Active != "" {
+		info = append(info, label.Render("Profile")+value.Render(m.cfg.Active))
+	}
+	info = append(info,
+		label.Render("Config")+value.Render(config.ConfigPath()),
+		label.Render("Version")+value.Render("task-agent "+Version),
+	)
+
+	footer := m.keys.Back.Help().Key + " or " + m.keys.Help.Help().Key + " to close"
+	content := strings.Join([]string{
+		lipgloss.NewStyle().Foreground(colorAccent).Background(colorBg).Bold(true).Render("Help — " + title),
+		"",
+		strings.Join(keyRows, "\n\n"),
+		"",
+		strings.Join(info, "\n"),
+		"",
+		lipgloss.NewStyle().Foreground(colorMuted).Background(colorBg).Render(footer),
+	}, "\n")
+	box := lipgloss.NewStyle().
+		Width(boxW).
+		Border(lipgloss.RoundedBorder()).BorderForeground(colorAccent).
+		Background(colorBg).Padding(0, 1).
+		Render(content)
+	return lipgloss.Place(m.width, m.height,
+		lipgloss.Center, lipgloss.Center, box,
+		lipgloss.WithWhitespaceBackground(colorBg))
+}
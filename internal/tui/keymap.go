@@ -0,0 +1,306 @@
+package tui
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/bubbles/key"
+)
+
+// KeyMap holds the TUI's key bindings, one per action. Text entry (search
+// and follow-up chat) keeps its fixed Enter/Esc keys.
+type KeyMap struct {
+	Up, Down, Left, Right key.Binding
+	Execute               key.Binding
+	NextPane              key.Binding
+	Back                  key.Binding
+	Search                key.Binding
+	Refresh               key.Binding
+	Config                key.Binding
+	Log                   key.Binding
+	Compare               key.Binding
+	History               key.Binding
+	Edit                  key.Binding
+	Preview               key.Binding
+	FollowUp              key.Binding
+	Profile               key.Binding
+	Theme                 key.Binding
+	Quit                  key.Binding
+	Help                  key.Binding
+	Export                key.Binding // history
+	ExportZip             key.Binding // export format picker
+	ExportTarGz           key.Binding // export format picker
+	ExportHTML            key.Binding // export format picker
+	NextField, PrevField  key.Binding // config screen
+	Save                  key.Binding // config screen
+}
+
+// keyActions names every binding as the keys setting does, with its help
+// text and default keys.
+var keyActions = []struct {
+	name, desc string
+	keys       []string
+	field      func(*KeyMap) *key.Binding
+}{
+	{"up", "up", []string{"up", "k"}, func(k *KeyMap) *key.Binding { return &k.Up }},
+	{"down", "down", []string{"down", "j"}, func(k *KeyMap) *key.Binding { return &k.Down }},
+	{"left", "previous option", []string{"left"}, func(k *KeyMap) *key.Binding { return &k.Left }},
+	{"right", "next option", []string{"right"}, func(k *KeyMap) *key.Binding { return &k.Right }},
+	{"execute", "execute", []string{"enter"}, func(k *KeyMap) *key.Binding { return &k.Execute }},
+	{"edit", "edit+run", []string{"e", "E"}, func(k *KeyMap) *key.Binding { return &k.Edit }},
+	{"preview", "preview", []string{"p", "P"}, func(k *KeyMap) *key.Binding { return &k.Preview }},
+	{"next_pane", "pane", []string{"tab"}, func(k *KeyMap) *key.Binding { return &k.NextPane }},
+	{"search", "search", []string{"/"}, func(k *KeyMap) *key.Binding { return &k.Search }},
+	{"compare", "compare", []string{"v", "V"}, func(k *KeyMap) *key.Binding { return &k.Compare }},
+	{"follow_up", "follow up", []string{"f", "F"}, func(k *KeyMap) *key.Binding { return &k.FollowUp }},
+	{"history", "history", []string{"h", "H"}, func(k *KeyMap) *key.Binding { return &k.History }},
+	{"profile", "profile", []string{"w", "W"}, func(k *KeyMap) *key.Binding { return &k.Profile }},
+	{"config", "config", []string{"c", "C"}, func(k *KeyMap) *key.Binding { return &k.Config }},
+	{"log", "log", []string{"l", "L"}, func(k *KeyMap) *key.Binding { return &k.Log }},
+	{"theme", "theme", []string{"t", "T"}, func(k *KeyMap) *key.Binding { return &k.Theme }},
+	{"refresh", "refresh", []string{"r"}, func(k *KeyMap) *key.Binding { return &k.Refresh }},
+	{"back", "back", []string{"esc"}, func(k *KeyMap) *key.Binding { return &k.Back }},
+	{"quit", "quit", []string{"q", "ctrl+c"}, func(k *KeyMap) *key.Binding { return &k.Quit }},
+	{"help", "help", []string{"?", "f1"}, func(k *KeyMap) *key.Binding { return &k.Help }},
+	{"export", "export", []string{"x"}, func(k *KeyMap) *key.Binding { return &k.Export }},
+	{"export_zip", "zip", []string{"z"}, func(k *KeyMap) *key.Binding { return &k.ExportZip }},
+	{"export_tar_gz", "tar.gz", []string{"t"}, func(k *KeyMap) *key.Binding { return &k.ExportTarGz }},
+	{"export_html", "html", []string{"h"}, func(k *KeyMap) *key.Binding { return &k.ExportHTML }},
+	{"next_field", "next field", []string{"tab"}, func(k *KeyMap) *key.Binding { return &k.NextField }},
+	{"prev_field", "previous field", []string{"shift+tab"}, func(k *KeyMap) *key.Binding { return &k.PrevField }},
+	{"save", "save", []string{"ctrl+s"}, func(k *KeyMap) *key.Binding { return &k.Save }},
+}
+
+// keyScopes lists the actions that are live together on each screen; no
+// two of them may share a key. Where text is typed, printable keys go to
+// the input instead, so there only an action's non-printable keys work.
+var keyScopes = map[string][]string{
+	"task list":     {"up", "down", "execute", "edit", "preview", "next_pane", "search", "compare", "follow_up", "history", "profile", "config", "log", "theme", "refresh", "back", "quit", "help"},
+	"history":       {"up", "down", "execute", "follow_up", "history", "refresh", "export", "back", "quit", "help"},
+	"comparison":    {"up", "down", "left", "right", "next_field", "prev_field", "execute", "back", "quit", "help"},
+	"config screen": {"left", "right", "execute", "next_field", "prev_field", "save", "back", "help"},
+	"export picker": {"export_zip", "export_tar_gz", "export_html", "back"},
+}
+
+// typingActions are the config screen actions needed while a text field has
+// focus, so each must keep a non-printable key.
+var typingActions = []string{"execute", "next_field", "prev_field", "save", "back"}
+
+// KeyActions lists the action names the keys setting takes.
+func KeyActions() []string {
+	names := make([]string, len(keyActions))
+	for i, a := range keyActions {
+		names[i] = a.name
+	}
+	return names
+}
+
+// NewKeyMap builds the keymap from the keys setting, which maps action
+// names to the keys that trigger them. Unknown actions are ignored, and a
+// rebinding that collides with another binding on the same screen, or
+// that leaves an action needed in the config screen's text fields with
+// only printable keys, falls back to the default. Each is reported as a
+// "keys.action: message" problem.
+func NewKeyMap(bindings map[string][]string) (KeyMap, []string) {
+	var problems []string
+	keys := map[string][]string{}
+	for _, a := range keyActions {
+		keys[a.name] = a.keys
+	}
+	names := make([]string, 0, len(bindings))
+	for name := range bindings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	overridden := map[string]bool{}
+	for _, name := range names {
+		switch {
+		case keys[name] == nil:
+			problems = append(problems, fmt.Sprintf("keys.%s: unknown action (want one of %s)", name, strings.Join(KeyActions(), ", ")))
+		case len(bindings[name]) == 0:
+			problems = append(problems, fmt.Sprintf("keys.%s: no keys — keeping %s", name, strings.Join(keys[name], ", ")))
+		default:
+			keys[name] = bindings[name]
+			overridden[name] = true
+		}
+	}
+
+	// Drop the rebindings involved in a conflict until none is left; the
+	// defaults never conflict, so this ends.
+	for {
+		reverted := false
+		for _, scope := range sortedScopes() {
+			for _, c := range conflicts(scope, keys) {
+				for _, name := range c.actions {
+					if !overridden[name] {
+						continue
+					}
+					problems = append(problems, fmt.Sprintf("keys.%s: %s %s — keeping %s", name, c.key, c.reason, strings.Join(defaultKeys(name), ", ")))
+					keys[name] = defaultKeys(name)
+					overridden[name] = false
+					reverted = true
+				}
+			}
+		}
+		if !reverted {
+			break
+		}
+	}
+
+	var km KeyMap
+	for _, a := range keyActions {
+		*a.field(&km) = key.NewBinding(key.WithKeys(keys[a.name]...), key.WithHelp(keyLabel(keys[a.name]), a.desc))
+	}
+	return km, problems
+}
+
+type keyConflict struct {
+	key     string
+	actions []string
+	reason  string
+}
+
+// conflicts finds the keys bound to more than one action in scope, and on
+// the config screen the typingActions that have only printable keys.
+func conflicts(scope string, keys map[string][]string) []keyConflict {
+	var out []keyConflict
+	owners := map[string][]string{}
+	var order []string
+	for _, name := range keyScopes[scope] {
+		for _, k := range keys[name] {
+			if owners[k] == nil {
+				order = append(order, k)
+			}
+			if !slices.Contains(owners[k], name) {
+				owners[k] = append(owners[k], name)
+			}
+		}
+	}
+	for _, k := range order {
+		if names := owners[k]; len(names) > 1 {
+			out = append(out, keyConflict{k, names, fmt.Sprintf("is bound to both %s on the %s", strings.Join(names, " and "), scope)})
+		}
+	}
+	if scope == "config screen" {
+		for _, name := range typingActions {
+			if !slices.ContainsFunc(keys[name], func(k string) bool { return !printable(k) }) {
+				out = append(out, keyConflict{strings.Join(keys[name], ", "), []string{name}, "would be typed into the config screen's text fields"})
+			}
+		}
+	}
+	return out
+}
+
+// printable reports whether k is a key that types a character.
+func printable(k string) bool { return utf8.RuneCountInString(k) == 1 }
+
+func sortedScopes() []string {
+	scopes := make([]string, 0, len(keyScopes))
+	for s := range keyScopes {
+		scopes = append(scopes, s)
+	}
+	sort.Strings(scopes)
+	return scopes
+}
+
+func defaultKeys(name string) []string {
+	for _, a := range keyActions {
+		if a.name == name {
+			return a.keys
+		}
+	}
+	return nil
+}
+
+// keyNames are the help-bar forms of keys whose tea names are spelled out.
+var keyNames = map[string]string{
+	"enter": "Enter", "tab": "Tab", "shift+tab": "Shift-Tab", "esc": "Esc", " ": "Space",
+	"up": "↑", "down": "↓", "left": "←", "right": "→", "pgup": "PgUp", "pgdown": "PgDn",
+}
+
+// keyLabel is the short form of keys shown in the help bar: the first key
+// that is not an arrow, in upper case when the letter is bound in both
+// cases.
+func keyLabel(keys []string) string {
+	if len(keys) == 0 {
+		return ""
+	}
+	k := keys[0]
+	for _, c := range keys {
+		if c != "up" && c != "down" && c != "left" && c != "right" {
+			k = c
+			break
+		}
+	}
+	if up := strings.ToUpper(k); utf8.RuneCountInString(k) == 1 && up != k && slices.Contains(keys, up) {
+		return up
+	}
+	return prettyKey(k)
+}
+
+// keysLabel spells out all of keys, for the help overlay, e.g. "q/Ctrl-C".
+// A letter bound in both cases is listed once, in upper case.
+func keysLabel(keys []string) string {
+	var labels []string
+	for _, k := range keys {
+		if up := strings.ToUpper(k); utf8.RuneCountInString(k) == 1 && up != k && slices.Contains(keys, up) {
+			continue
+		}
+		labels = append(labels, prettyKey(k))
+	}
+	return strings.Join(labels, "/")
+}
+
+// prettyKey is the help form of a tea key name.
+func prettyKey(k string) string {
+	if name, ok := keyNames[k]; ok {
+		return name
+	}
+	if rest, ok := strings.CutPrefix(k, "ctrl+"); ok {
+		return "Ctrl-" + strings.ToUpper(rest)
+	}
+	if rest, ok := strings.CutPrefix(k, "f"); ok && rest != "" && strings.Trim(rest, "0123456789") == "" {
+		return "F" + rest
+	}
+	return k
+}
+
+// hint is b with the help text desc, for an action whose meaning depends
+// on the screen, e.g. Enter keeping the winner of a comparison.
+func hint(b key.Binding, desc string) key.Binding {
+	return key.NewBinding(key.WithKeys(b.Keys()...), key.WithHelp(b.Help().Key, desc))
+}
+
+// pair joins two bindings into one help entry, e.g. "jk nav".
+func pair(a, b key.Binding, desc string) key.Binding {
+	return key.NewBinding(key.WithKeys(append(a.Keys(), b.Keys()...)...), key.WithHelp(a.Help().Key+b.Help().Key, desc))
+}
+
+// keyHelp lists the bindings shown in the help bar for the active pane.
+func (m Model) keyHelp() []key.Binding {
+	k := m.keys
+	switch {
+	case m.activePane == paneConfig:
+		return []key.Binding{hint(k.NextField, "navigate"), pair(k.Left, k.Right, "option"), k.Save, hint(k.Back, "cancel")}
+	case m.activePane == paneHistory:
+		return []key.Binding{pair(k.Down, k.Up, "nav"), hint(k.Execute, "follow up"), k.Export, hint(k.Refresh, "reload"), hint(k.Back, "close"), k.Help, k.Quit}
+	case m.activePane == paneCompare && m.compareReport != nil:
+		return []key.Binding{pair(k.Left, k.Right, "flip"), hint(k.Execute, "keep winner"), hint(k.Back, "close"), k.Help, k.Quit}
+	}
+	return []key.Binding{
+		pair(k.Down, k.Up, "nav"), k.Execute, k.Edit, k.Preview, k.NextPane, k.Search, k.Compare,
+		k.FollowUp, k.History, k.Profile, k.Config, k.Log, k.Refresh, k.Help, k.Quit,
+	}
+}
+
+// joinHelp renders bindings as plain "key desc" hints.
+func joinHelp(sep string, bindings ...key.Binding) string {
+	parts := make([]string, len(bindings))
+	for i, b := range bindings {
+		parts[i] = b.Help().Key + " " + b.Help().Desc
+	}
+	return strings.Join(parts, sep)
+}
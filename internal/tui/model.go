@@ -2,9 +2,15 @@
 package tui
 
 import (
+	"context"
 	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 
+	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/spinner"
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
@@ -12,8 +18,14 @@ import (
 
 	"github.com/thecoolrobot/task-agent/internal/ai"
 	"github.com/thecoolrobot/task-agent/internal/asana"
+	"github.com/thecoolrobot/task-agent/internal/compare"
 	"github.com/thecoolrobot/task-agent/internal/config"
+	"github.com/thecoolrobot/task-agent/internal/editor"
+	"github.com/thecoolrobot/task-agent/internal/export"
+	"github.com/thecoolrobot/task-agent/internal/history"
 	"github.com/thecoolrobot/task-agent/internal/output"
+	"github.com/thecoolrobot/task-agent/internal/runner"
+	"github.com/thecoolrobot/task-agent/internal/verify"
 )
 
 
@@ -25,7 +37,9 @@ const (
 	paneTasks pane = iota
 	paneModel
 	paneLog
-	paneConfig // full-screen config editor
+	paneConfig  // full-screen config editor
+	paneCompare // side-by-side model comparison results
+	paneHistory // past runs that can be resumed with a follow-up
 )
 
 // ─── Messages ────────────────────────────────────────────────────────────────
@@ -33,11 +47,36 @@ const (
 type tasksLoadedMsg struct{ tasks []asana.Task }
 type taskExecProgressMsg struct{ msg string }
 type taskExecDoneMsg struct {
-	result  *ai.TaskResult
-	outPath string
-	err     error
+	taskGID      string
+	result       *ai.TaskResult
+	outPath      string
+	verification *verify.Report
+	attempts     int
+	revision     int
+	err          error
+}
+type compareDoneMsg struct {
+	report *compare.Report
+	err    error
+}
+type historyLoadedMsg struct {
+	runs []history.Run
+	err  error
+}
+type exportDoneMsg struct {
+	path string
+	err  error
+}
+type promptEditedMsg struct {
+	task asana.Task
+	path string
+	err  error
 }
 type searchDoneMsg struct{ tasks []asana.Task }
+type previewDoneMsg struct {
+	task   asana.Task
+	dryRun *ai.DryRun
+}
 type errMsg struct{ err error }
 
 // pollProgressCmd drains one message from the progress channel (non-blocking).
@@ -64,17 +103,36 @@ type configField struct {
 	optionKey string  // e.g. "provider" or "model"
 }
 
-var configFields = []configField{
-	{label: "Workspace GID",    key: "workspace_gid"},
-	{label: "Project GID",      key: "project_gid"},
-	{label: "Output directory", key: "output_dir"},
-	{label: "Anthropic API key", key: "api_anthropic", secret: true},
-	{label: "OpenAI API key",    key: "api_openai",    secret: true},
-	{label: "Groq API key",      key: "api_groq",      secret: true},
-	{label: "Moonshot API key",  key: "api_moonshot",  secret: true},
-	{label: "AI Provider",       key: "provider",      options: []string{"anthropic", "openai", "groq", "moonshot", "ollama"}},
-	{label: "Model",             key: "model"},
-	{label: "Theme",             key: "theme",         options: []string{"dark", "light", "homebrew", "dracula", "solarized", "nord", "monokai"}},
+var configFields = buildConfigFields()
+
+// apiKeyPrefix marks config fields that hold a provider API key; the rest of
+// the field key is the provider ID.
+const apiKeyPrefix = "api_"
+
+// buildConfigFields derives the config screen from the provider registry so a
+// new backend gets its key field and provider option without TUI changes.
+func buildConfigFields() []configField {
+	fields := []configField{
+		{label: "Workspace GID", key: "workspace_gid"},
+		{label: "Project GID", key: "project_gid"},
+		{label: "Output directory", key: "output_dir"},
+	}
+	var providerIDs []string
+	for _, p := range ai.Providers() {
+		providerIDs = append(providerIDs, p.ID)
+		if p.RequiresKey() {
+			fields = append(fields, configField{label: p.Name + " API key", key: apiKeyPrefix + p.ID, secret: true})
+		}
+	}
+	return append(fields,
+		configField{label: "AI Provider", key: "provider", options: providerIDs},
+		configField{label: "Model", key: "model"},
+		configField{label: "Compare models (provider/model, …)", key: "compare_models"},
+		configField{label: "Output layout", key: "output_layout", options: output.Layouts},
+		configField{label: "Verify output (build/test hooks)", key: "verify", options: []string{"off", "on"}},
+		configField{label: "Fix attempts on failed verification", key: "fix_attempts"},
+		configField{label: "Theme", key: "theme", options: append([]string{AutoTheme}, ThemeNames()...)},
+	)
 }
 
 // ─── Model ───────────────────────────────────────────────────────────────────
@@ -100,8 +158,28 @@ type Model struct {
 	modelPane    modelPaneState
 	modelSubPane int // 0=providers 1=models
 
+	// Model comparison
+	compareReport *compare.Report
+	compareCursor int
+
+	// Verification status of the last run per task GID
+	runStatus map[string]string
+
+	// Run history and follow-up chat
+	historyRuns   []history.Run
+	historyCursor int
+	exportDir     string // run being exported while the format picker is open
+	lastRunDir    string // output folder of the most recent run, for F
+	chatInput     textinput.Model
+	chatting      bool
+	chatDir       string
+	chatConv      *history.Conversation // conversation shown in the chat pane
+	chatPending   string                // follow-up awaiting its reply
+	chatScroll    int                   // transcript lines scrolled up from the end
+
 	// Execution log
 	logLines    []logLine
+	logStart    int // first visible log line; -1 follows the tail
 	progressCh  <-chan string // live AI progress feed
 
 	// Search
@@ -121,6 +199,10 @@ type Model struct {
 	// Theme
 	themeIdx int
 
+	// Key bindings, from the keys setting
+	keys     KeyMap
+	showHelp bool // help overlay open
+
 	// Status bar
 	statusMsg  string
 	statusKind string // "ok" | "err" | "loading"
@@ -150,6 +232,14 @@ func New(cfg *config.Config, client *asana.Client) Model {
 	si.Placeholder = "search tasks..."
 	si.CharLimit = 100
 
+	ci := textinput.New()
+	ci.Placeholder = "ask for a change..."
+	ci.CharLimit = 2000
+
+	// Rebuild the fields so the theme options include the user themes
+	// loaded since init.
+	configFields = buildConfigFields()
+
 	// Build config inputs
 	cfgInputs := make([]textinput.Model, len(configFields))
 	cfgOptCursors := make([]int, len(configFields))
@@ -169,14 +259,6 @@ func New(cfg *config.Config, client *asana.Client) Model {
 			ti.SetValue(cfg.ProjectGID)
 		case "output_dir":
 			ti.SetValue(cfg.OutputDir)
-		case "api_anthropic":
-			ti.SetValue(cfg.APIKeys["anthropic"])
-		case "api_openai":
-			ti.SetValue(cfg.APIKeys["openai"])
-		case "api_groq":
-			ti.SetValue(cfg.APIKeys["groq"])
-		case "api_moonshot":
-			ti.SetValue(cfg.APIKeys["moonshot"])
 		case "provider":
 			// find cursor for current provider
 			for j, opt := range f.options {
@@ -187,23 +269,41 @@ func New(cfg *config.Config, client *asana.Client) Model {
 		case "model":
 			// will be populated dynamically
 			ti.SetValue(cfg.Model)
+		case "compare_models":
+			ti.SetValue(strings.Join(cfg.CompareModels, ", "))
+		case "fix_attempts":
+			ti.SetValue(strconv.Itoa(cfg.Verify.FixAttempts))
+		case "verify":
+			if cfg.Verify.Enabled {
+				cfgOptCursors[i] = 1
+			}
+		case "output_layout":
+			if cfg.OutputLayout == output.LayoutTask {
+				cfgOptCursors[i] = 1
+			}
+		case "theme":
+			if j := slices.Index(f.options, cfg.Theme); j >= 0 {
+				cfgOptCursors[i] = j
+			}
+		}
+		if id, ok := strings.CutPrefix(f.key, apiKeyPrefix); ok {
+			ti.SetValue(cfg.APIKeys[id])
 		}
 		cfgInputs[i] = ti
 	}
 
 	// Find initial theme index
-	themeIdx := 0
-	for i, t := range Themes {
-		if t.Name == cfg.Theme {
-			themeIdx = i
-			break
-		}
-	}
-	setTheme(Themes[themeIdx])
+	theme := themeFor(cfg.Theme, cfg.ThemeOverrides)
+	themeIdx := max(themeIndex(theme.Name), 0)
+	setTheme(theme)
+
+	// Problems with the keys setting are reported before the TUI starts.
+	keys, _ := NewKeyMap(cfg.Keys)
 
 	// Find initial model cursor for provider
+	providers := ai.Providers()
 	provCursor, modelCursor := 0, 0
-	for i, p := range ai.Providers {
+	for i, p := range providers {
 		if p.ID == cfg.Provider {
 			provCursor = i
 			for j, m := range p.Models {
@@ -220,14 +320,18 @@ func New(cfg *config.Config, client *asana.Client) Model {
 		activePane:    paneTasks,
 		spinner:       sp,
 		searchInput:   si,
+		chatInput:     ci,
+		logStart:      -1,
 		cfgInputs:     cfgInputs,
 		cfgOptCursors: cfgOptCursors,
 		themeIdx:      themeIdx,
+		keys:          keys,
+		runStatus:     map[string]string{},
 		statusMsg:     "Loading tasks...",
 		statusKind:    "loading",
 		loading:       true,
 		modelPane: modelPaneState{
-			providers:      ai.Providers,
+			providers:      providers,
 			providerCursor: provCursor,
 			modelCursor:    modelCursor,
 			activeProvider: cfg.Provider,
@@ -277,7 +381,9 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.tasks = msg.tasks
 		m.filteredTasks = msg.tasks
 		m.loading = false
-		m.statusMsg = fmt.Sprintf("Loaded %d tasks  [↑↓ navigate · Enter execute · Tab switch pane · C config · ? help]", len(m.tasks))
+		k := m.keys
+		m.statusMsg = fmt.Sprintf("Loaded %d tasks  [%s]", len(m.tasks),
+			joinHelp(" · ", pair(k.Down, k.Up, "navigate"), k.Execute, hint(k.NextPane, "switch pane"), k.Config, k.Help))
 		m.statusKind = "ok"
 
 	case searchDoneMsg:
@@ -299,6 +405,18 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.executing = false
 		m.loading = false
 		m.progressCh = nil
+		if m.chatting {
+			// Show the reply, or the turn kept despite an unusable reply. A
+			// follow-up that never reached the conversation goes back into
+			// the input to retry.
+			if conv, err := history.Load(m.chatDir); err == nil {
+				if len(conv.Messages) == len(m.chatConv.Messages) && m.chatInput.Value() == "" {
+					m.chatInput.SetValue(m.chatPending)
+				}
+				m.chatConv = conv
+			}
+			m.chatPending = ""
+		}
 		if msg.err != nil {
 			m.logLines = append(m.logLines, logLine{text: "❌  " + msg.err.Error(), kind: "err"})
 			m.statusMsg = "Execution failed — press Esc to return"
@@ -312,12 +430,96 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 			m.statusMsg = "✅ Task complete — output saved to " + msg.outPath
 			m.statusKind = "ok"
+			m.lastRunDir = msg.outPath
+			if msg.revision > 1 {
+				m.logLines = append(m.logLines, logLine{text: fmt.Sprintf("📝  Revision %d — previous in %s/%s", msg.revision, output.MetaDir, history.RevisionsDir), kind: "dim"})
+				m.statusMsg = fmt.Sprintf("✅ Revision %d saved to %s", msg.revision, msg.outPath)
+			}
+			if msg.attempts > 1 {
+				m.logLines = append(m.logLines, logLine{text: fmt.Sprintf("🔧  %d fix attempt(s) — earlier versions in %s/%s", msg.attempts-1, output.MetaDir, runner.IterationsDir), kind: "dim"})
+			}
+			if msg.verification != nil {
+				status := msg.verification.Status()
+				m.runStatus[msg.taskGID] = status
+				switch status {
+				case verify.StatusVerified:
+					m.logLines = append(m.logLines, logLine{text: "🧪  Verified", kind: "ok"})
+					m.statusMsg = "✅ Task complete and verified — output saved to " + msg.outPath
+				case verify.StatusFailed:
+					m.logLines = append(m.logLines, logLine{text: "🧪  " + msg.verification.Err().Error(), kind: "err"})
+					m.statusMsg = "⚠️  Verification failed — output saved to " + msg.outPath
+					m.statusKind = "err"
+				default:
+					m.logLines = append(m.logLines, logLine{text: "🧪  Not verified — no applicable hooks ran", kind: "dim"})
+				}
+			}
 		}
 
+	case compareDoneMsg:
+		m.executing = false
+		m.loading = false
+		m.progressCh = nil
+		if msg.err != nil {
+			m.logLines = append(m.logLines, logLine{text: "❌  " + msg.err.Error(), kind: "err"})
+			m.statusMsg = "Comparison failed — press Esc to return"
+			m.statusKind = "err"
+		} else {
+			m.compareReport = msg.report
+			m.compareCursor = 0
+			m.activePane = paneCompare
+			m.statusMsg = "⚖️  Comparison ready — ←→ flip results · Enter keep winner · Esc close"
+			m.statusKind = "ok"
+		}
+
+	case promptEditedMsg:
+		text, err := "", msg.err
+		if err == nil {
+			text, err = editor.ReadBack(msg.path)
+		} else {
+			os.Remove(msg.path)
+		}
+		if err != nil {
+			m.statusMsg = "❌ " + err.Error()
+			m.statusKind = "err"
+			break
+		}
+		return m.executeTask(msg.task, text)
+
+	case exportDoneMsg:
+		m.loading = false
+		if msg.err != nil {
+			m.statusMsg = "❌ Export failed: " + msg.err.Error()
+			m.statusKind = "err"
+			break
+		}
+		m.statusMsg = "📦 Exported to " + msg.path
+		m.statusKind = "ok"
+
+	case historyLoadedMsg:
+		m.loading = false
+		if msg.err != nil {
+			m.statusMsg = "❌ " + msg.err.Error()
+			m.statusKind = "err"
+			break
+		}
+		m.historyRuns = msg.runs
+		if m.historyCursor >= len(m.historyRuns) {
+			m.historyCursor = 0
+		}
+		m.statusMsg = fmt.Sprintf("%d past run(s) — Enter to follow up", len(m.historyRuns))
+		m.statusKind = "ok"
+
+	case previewDoneMsg:
+		m.showPreview(msg)
+
 	case errMsg:
 		m.loading = false
 		m.executing = false
 		m.progressCh = nil
+		if m.chatPending != "" && m.chatInput.Value() == "" {
+			m.chatInput.SetValue(m.chatPending)
+		}
+		m.chatPending = ""
 		m.statusMsg = "Error: " + msg.err.Error()
 		m.statusKind = "err"
 		cmds = append(cmds, m.spinner.Tick)
@@ -335,6 +537,51 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 func (m Model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 
+	// ── Help overlay ─────────────────────────────────────────────────────────
+	if m.showHelp {
+		if key.Matches(msg, m.keys.Back, m.keys.Help) {
+			m.showHelp = false
+		}
+		return m, nil
+	}
+	// Where text is typed, printable keys go to the input.
+	typing := m.chatting || m.searching || (m.activePane == paneConfig && len(configFields[m.cfgCursor].options) == 0)
+	if key.Matches(msg, m.keys.Help) && !(typing && msg.Type == tea.KeyRunes) {
+		m.showHelp = true
+		return m, nil
+	}
+
+	// ── Follow-up chat pane ──────────────────────────────────────────────────
+	if m.chatting {
+		switch msg.Type {
+		case tea.KeyEscape:
+			m.chatting = false
+			m.chatInput.Blur()
+		case tea.KeyEnter:
+			text := strings.TrimSpace(m.chatInput.Value())
+			if text == "" || m.executing {
+				return m, nil
+			}
+			m.chatInput.SetValue("")
+			m.chatPending = text
+			m.chatScroll = 0
+			return m.sendChat(m.chatDir, text)
+		case tea.KeyUp:
+			m.chatScroll++
+		case tea.KeyDown:
+			m.chatScroll = max(m.chatScroll-1, 0)
+		case tea.KeyPgUp:
+			m.chatScroll += m.chatPageLines()
+		case tea.KeyPgDown:
+			m.chatScroll = max(m.chatScroll-m.chatPageLines(), 0)
+		default:
+			var cmd tea.Cmd
+			m.chatInput, cmd = m.chatInput.Update(msg)
+			return m, cmd
+		}
+		return m, nil
+	}
+
 	// ── Search mode ──────────────────────────────────────────────────────────
 	if m.searching {
 		switch msg.Type {
@@ -365,51 +612,99 @@ func (m Model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		return m.handleConfigKey(msg)
 	}
 
+	// ── Comparison view ──────────────────────────────────────────────────────
+	if m.activePane == paneCompare && m.compareReport != nil {
+		return m.handleCompareKey(msg)
+	}
+
+	// ── History view ─────────────────────────────────────────────────────────
+	if m.activePane == paneHistory {
+		return m.handleHistoryKey(msg)
+	}
+
 	// ── Global keys ──────────────────────────────────────────────────────────
-	switch msg.String() {
-	case "q", "ctrl+c":
+	k := m.keys
+	switch {
+	case key.Matches(msg, k.Quit):
 		_ = config.Save(m.cfg)
 		return m, tea.Quit
 
-	case "esc":
+	case key.Matches(msg, k.Back):
 		m.activePane = paneTasks
 
-	case "tab":
+	case key.Matches(msg, k.NextPane):
 		m.cyclePane()
 
-	case "up", "k":
+	case key.Matches(msg, k.Up):
 		m.cursorUp()
 
-	case "down", "j":
+	case key.Matches(msg, k.Down):
 		m.cursorDown()
 
-	case "enter":
+	case key.Matches(msg, k.Execute):
 		return m.handleEnter()
 
-	case "/":
+	case key.Matches(msg, k.Search):
 		m.searching = true
 		m.searchInput.SetValue("")
 		m.searchInput.Focus()
 
-	case "r":
+	case key.Matches(msg, k.Refresh):
 		m.loading = true
 		m.statusMsg = "Refreshing..."
 		m.statusKind = "loading"
 		return m, tea.Batch(m.cmdLoadTasks(), m.spinner.Tick)
 
-	case "c", "C":
+	case key.Matches(msg, k.Config):
 		m.refreshConfigInputs()
 		m.cfgCursor = 0
 		m.cfgInputs[0].Focus()
 		m.activePane = paneConfig
 
-	case "l", "L":
+	case key.Matches(msg, k.Log):
 		m.activePane = paneLog
 
-	case "t", "T":
+	case key.Matches(msg, k.Compare):
+		if m.executing || len(m.filteredTasks) == 0 {
+			return m, nil
+		}
+		return m.compareTask(m.filteredTasks[m.taskCursor])
+
+	case key.Matches(msg, k.History):
+		m.activePane = paneHistory
+		m.loading = true
+		return m, tea.Batch(m.cmdLoadHistory(), m.spinner.Tick)
+
+	case key.Matches(msg, k.Edit):
+		if m.executing || m.activePane != paneTasks || len(m.filteredTasks) == 0 {
+			return m, nil
+		}
+		return m.editPrompt(m.filteredTasks[m.taskCursor])
+
+	case key.Matches(msg, k.Preview):
+		if m.executing || len(m.filteredTasks) == 0 {
+			return m, nil
+		}
+		return m, m.previewTask(m.filteredTasks[m.taskCursor])
+
+	case key.Matches(msg, k.FollowUp):
+		if m.executing {
+			return m, nil
+		}
+		if m.lastRunDir == "" {
+			m.statusMsg = "No run to follow up on yet — execute a task or pick one with H"
+			m.statusKind = "err"
+			return m, nil
+		}
+		m.openChat(m.lastRunDir)
+
+	case key.Matches(msg, k.Profile):
+		return m.switchProfile()
+
+	case key.Matches(msg, k.Theme):
 		m.themeIdx = (m.themeIdx + 1) % len(Themes)
-		setTheme(Themes[m.themeIdx])
 		m.cfg.Theme = Themes[m.themeIdx].Name
+		m.applyTheme(m.cfg.Theme)
 		m.statusMsg = "Theme: " + Themes[m.themeIdx].Name
 		m.statusKind = "ok"
 	}
@@ -417,19 +712,73 @@ func (m Model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+// switchProfile saves the current settings and cycles to the next config
+// profile (the base settings come after the last one), then reloads the
+// task list for the new workspace. The switch lasts for this session; use
+// "config profiles use" to change the default.
+func (m Model) switchProfile() (tea.Model, tea.Cmd) {
+	names := append([]string{""}, m.cfg.ProfileNames()...)
+	if len(names) == 1 {
+		m.statusMsg = "No profiles — create one with: task-agent config profiles create <name>"
+		m.statusKind = "err"
+		return m, nil
+	}
+	next := names[0]
+	for i, name := range names {
+		if name == m.cfg.Active {
+			next = names[(i+1)%len(names)]
+		}
+	}
+	if err := config.Save(m.cfg); err != nil {
+		m.statusMsg = "❌ Save failed: " + err.Error()
+		m.statusKind = "err"
+		return m, nil
+	}
+	load := func() (*config.Config, error) { return config.LoadProfile(next) }
+	if next == "" {
+		// LoadProfile("") falls back to the default profile; the base
+		// settings are the stop after the last profile.
+		load = config.LoadWithoutProfile
+	}
+	cfg, err := load()
+	if err != nil {
+		m.statusMsg = "❌ " + err.Error()
+		m.statusKind = "err"
+		return m, nil
+	}
+	config.ApplyBaseURLs(cfg)
+	m.cfg = cfg
+	m.refreshConfigInputs()
+	m.refreshModelPane()
+	name := cfg.Active
+	if name == "" {
+		name = "(base settings)"
+	}
+	m.loading = true
+	m.statusMsg = fmt.Sprintf("Profile: %s — %s / %s", name, cfg.Provider, cfg.Model)
+	m.statusKind = "ok"
+	return m, tea.Batch(m.cmdLoadTasks(), m.spinner.Tick)
+}
+
 func (m Model) handleConfigKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	f := configFields[m.cfgCursor]
+	k := m.keys
 
-	switch msg.Type {
-	case tea.KeyEscape:
+	switch {
+	case len(f.options) == 0 && (msg.Type == tea.KeyRunes || msg.Type == tea.KeySpace):
+		// Printable keys are typed, even if an action is bound to them.
+
+	case key.Matches(msg, k.Back):
 		m.cfgInputs[m.cfgCursor].Blur()
 		m.activePane = paneTasks
+		// Drop the theme being previewed.
+		m.applyTheme(m.cfg.Theme)
 		return m, nil
 
-	case tea.KeyTab, tea.KeyShiftTab:
+	case key.Matches(msg, k.NextField, k.PrevField):
 		// Move between fields
 		m.cfgInputs[m.cfgCursor].Blur()
-		if msg.Type == tea.KeyTab {
+		if key.Matches(msg, k.NextField) {
 			m.cfgCursor = (m.cfgCursor + 1) % len(configFields)
 		} else {
 			m.cfgCursor = (m.cfgCursor - 1 + len(configFields)) % len(configFields)
@@ -437,10 +786,11 @@ func (m Model) handleConfigKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		m.cfgInputs[m.cfgCursor].Focus()
 		return m, nil
 
-	case tea.KeyEnter:
+	case key.Matches(msg, k.Execute):
 		if len(f.options) > 0 {
 			// Cycle option
 			m.cfgOptCursors[m.cfgCursor] = (m.cfgOptCursors[m.cfgCursor] + 1) % len(f.options)
+			m.previewTheme()
 		} else {
 			// Save text field and advance
 			m.saveConfigField(m.cfgCursor)
@@ -450,14 +800,14 @@ func (m Model) handleConfigKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		}
 		return m, nil
 
-	case tea.KeyCtrlS:
+	case key.Matches(msg, k.Save):
 		// Save all and exit config
 		m.saveAllConfigFields()
 		if err := config.Save(m.cfg); err != nil {
 			m.statusMsg = "❌ Save failed: " + err.Error()
 			m.statusKind = "err"
 		} else {
-			m.statusMsg = "✅ Config saved to ~/.task-agent/config.json"
+			m.statusMsg = "✅ Config saved to " + config.ConfigPath()
 			m.statusKind = "ok"
 		}
 		m.cfgInputs[m.cfgCursor].Blur()
@@ -466,16 +816,18 @@ func (m Model) handleConfigKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		m.refreshModelPane()
 		return m, nil
 
-	case tea.KeyLeft:
+	case key.Matches(msg, k.Left):
 		if len(f.options) > 0 {
 			n := len(f.options)
 			m.cfgOptCursors[m.cfgCursor] = (m.cfgOptCursors[m.cfgCursor] - 1 + n) % n
+			m.previewTheme()
 		}
 		return m, nil
 
-	case tea.KeyRight:
+	case key.Matches(msg, k.Right):
 		if len(f.options) > 0 {
 			m.cfgOptCursors[m.cfgCursor] = (m.cfgOptCursors[m.cfgCursor] + 1) % len(f.options)
+			m.previewTheme()
 		}
 		return m, nil
 	}
@@ -499,14 +851,21 @@ func (m *Model) saveConfigField(idx int) {
 		m.cfg.ProjectGID = val
 	case "output_dir":
 		m.cfg.OutputDir = val
-	case "api_anthropic":
-		config.SetAPIKey(m.cfg, "anthropic", val)
-	case "api_openai":
-		config.SetAPIKey(m.cfg, "openai", val)
-	case "api_groq":
-		config.SetAPIKey(m.cfg, "groq", val)
-	case "api_moonshot":
-		config.SetAPIKey(m.cfg, "moonshot", val)
+	case "fix_attempts":
+		if n, err := strconv.Atoi(val); err == nil && n >= 0 {
+			m.cfg.Verify.FixAttempts = n
+		}
+	case "compare_models":
+		m.cfg.CompareModels = nil
+		for _, ref := range strings.Split(val, ",") {
+			if ref = strings.TrimSpace(ref); ref != "" {
+				m.cfg.CompareModels = append(m.cfg.CompareModels, ref)
+			}
+		}
+	default:
+		if id, ok := strings.CutPrefix(f.key, apiKeyPrefix); ok {
+			config.SetAPIKey(m.cfg, id, val)
+		}
 	}
 }
 
@@ -520,16 +879,13 @@ func (m *Model) saveAllConfigFields() {
 				if provDef, ok := ai.GetProvider(m.cfg.Provider); ok {
 					m.cfg.Model = provDef.DefaultModel
 				}
+			case "verify":
+				m.cfg.Verify.Enabled = f.options[m.cfgOptCursors[i]] == "on"
+			case "output_layout":
+				m.cfg.OutputLayout = f.options[m.cfgOptCursors[i]]
 			case "theme":
-				name := f.options[m.cfgOptCursors[i]]
-				m.cfg.Theme = name
-				for idx, t := range Themes {
-					if t.Name == name {
-						m.themeIdx = idx
-						setTheme(t)
-						break
-					}
-				}
+				m.cfg.Theme = f.options[m.cfgOptCursors[i]]
+				m.applyTheme(m.cfg.Theme)
 			}
 		} else {
 			m.saveConfigField(i)
@@ -537,16 +893,34 @@ func (m *Model) saveAllConfigFields() {
 	}
 }
 
+// applyTheme switches to the named theme, with the configured overrides.
+func (m *Model) applyTheme(name string) {
+	theme := themeFor(name, m.cfg.ThemeOverrides)
+	if i := themeIndex(theme.Name); i >= 0 {
+		m.themeIdx = i
+	}
+	setTheme(theme)
+}
+
+// previewTheme shows the theme chosen on the config screen before it is
+// saved; Esc goes back to the saved one.
+func (m *Model) previewTheme() {
+	if f := configFields[m.cfgCursor]; f.key == "theme" {
+		m.applyTheme(f.options[m.cfgOptCursors[m.cfgCursor]])
+	}
+}
+
 func (m *Model) refreshConfigInputs() {
 	vals := map[string]string{
-		"workspace_gid": m.cfg.WorkspaceGID,
-		"project_gid":   m.cfg.ProjectGID,
-		"output_dir":    m.cfg.OutputDir,
-		"api_anthropic": m.cfg.APIKeys["anthropic"],
-		"api_openai":    m.cfg.APIKeys["openai"],
-		"api_groq":      m.cfg.APIKeys["groq"],
-		"api_moonshot":  m.cfg.APIKeys["moonshot"],
-		"model":         m.cfg.Model,
+		"workspace_gid":  m.cfg.WorkspaceGID,
+		"project_gid":    m.cfg.ProjectGID,
+		"output_dir":     m.cfg.OutputDir,
+		"model":          m.cfg.Model,
+		"compare_models": strings.Join(m.cfg.CompareModels, ", "),
+		"fix_attempts":   strconv.Itoa(m.cfg.Verify.FixAttempts),
+	}
+	for id, key := range m.cfg.APIKeys {
+		vals[apiKeyPrefix+id] = key
 	}
 	for i, f := range configFields {
 		if v, ok := vals[f.key]; ok {
@@ -559,6 +933,18 @@ func (m *Model) refreshConfigInputs() {
 				}
 			}
 		}
+		if f.key == "verify" {
+			m.cfgOptCursors[i] = 0
+			if m.cfg.Verify.Enabled {
+				m.cfgOptCursors[i] = 1
+			}
+		}
+		if f.key == "output_layout" {
+			m.cfgOptCursors[i] = 0
+			if m.cfg.OutputLayout == output.LayoutTask {
+				m.cfgOptCursors[i] = 1
+			}
+		}
 		if f.key == "theme" {
 			for j, opt := range f.options {
 				if opt == m.cfg.Theme {
@@ -570,7 +956,7 @@ func (m *Model) refreshConfigInputs() {
 }
 
 func (m *Model) refreshModelPane() {
-	for i, p := range ai.Providers {
+	for i, p := range m.modelPane.providers {
 		if p.ID == m.cfg.Provider {
 			m.modelPane.providerCursor = i
 			m.modelPane.activeProvider = p.ID
@@ -605,6 +991,13 @@ func (m *Model) cyclePane() {
 
 func (m *Model) cursorUp() {
 	switch m.activePane {
+	case paneLog:
+		if m.logStart < 0 {
+			m.logStart = len(m.logLines) - 1
+		}
+		if m.logStart > 0 {
+			m.logStart--
+		}
 	case paneTasks:
 		if m.taskCursor > 0 {
 			m.taskCursor--
@@ -623,6 +1016,10 @@ func (m *Model) cursorUp() {
 
 func (m *Model) cursorDown() {
 	switch m.activePane {
+	case paneLog:
+		if m.logStart >= 0 && m.logStart < len(m.logLines)-1 {
+			m.logStart++
+		}
 	case paneTasks:
 		if m.taskCursor < len(m.filteredTasks)-1 {
 			m.taskCursor++
@@ -651,7 +1048,7 @@ func (m Model) handleEnter() (tea.Model, tea.Cmd) {
 		if len(m.filteredTasks) == 0 {
 			return m, nil
 		}
-		return m.executeTask(m.filteredTasks[m.taskCursor])
+		return m.executeTask(m.filteredTasks[m.taskCursor], "")
 
 	case paneModel:
 		if m.modelSubPane == 0 {
@@ -674,8 +1071,58 @@ func (m Model) handleEnter() (tea.Model, tea.Cmd) {
 
 // ─── Task execution with live streaming ───────────────────────────────────────
 
-func (m Model) executeTask(task asana.Task) (tea.Model, tea.Cmd) {
+// editPrompt suspends the TUI and opens the rendered prompt in $EDITOR; the
+// edited text is executed when the editor exits.
+func (m Model) editPrompt(task asana.Task) (tea.Model, tea.Cmd) {
+	path, err := editor.TempFile("task-agent-prompt-*.md", runner.Prompt(&task))
+	if err != nil {
+		m.statusMsg = "❌ " + err.Error()
+		m.statusKind = "err"
+		return m, nil
+	}
+	return m, tea.ExecProcess(editor.Command(path), func(err error) tea.Msg {
+		return promptEditedMsg{task: task, path: path, err: err}
+	})
+}
+
+// previewTask builds the exact request for task without sending it. The
+// API key is resolved off the event loop: a cmd: reference may take a while.
+func (m Model) previewTask(task asana.Task) tea.Cmd {
+	cfg := m.cfg
+	return func() tea.Msg {
+		apiKey, err := config.LookupAPIKey(cfg, cfg.Provider)
+		if err != nil {
+			return errMsg{err}
+		}
+		d, err := runner.Preview(&task, runner.Options{
+			ProviderID: cfg.Provider,
+			Model:      cfg.Model,
+			APIKey:     apiKey,
+		})
+		if err != nil {
+			return errMsg{err}
+		}
+		return previewDoneMsg{task: task, dryRun: d}
+	}
+}
+
+// showPreview puts a request preview in the log panel.
+func (m *Model) showPreview(msg previewDoneMsg) {
+	m.logLines = []logLine{{text: "🧪  Request preview: " + msg.task.Name, kind: "info"}, {text: "", kind: "info"}}
+	for _, line := range strings.Split(msg.dryRun.Format(), "\n") {
+		m.logLines = append(m.logLines, logLine{text: line, kind: "dim"})
+	}
+	m.logStart = 0
+	m.activePane = paneLog
+	m.statusMsg = fmt.Sprintf("🧪 Preview — ~%d input tokens, nothing sent  [jk scroll · Esc back]", msg.dryRun.EstimatedInputTokens)
+	m.statusKind = "ok"
+}
+
+// executeTask runs task; a non-empty prompt replaces the rendered task
+// message.
+func (m Model) executeTask(task asana.Task, prompt string) (tea.Model, tea.Cmd) {
 	m.executing = true
+	m.logStart = -1
 	m.loading = true
 	m.logLines = []logLine{
 		{text: fmt.Sprintf("⚡  Executing: %s", task.Name), kind: "info"},
@@ -690,29 +1137,34 @@ func (m Model) executeTask(task asana.Task) (tea.Model, tea.Cmd) {
 	ch := make(chan string, 64)
 	m.progressCh = ch
 
-	apiKey := config.GetAPIKey(m.cfg, m.cfg.Provider)
-	providerID := m.cfg.Provider
-	model := m.cfg.Model
-	outDir := m.cfg.OutputDir
-	if outDir == "" {
-		outDir = "./task-outputs"
+	cfg := m.cfg
+	opts := runner.Options{
+		ProviderID: m.cfg.Provider,
+		Model:      m.cfg.Model,
+		OutputDir:  m.cfg.OutputDir,
+		Layout:     m.cfg.OutputLayout,
+		Prompt:     prompt,
+		Verify:     m.cfg.Verify,
+		Retention:  m.cfg.Retention,
+		Progress:   func(s string) { ch <- s },
+	}
+	if opts.OutputDir == "" {
+		opts.OutputDir = "./task-outputs"
 	}
 
 	execCmd := func() tea.Msg {
-		client := ai.NewClient(providerID, model, apiKey)
-		taskMD := asana.FormatTaskMarkdown(&task)
-		result, err := client.ExecuteTask(taskMD, func(s string) {
-			ch <- s
-		})
-		close(ch)
+		apiKey, err := config.LookupAPIKey(cfg, opts.ProviderID)
 		if err != nil {
-			return taskExecDoneMsg{err: err}
+			close(ch)
+			return errMsg{err}
 		}
-		outPath, err := output.Write(result, &task, outDir)
+		opts.APIKey = apiKey
+		out, err := runner.Run(context.Background(), &task, opts)
+		close(ch)
 		if err != nil {
-			return taskExecDoneMsg{result: result, err: err}
+			return taskExecDoneMsg{taskGID: task.GetID(), err: err}
 		}
-		return taskExecDoneMsg{result: result, outPath: outPath}
+		return taskExecDoneMsg{taskGID: task.GetID(), result: out.Result, outPath: out.OutPath, verification: out.Verification, attempts: len(out.Attempts)}
 	}
 
 	return m, tea.Batch(
@@ -722,6 +1174,232 @@ func (m Model) executeTask(task asana.Task) (tea.Model, tea.Cmd) {
 	)
 }
 
+// ─── Model comparison ─────────────────────────────────────────────────────────
+
+func (m Model) compareTask(task asana.Task) (tea.Model, tea.Cmd) {
+	refs, err := ai.ParseModelRefs(strings.Join(m.cfg.CompareModels, ","))
+	if err == nil && len(refs) < 2 {
+		err = fmt.Errorf("set at least two compare models (provider/model) in config")
+	}
+	if err != nil {
+		m.statusMsg = "❌ " + err.Error()
+		m.statusKind = "err"
+		return m, nil
+	}
+
+	m.executing = true
+	m.loading = true
+	m.compareReport = nil
+	m.logLines = []logLine{
+		{text: fmt.Sprintf("⚖️  Comparing %d models: %s", len(refs), task.Name), kind: "info"},
+		{text: "", kind: "info"},
+	}
+	m.activePane = paneLog
+	m.statusMsg = "Running comparison..."
+	m.statusKind = "loading"
+
+	ch := make(chan string, 64)
+	m.progressCh = ch
+
+	cfg := m.cfg
+	outDir := cfg.OutputDir
+	if outDir == "" {
+		outDir = "./task-outputs"
+	}
+
+	execCmd := func() tea.Msg {
+		rep, err := compare.Run(&task, compare.Options{
+			Models:    refs,
+			OutputDir: outDir,
+			APIKey:    func(id string) (string, error) { return config.LookupAPIKey(cfg, id) },
+			Progress:  func(ref ai.ModelRef, msg string) { ch <- "[" + ref.String() + "] " + msg },
+		})
+		close(ch)
+		return compareDoneMsg{report: rep, err: err}
+	}
+
+	return m, tea.Batch(m.spinner.Tick, execCmd, pollProgress(ch))
+}
+
+func (m Model) handleCompareKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	n := len(m.compareReport.Entries)
+	k := m.keys
+	switch {
+	case key.Matches(msg, k.Quit):
+		_ = config.Save(m.cfg)
+		return m, tea.Quit
+	case key.Matches(msg, k.Back):
+		m.statusMsg = "Comparison kept in " + m.compareReport.Dir
+		m.statusKind = "ok"
+		m.compareReport = nil
+		m.activePane = paneTasks
+	case key.Matches(msg, k.Left, k.Up, k.PrevField):
+		m.compareCursor = (m.compareCursor - 1 + n) % n
+	case key.Matches(msg, k.Right, k.Down, k.NextField):
+		m.compareCursor = (m.compareCursor + 1) % n
+	case key.Matches(msg, k.Execute):
+		outDir := m.cfg.OutputDir
+		if outDir == "" {
+			outDir = "./task-outputs"
+		}
+		dest, err := m.compareReport.Keep(m.compareCursor, outDir)
+		if err != nil {
+			m.statusMsg = "❌ " + err.Error()
+			m.statusKind = "err"
+			return m, nil
+		}
+		m.statusMsg = "🏆 Kept " + m.compareReport.Winner + " → " + dest
+		m.statusKind = "ok"
+		m.compareReport = nil
+		m.activePane = paneTasks
+	}
+	return m, nil
+}
+
+// ─── History & follow-up chat ─────────────────────────────────────────────────
+
+func (m Model) outputDir() string {
+	if m.cfg.OutputDir == "" {
+		return "./task-outputs"
+	}
+	return m.cfg.OutputDir
+}
+
+func (m Model) cmdLoadHistory() tea.Cmd {
+	dir := m.outputDir()
+	return func() tea.Msg {
+		runs, err := history.List(dir)
+		return historyLoadedMsg{runs: runs, err: err}
+	}
+}
+
+func (m Model) handleHistoryKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.exportDir != "" {
+		return m.handleExportKey(msg)
+	}
+	k := m.keys
+	switch {
+	case key.Matches(msg, k.Quit):
+		_ = config.Save(m.cfg)
+		return m, tea.Quit
+	case key.Matches(msg, k.Back, k.History):
+		m.activePane = paneTasks
+	case key.Matches(msg, k.Up):
+		if m.historyCursor > 0 {
+			m.historyCursor--
+		}
+	case key.Matches(msg, k.Down):
+		if m.historyCursor < len(m.historyRuns)-1 {
+			m.historyCursor++
+		}
+	case key.Matches(msg, k.Refresh):
+		m.loading = true
+		return m, tea.Batch(m.cmdLoadHistory(), m.spinner.Tick)
+	case key.Matches(msg, k.Execute, k.FollowUp):
+		if !m.executing && m.historyCursor < len(m.historyRuns) {
+			m.openChat(m.historyRuns[m.historyCursor].Dir)
+		}
+	case key.Matches(msg, k.Export):
+		if m.historyCursor < len(m.historyRuns) {
+			m.exportDir = m.historyRuns[m.historyCursor].Dir
+			m.statusMsg = "Export as: " + joinHelp(" · ", m.keys.ExportZip, m.keys.ExportTarGz, m.keys.ExportHTML, hint(m.keys.Back, "cancel"))
+			m.statusKind = "loading"
+		}
+	}
+	return m, nil
+}
+
+// handleExportKey picks the format for the run chosen with the export key
+// and exports it next to the run folder. Any other key cancels.
+func (m Model) handleExportKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	dir := m.exportDir
+	m.exportDir = ""
+	var format string
+	switch k := m.keys; {
+	case key.Matches(msg, k.ExportZip):
+		format = export.Zip
+	case key.Matches(msg, k.ExportTarGz):
+		format = export.TarGz
+	case key.Matches(msg, k.ExportHTML):
+		format = export.HTML
+	default:
+		m.statusMsg = "Export cancelled"
+		m.statusKind = ""
+		return m, nil
+	}
+	m.loading = true
+	m.statusMsg = fmt.Sprintf("Exporting %s as %s...", filepath.Base(dir), format)
+	m.statusKind = "loading"
+	return m, tea.Batch(func() tea.Msg {
+		path, err := export.Export(dir, format, "")
+		return exportDoneMsg{path: path, err: err}
+	}, m.spinner.Tick)
+}
+
+// openChat opens the chat pane on the conversation recorded in dir.
+func (m *Model) openChat(dir string) {
+	conv, err := history.Load(dir)
+	if err != nil {
+		m.statusMsg = "❌ " + err.Error()
+		m.statusKind = "err"
+		return
+	}
+	m.chatting = true
+	m.chatDir = dir
+	m.chatConv = conv
+	m.chatPending = ""
+	m.chatScroll = 0
+	m.chatInput.SetValue("")
+	m.chatInput.Focus()
+}
+
+// sendChat continues the conversation recorded in dir; the reply becomes a
+// new revision of the folder.
+func (m Model) sendChat(dir, text string) (tea.Model, tea.Cmd) {
+	conv, err := history.Load(dir)
+	if err != nil {
+		m.statusMsg = "❌ " + err.Error()
+		m.statusKind = "err"
+		return m, nil
+	}
+	m.executing = true
+	m.logStart = -1
+	m.loading = true
+	m.logLines = []logLine{
+		{text: fmt.Sprintf("💬  Follow-up: %s", conv.TaskName), kind: "info"},
+		{text: fmt.Sprintf("🤖  Provider : %s / %s · revision %d", conv.Provider, conv.Model, conv.Revision), kind: "dim"},
+		{text: "    > " + text, kind: "info"},
+		{text: "", kind: "info"},
+	}
+	m.activePane = paneLog
+	m.statusMsg = "Sending follow-up..."
+	m.statusKind = "loading"
+
+	ch := make(chan string, 64)
+	m.progressCh = ch
+	cfg := m.cfg
+	opts := runner.ChatOptions{
+		Verify:   m.cfg.Verify,
+		Progress: func(s string) { ch <- s },
+	}
+
+	execCmd := func() tea.Msg {
+		apiKey, err := config.LookupAPIKey(cfg, conv.Provider)
+		if err != nil {
+			close(ch)
+			return errMsg{err}
+		}
+		opts.APIKey = apiKey
+		out, err := runner.Chat(context.Background(), dir, text, opts)
+		close(ch)
+		if err != nil {
+			return taskExecDoneMsg{taskGID: conv.TaskGID, err: err}
+		}
+		return taskExecDoneMsg{taskGID: conv.TaskGID, result: out.Result, outPath: out.OutPath, verification: out.Verification, revision: out.Revision}
+	}
+	return m, tea.Batch(m.spinner.Tick, execCmd, pollProgress(ch))
+}
+
 // ─── Search ───────────────────────────────────────────────────────────────────
 
 func (m Model) cmdSearch(query string) tea.Cmd {
@@ -0,0 +1,307 @@
+package tui
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/exp/teatest"
+
+	"github.com/thecoolrobot/task-agent/internal/ai"
+	"github.com/thecoolrobot/task-agent/internal/asana"
+	"github.com/thecoolrobot/task-agent/internal/config"
+	"github.com/thecoolrobot/task-agent/internal/history"
+	"github.com/thecoolrobot/task-agent/internal/runner"
+	"github.com/thecoolrobot/task-agent/internal/testharness"
+)
+
+func TestExecuteTaskFromList(t *testing.T) {
+	home := testharness.Home(t)
+	fake := testharness.NewFakeAsana(t, testharness.Fixtures{
+		"list": testharness.OK(testharness.Tasks("Write docs", "Fix bug")),
+	})
+	llm := testharness.NewLLMServer(t)
+
+	cfg := config.Defaults()
+	cfg.OutputDir = filepath.Join(home, "out")
+	config.SetAPIKey(cfg, "anthropic", "sk-test")
+
+	tm := teatest.NewTestModel(t, New(cfg, fake.Client()), teatest.WithInitialTermSize(120, 40))
+	waitFor(t, tm, "Write docs")
+
+	tm.Send(tea.KeyMsg{Type: tea.KeyEnter})
+	waitFor(t, tm, "Task complete")
+
+	tm.Send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("q")})
+	final := tm.FinalModel(t, teatest.WithFinalTimeout(5*time.Second)).(Model)
+	if final.statusKind != "ok" || !strings.Contains(final.statusMsg, cfg.OutputDir) {
+		t.Errorf("status = %q (%s)", final.statusMsg, final.statusKind)
+	}
+	if n := len(llm.Requests()); n != 1 {
+		t.Errorf("want 1 LLM request, got %d", n)
+	}
+	dirs, _ := filepath.Glob(filepath.Join(cfg.OutputDir, "*_Write_docs"))
+	if len(dirs) != 1 {
+		t.Fatalf("want one output folder, got %v", dirs)
+	}
+	if _, err := os.Stat(filepath.Join(dirs[0], "README.md")); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestListFailureShowsError(t *testing.T) {
+	testharness.Home(t)
+	fake := testharness.NewFakeAsana(t, testharness.Fixtures{
+		"list": testharness.Fail("rate limited"),
+	})
+	tm := teatest.NewTestModel(t, New(config.Defaults(), fake.Client()), teatest.WithInitialTermSize(120, 40))
+	waitFor(t, tm, "rate limited")
+	tm.Send(tea.KeyMsg{Type: tea.KeyCtrlC})
+	tm.WaitFinished(t, teatest.WithFinalTimeout(5*time.Second))
+}
+
+func TestKeyLookupErrorShown(t *testing.T) {
+	home := testharness.Home(t)
+	t.Setenv("ANTHROPIC_API_KEY", "")
+	fake := testharness.NewFakeAsana(t, testharness.Fixtures{
+		"list": testharness.OK(testharness.Tasks("Write docs")),
+	})
+	cfg := config.Defaults()
+	cfg.OutputDir = filepath.Join(home, "out")
+	config.SetAPIKey(cfg, "anthropic", "file:"+filepath.Join(home, "missing-key"))
+
+	tm := teatest.NewTestModel(t, New(cfg, fake.Client()), teatest.WithInitialTermSize(120, 40))
+	waitFor(t, tm, "Write docs")
+	tm.Send(tea.KeyMsg{Type: tea.KeyEnter})
+	waitFor(t, tm, "api_keys.anthropic")
+
+	tm.Send(tea.KeyMsg{Type: tea.KeyCtrlC})
+	final := tm.FinalModel(t, teatest.WithFinalTimeout(5*time.Second)).(Model)
+	if final.statusKind != "err" || !strings.Contains(final.statusMsg, "key file") || final.executing {
+		t.Errorf("status = %q (%s), executing = %v", final.statusMsg, final.statusKind, final.executing)
+	}
+}
+
+func TestChatPane(t *testing.T) {
+	home := testharness.Home(t)
+	fake := testharness.NewFakeAsana(t, testharness.Fixtures{
+		"list": testharness.OK(testharness.Tasks("Draft post")),
+	})
+	llm := testharness.NewLLMServer(t)
+	cfg := config.Defaults()
+	cfg.OutputDir = filepath.Join(home, "out")
+	config.SetAPIKey(cfg, "anthropic", "sk-test")
+	run, err := runner.Run(context.Background(), &asana.Task{GID: "42", Name: "Draft post"}, runner.Options{
+		ProviderID: cfg.Provider, Model: cfg.Model, APIKey: "sk-test", OutputDir: cfg.OutputDir,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	llm.ReplyResult(ai.TaskResult{OutputType: "markdown", Summary: "Made it shorter", Files: []ai.OutputFile{{Path: "post.md", Content: "# Short\n"}}})
+
+	m := New(cfg, fake.Client())
+	m.lastRunDir = run.OutPath
+	tm := teatest.NewTestModel(t, m, teatest.WithInitialTermSize(120, 40))
+	waitFor(t, tm, "Draft post")
+	tm.Send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("f")})
+	waitFor(t, tm, "Task: Draft post")
+
+	tm.Type("make it shorter")
+	tm.Send(tea.KeyMsg{Type: tea.KeyEnter})
+	waitFor(t, tm, "Made it shorter")
+
+	tm.Send(tea.KeyMsg{Type: tea.KeyEscape})
+	tm.Send(tea.KeyMsg{Type: tea.KeyCtrlC})
+	final := tm.FinalModel(t, teatest.WithFinalTimeout(5*time.Second)).(Model)
+	if final.chatting || final.chatConv.Revision != 2 {
+		t.Errorf("chatting %v, revision %d", final.chatting, final.chatConv.Revision)
+	}
+	turns := runner.Transcript(final.chatConv)
+	if len(turns) != 4 || turns[2].Content != "make it shorter" || !strings.HasPrefix(turns[3].Content, "Made it shorter\n  📄 post.md") {
+		t.Errorf("transcript: %+v", turns)
+	}
+}
+
+func TestUserThemes(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"ocean.toml":  "extends = \"nord\"\naccent = \"#00ffaa\"\n",
+		"dark.json":   `{"Bg": "#000000"}`,
+		"broken.json": `{"accent": "blue"}`,
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	warnings := LoadThemes(dir)
+	t.Cleanup(func() { LoadThemes(t.TempDir()) })
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "broken.json") || !strings.Contains(warnings[0], "invalid color") {
+		t.Errorf("warnings = %q", warnings)
+	}
+	if ocean := ThemeByName("ocean"); ocean.Accent != "#00ffaa" || ocean.Bg != ThemeByName("nord").Bg {
+		t.Errorf("ocean = %+v", ocean)
+	}
+	if dark := ThemeByName("dark"); dark.Bg != "#000000" || dark.Accent != "#58a6ff" {
+		t.Errorf("dark = %+v", dark)
+	}
+	if len(Themes) != len(builtinThemes)+1 {
+		t.Errorf("themes = %v", ThemeNames())
+	}
+	problems := ThemeProblems("sunset", map[string]string{"accent": "#fff", "glow": "#fff", "red": "nope"})
+	if len(problems) != 3 {
+		t.Errorf("problems = %q", problems)
+	}
+
+	// The config screen previews a theme as it is picked; Esc drops it.
+	cfg := config.Defaults()
+	cfg.ThemeOverrides = map[string]string{"accent": "#123456"}
+	m := New(cfg, nil)
+	m.activePane = paneConfig
+	m.cfgCursor = slices.IndexFunc(configFields, func(f configField) bool { return f.key == "theme" })
+	if colorBg != "#000000" || colorAccent != "#123456" {
+		t.Fatalf("start: bg %s accent %s", colorBg, colorAccent)
+	}
+	next, _ := m.Update(tea.KeyMsg{Type: tea.KeyRight})
+	if colorBg != ThemeByName("light").Bg || colorAccent != "#123456" {
+		t.Errorf("preview: bg %s accent %s", colorBg, colorAccent)
+	}
+	next.(Model).Update(tea.KeyMsg{Type: tea.KeyEscape})
+	if colorBg != "#000000" || cfg.Theme != "dark" {
+		t.Errorf("after Esc: bg %s theme %s", colorBg, cfg.Theme)
+	}
+}
+
+func TestKeyMap(t *testing.T) {
+	if _, problems := NewKeyMap(nil); problems != nil {
+		t.Fatalf("defaults: %q", problems)
+	}
+	if _, problems := NewKeyMap(map[string][]string{"up": {"j"}, "down": {"k"}}); problems != nil {
+		t.Errorf("swapping two keys: %q", problems)
+	}
+	_, problems := NewKeyMap(map[string][]string{
+		"search": {"r"},      // taken by refresh
+		"save":   {"s"},      // would only type in the config screen's text fields
+		"warp":   {"z"},      // no such action
+		"quit":   {"ctrl+q"}, // fine
+		"right":  {"n"},      // fine: not needed in text fields
+		"back":   {"esc", "b"},
+	})
+	got := strings.Join(problems, "\n")
+	if len(problems) != 3 || !strings.Contains(got, "keys.search: r is bound to both search and refresh") ||
+		!strings.Contains(got, "keys.save: s would be typed into the config screen's text fields") || !strings.Contains(got, "keys.warp: unknown action") {
+		t.Errorf("problems:\n%s", got)
+	}
+	if _, problems := NewKeyMap(map[string][]string{"export_zip": {"t"}}); len(problems) != 1 ||
+		!strings.Contains(problems[0], "keys.export_zip: t is bound to both export_zip and export_tar_gz on the export picker") {
+		t.Errorf("export picker problems: %q", problems)
+	}
+
+	testharness.Home(t)
+	cfg := config.Defaults()
+	cfg.Keys = map[string][]string{"quit": {"ctrl+q"}, "config": {"g"}, "refresh": {"q"}, "right": {"right", "n"}}
+	m := New(cfg, nil)
+	m.width = 200
+	bar := m.viewKeybinds()
+	for _, want := range []string{"jk", "nav", "Ctrl-Q", "quit", "g", "config"} {
+		if !strings.Contains(bar, want) {
+			t.Errorf("help bar lacks %q: %s", want, bar)
+		}
+	}
+	next, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("g")})
+	if m = next.(Model); m.activePane != paneConfig {
+		t.Fatalf("g opened pane %v, want config", m.activePane)
+	}
+	if !strings.Contains(m.viewKeybinds(), "Ctrl-S") {
+		t.Errorf("config help bar: %s", m.viewKeybinds())
+	}
+	// A printable key is typed into a text field and works on the others.
+	next, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("n")})
+	if m = next.(Model); m.cfgInputs[0].Value() != "n" {
+		t.Errorf("n in a text field typed %q", m.cfgInputs[0].Value())
+	}
+	for len(configFields[m.cfgCursor].options) == 0 {
+		next, _ = m.Update(tea.KeyMsg{Type: tea.KeyTab})
+		m = next.(Model)
+	}
+	opt := m.cfgOptCursors[m.cfgCursor]
+	next, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("n")})
+	if m = next.(Model); m.cfgOptCursors[m.cfgCursor] == opt {
+		t.Errorf("n did not pick the next %s", configFields[m.cfgCursor].key)
+	}
+	next, _ = m.Update(tea.KeyMsg{Type: tea.KeyEscape})
+	m = next.(Model)
+	if _, cmd := m.Update(tea.KeyMsg{Type: tea.KeyCtrlQ}); cmd == nil || cmd() != tea.Quit() {
+		t.Error("ctrl+q did not quit")
+	}
+
+	// The export format picker uses the keymap too.
+	cfg.Keys = map[string][]string{"export_html": {"m"}}
+	m = New(cfg, nil)
+	m.activePane = paneHistory
+	m.historyRuns = []history.Run{{Dir: filepath.Join(t.TempDir(), "run")}}
+	next, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("x")})
+	if m = next.(Model); !strings.Contains(m.statusMsg, "m html") {
+		t.Errorf("export prompt: %s", m.statusMsg)
+	}
+	next, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("m")})
+	if m = next.(Model); m.exportDir != "" || !strings.Contains(m.statusMsg, "as html") {
+		t.Errorf("m in the export picker: %s", m.statusMsg)
+	}
+}
+
+func TestHelpOverlay(t *testing.T) {
+	testharness.Home(t)
+	m := New(config.Defaults(), nil)
+	m.width, m.height = 120, 40
+	press := func(msg tea.KeyMsg) {
+		t.Helper()
+		next, _ := m.Update(msg)
+		m = next.(Model)
+	}
+	runes := func(s string) tea.KeyMsg { return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(s)} }
+
+	press(runes("?"))
+	view := m.View()
+	for _, want := range []string{"Help — Tasks", "execute task", "anthropic / claude-sonnet-4-6", config.ConfigPath(), "task-agent " + Version} {
+		if !strings.Contains(view, want) {
+			t.Errorf("help overlay lacks %q:\n%s", want, view)
+		}
+	}
+	press(runes("j"))
+	if !m.showHelp || m.taskCursor != 0 {
+		t.Error("keys other than Esc and ? should be ignored while help is open")
+	}
+	press(runes("?"))
+	if m.showHelp {
+		t.Fatal("? did not close help")
+	}
+
+	// On the config screen ? is typed into a text field; F1 opens help.
+	press(runes("c"))
+	press(runes("?"))
+	if m.showHelp || m.cfgInputs[0].Value() != "?" {
+		t.Fatalf("? on a text field: help %v, input %q", m.showHelp, m.cfgInputs[0].Value())
+	}
+	press(tea.KeyMsg{Type: tea.KeyF1})
+	if !strings.Contains(m.View(), "Help — Config") {
+		t.Errorf("F1 on the config screen:\n%s", m.View())
+	}
+	press(tea.KeyMsg{Type: tea.KeyEscape})
+	if m.showHelp || m.activePane != paneConfig {
+		t.Errorf("Esc should close only the overlay: help %v, pane %v", m.showHelp, m.activePane)
+	}
+}
+
+func waitFor(t *testing.T, tm *teatest.TestModel, s string) {
+	t.Helper()
+	teatest.WaitFor(t, tm.Output(), func(b []byte) bool {
+		return bytes.Contains(b, []byte(s))
+	}, teatest.WithDuration(10*time.Second), teatest.WithCheckInterval(20*time.Millisecond))
+}
@@ -0,0 +1,219 @@
+package tui
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/BurntSushi/toml"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// AutoTheme is the theme setting that picks the built-in dark or light
+// theme from the terminal's background color.
+const AutoTheme = "auto"
+
+// builtinThemes are the compiled-in themes, before LoadThemes adds the
+// user's.
+var builtinThemes = slices.Clone(Themes)
+
+// themeElements maps the element names used by theme files and
+// theme_overrides to the Theme fields they set.
+var themeElements = map[string]func(*Theme) *lipgloss.Color{
+	"bg":       func(t *Theme) *lipgloss.Color { return &t.Bg },
+	"surface":  func(t *Theme) *lipgloss.Color { return &t.Surface },
+	"border":   func(t *Theme) *lipgloss.Color { return &t.Border },
+	"accent":   func(t *Theme) *lipgloss.Color { return &t.Accent },
+	"selected": func(t *Theme) *lipgloss.Color { return &t.Selected },
+	"text":     func(t *Theme) *lipgloss.Color { return &t.Text },
+	"muted":    func(t *Theme) *lipgloss.Color { return &t.Muted },
+	"green":    func(t *Theme) *lipgloss.Color { return &t.Green },
+	"red":      func(t *Theme) *lipgloss.Color { return &t.Red },
+	"yellow":   func(t *Theme) *lipgloss.Color { return &t.Yellow },
+}
+
+// ThemeElements lists the element names a theme file or theme_overrides
+// can set, sorted.
+func ThemeElements() []string {
+	names := make([]string, 0, len(themeElements))
+	for name := range themeElements {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ThemeNames lists the available themes, built-in ones first.
+func ThemeNames() []string {
+	names := make([]string, len(Themes))
+	for i, t := range Themes {
+		names[i] = t.Name
+	}
+	return names
+}
+
+func themeIndex(name string) int {
+	return slices.IndexFunc(Themes, func(t Theme) bool { return t.Name == name })
+}
+
+var hexColor = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+// checkColor accepts the colors lipgloss renders: #rgb or #rrggbb, or an
+// ANSI color number from 0 to 255.
+func checkColor(s string) error {
+	if hexColor.MatchString(s) {
+		return nil
+	}
+	if n, err := strconv.Atoi(s); err == nil && n >= 0 && n <= 255 {
+		return nil
+	}
+	return fmt.Errorf("invalid color %q (want #rrggbb, #rgb or 0-255)", s)
+}
+
+// setElements sets the colors in values on t. Element names are matched
+// case-insensitively, so the Theme field names work too. Unknown elements
+// and invalid colors are skipped and returned as "element: message"
+// problems.
+func setElements(t *Theme, values map[string]string) []string {
+	var problems []string
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		field, ok := themeElements[strings.ToLower(k)]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s: unknown element (want one of %s)", k, strings.Join(ThemeElements(), ", ")))
+			continue
+		}
+		if err := checkColor(values[k]); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", k, err))
+			continue
+		}
+		*field(t) = lipgloss.Color(values[k])
+	}
+	return problems
+}
+
+// LoadThemes adds the *.json and *.toml theme files in dir to Themes,
+// after the built-in themes. A file named like a built-in theme replaces
+// it. Files that cannot be read or hold invalid colors are skipped, with
+// one warning each; a missing dir is not an error.
+func LoadThemes(dir string) []string {
+	Themes = slices.Clone(builtinThemes)
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return []string{err.Error()}
+	}
+	var warnings []string
+	for _, e := range entries {
+		ext := filepath.Ext(e.Name())
+		if e.IsDir() || (ext != ".json" && ext != ".toml") {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		t, err := readThemeFile(path)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("%s: %v — theme skipped", path, err))
+			continue
+		}
+		if i := themeIndex(t.Name); i >= 0 {
+			Themes[i] = t
+		} else {
+			Themes = append(Themes, t)
+		}
+	}
+	return warnings
+}
+
+// readThemeFile reads a theme file: an optional "name" (default: the file
+// name), an optional "extends" naming the theme that supplies the colors
+// the file leaves out (default: dark), and one color per element.
+func readThemeFile(path string) (Theme, error) {
+	raw := map[string]any{}
+	if filepath.Ext(path) == ".toml" {
+		if _, err := toml.DecodeFile(path, &raw); err != nil {
+			return Theme{}, err
+		}
+	} else {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return Theme{}, err
+		}
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return Theme{}, err
+		}
+	}
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	extends := "dark"
+	colors := map[string]string{}
+	for k, v := range raw {
+		s, ok := v.(string)
+		if !ok {
+			return Theme{}, fmt.Errorf("%s: want a string, got %v", k, v)
+		}
+		switch strings.ToLower(k) {
+		case "name":
+			name = s
+		case "extends":
+			extends = s
+		default:
+			colors[k] = s
+		}
+	}
+	i := themeIndex(extends)
+	if i < 0 {
+		return Theme{}, fmt.Errorf("extends unknown theme %q", extends)
+	}
+	t := Themes[i]
+	t.Name = name
+	if problems := setElements(&t, colors); len(problems) > 0 {
+		return Theme{}, errors.New(strings.Join(problems, "; "))
+	}
+	return t, nil
+}
+
+// darkBackground reports whether the terminal background is dark. The
+// terminal is asked once, before the program takes over its input.
+var darkBackground = sync.OnceValue(lipgloss.HasDarkBackground)
+
+// themeFor is the theme a config selects: name, with "auto" resolved from
+// the terminal background, and the overrides on top. Invalid overrides are
+// ignored here; ThemeProblems reports them.
+func themeFor(name string, overrides map[string]string) Theme {
+	if name == AutoTheme {
+		name = "light"
+		if darkBackground() {
+			name = "dark"
+		}
+	}
+	t := ThemeByName(name)
+	setElements(&t, overrides)
+	return t
+}
+
+// ThemeProblems checks the theme and theme_overrides settings against the
+// loaded themes, in the "key: message" form of config.Validate.
+func ThemeProblems(name string, overrides map[string]string) []string {
+	var problems []string
+	if name != "" && name != AutoTheme && themeIndex(name) < 0 {
+		problems = append(problems, fmt.Sprintf("theme: unknown theme %q (want %s or one of %s)", name, AutoTheme, strings.Join(ThemeNames(), ", ")))
+	}
+	for _, p := range setElements(&Theme{}, overrides) {
+		problems = append(problems, "theme_overrides."+p)
+	}
+	return problems
+}
@@ -2,6 +2,7 @@ package tui
 
 import (
 	"fmt"
+	"path/filepath"
 	"strings"
 
 	tea "github.com/charmbracelet/bubbletea"
@@ -9,7 +10,10 @@ import (
 
 	"github.com/thecoolrobot/task-agent/internal/ai"
 	"github.com/thecoolrobot/task-agent/internal/asana"
+	"github.com/thecoolrobot/task-agent/internal/compare"
 	"github.com/thecoolrobot/task-agent/internal/config"
+	"github.com/thecoolrobot/task-agent/internal/output"
+	"github.com/thecoolrobot/task-agent/internal/runner"
 )
 
 const (
@@ -58,9 +62,15 @@ func (m Model) View() string {
 			lipgloss.Center, lipgloss.Center,
 			"Terminal too small — please resize!")
 	}
+	if m.showHelp {
+		return m.viewHelpOverlay()
+	}
 	if m.searching {
 		return m.viewSearchOverlay()
 	}
+	if m.chatting {
+		return m.viewChatPane()
+	}
 	if m.activePane == paneConfig {
 		return m.viewConfigScreen()
 	}
@@ -84,7 +94,13 @@ func (m Model) View() string {
 	leftPanel := m.viewTaskList(leftW, bodyH)
 
 	var rightPanel string
-	if m.activePane == paneLog {
+	if m.activePane == paneCompare && m.compareReport != nil {
+		leftPanel = m.viewCompareList(leftW, bodyH)
+		rightPanel = m.viewComparePreview(rightW, bodyH)
+	} else if m.activePane == paneHistory {
+		leftPanel = m.viewHistoryList(leftW, bodyH)
+		rightPanel = m.viewHistoryPreview(rightW, bodyH)
+	} else if m.activePane == paneLog {
 		rightPanel = m.viewLogPanel(rightW, bodyH)
 	} else {
 		rightPanel = lipgloss.JoinVertical(lipgloss.Left,
@@ -267,6 +283,7 @@ func (m Model) renderDetailLines(task asana.Task, w int) []string {
 		kv("Status", "Incomplete")
 	}
 	kv("Priority", task.Priority)
+	kv("Last run", m.runStatus[task.GetID()])
 	kv("Due",      task.DueDate)
 	kv("Assignee", task.Assignee.Name)
 
@@ -389,12 +406,16 @@ func (m Model) viewLogPanel(outerW, outerH int) string {
 		contentH = 0
 	}
 
+	// Follow the tail unless the log has been scrolled.
 	start := 0
 	if len(m.logLines) > contentH {
 		start = len(m.logLines) - contentH
 	}
+	if m.logStart >= 0 && m.logStart < start {
+		start = m.logStart
+	}
 
-	for _, ll := range m.logLines[start:] {
+	for _, ll := range m.logLines[start:min(len(m.logLines), start+contentH)] {
 		var fg lipgloss.Color
 		switch ll.kind {
 		case "ok":
@@ -420,6 +441,151 @@ func (m Model) viewLogPanel(outerW, outerH int) string {
 	return activeBorderStyle.Width(outerW).Height(outerH).Render(strings.Join(lines, "\n"))
 }
 
+// ─── Comparison Panels ───────────────────────────────────────────────────────
+
+func (m Model) viewCompareList(outerW, outerH int) string {
+	iW := panelInnerW(outerW)
+	iH := panelInnerH(outerH)
+
+	titleSt := lipgloss.NewStyle().Foreground(colorMuted).Background(colorBg).Bold(true).Width(iW)
+	mutedSt := lipgloss.NewStyle().Foreground(colorMuted).Background(colorBg).Width(iW)
+
+	var lines []string
+	lines = append(lines, titleSt.Render(fmt.Sprintf("Compare (%d models)", len(m.compareReport.Entries))))
+
+	for i, e := range m.compareReport.Entries {
+		label := " " + e.Ref().String()
+		switch {
+		case i == m.compareCursor:
+			lines = append(lines, lipgloss.NewStyle().
+				Background(colorSelected).Foreground(colorText).Bold(true).Width(iW).Render(label))
+		case e.Error != "":
+			lines = append(lines, lipgloss.NewStyle().
+				Foreground(colorRed).Background(colorBg).Width(iW).Render(label))
+		default:
+			lines = append(lines, lipgloss.NewStyle().
+				Foreground(colorText).Background(colorBg).Width(iW).Render(label))
+		}
+		if e.Error != "" {
+			lines = append(lines, mutedSt.Render("   failed"))
+			continue
+		}
+		parsed := "parsed"
+		if !e.Parsed {
+			parsed = "raw"
+		}
+		lines = append(lines, mutedSt.Render(fmt.Sprintf("   %d files · %s · %s",
+			e.Files, compare.HumanBytes(e.Bytes), parsed)))
+		lines = append(lines, mutedSt.Render(fmt.Sprintf("   %d/%d tok · %s · %s",
+			e.Usage.InputTokens, e.Usage.OutputTokens, e.CostString(), e.LatencyString())))
+	}
+
+	lines = padLines(lines, iH, iW)
+	return activeBorderStyle.Width(outerW).Height(outerH).Render(strings.Join(lines, "\n"))
+}
+
+func (m Model) viewComparePreview(outerW, outerH int) string {
+	iW := panelInnerW(outerW)
+	iH := panelInnerH(outerH)
+
+	e := m.compareReport.Entries[m.compareCursor]
+	titleSt := lipgloss.NewStyle().Foreground(colorMuted).Background(colorBg).Bold(true).Width(iW)
+	textSt := lipgloss.NewStyle().Foreground(colorText).Background(colorBg).Width(iW)
+
+	var lines []string
+	lines = append(lines, titleSt.Render(fmt.Sprintf("Result %d/%d  %s", m.compareCursor+1, len(m.compareReport.Entries), e.Ref())))
+
+	switch {
+	case e.Error != "":
+		lines = append(lines, lipgloss.NewStyle().Foreground(colorRed).Background(colorBg).Width(iW).Render(" "+e.Error))
+	case e.Result != nil:
+		for _, line := range strings.Split(output.Preview(e.Result), "\n") {
+			lines = append(lines, textSt.Render(line))
+		}
+	}
+
+	lines = padLines(lines, iH, iW)
+	return borderStyle.Width(outerW).Height(outerH).Render(strings.Join(lines, "\n"))
+}
+
+// ─── History Panels ──────────────────────────────────────────────────────────
+
+func (m Model) viewHistoryList(outerW, outerH int) string {
+	iW := panelInnerW(outerW)
+	iH := panelInnerH(outerH)
+
+	titleSt := lipgloss.NewStyle().Foreground(colorMuted).Background(colorBg).Bold(true).Width(iW)
+	mutedSt := lipgloss.NewStyle().Foreground(colorMuted).Background(colorBg).Width(iW)
+
+	var lines []string
+	lines = append(lines, titleSt.Render(fmt.Sprintf("History (%d)", len(m.historyRuns))))
+	if len(m.historyRuns) == 0 {
+		lines = append(lines, mutedSt.Render(" No past runs in "+m.outputDir()))
+	}
+
+	// Two rows per run; keep the cursor on screen.
+	perPage := (iH - 1) / 2
+	start := 0
+	if perPage > 0 && m.historyCursor >= perPage {
+		start = m.historyCursor - perPage + 1
+	}
+	for i := start; i < len(m.historyRuns) && i < start+perPage; i++ {
+		c := m.historyRuns[i].Conversation
+		label := " " + c.TaskName
+		if i == m.historyCursor {
+			lines = append(lines, lipgloss.NewStyle().
+				Background(colorSelected).Foreground(colorText).Bold(true).Width(iW).Render(label))
+		} else {
+			lines = append(lines, lipgloss.NewStyle().
+				Foreground(colorText).Background(colorBg).Width(iW).Render(label))
+		}
+		lines = append(lines, mutedSt.Render(fmt.Sprintf("   rev %d · %s · %s",
+			c.Revision, c.Model, c.Updated.Format("Jan 02 15:04"))))
+	}
+
+	lines = padLines(lines, iH, iW)
+	return activeBorderStyle.Width(outerW).Height(outerH).Render(strings.Join(lines, "\n"))
+}
+
+func (m Model) viewHistoryPreview(outerW, outerH int) string {
+	iW := panelInnerW(outerW)
+	iH := panelInnerH(outerH)
+
+	titleSt := lipgloss.NewStyle().Foreground(colorMuted).Background(colorBg).Bold(true).Width(iW)
+	keySt := lipgloss.NewStyle().Foreground(colorYellow).Background(colorBg).Bold(true)
+	textSt := lipgloss.NewStyle().Foreground(colorText).Background(colorBg).Width(iW)
+	mutedSt := lipgloss.NewStyle().Foreground(colorMuted).Background(colorBg).Width(iW)
+
+	var lines []string
+	lines = append(lines, titleSt.Render("Run"))
+	if m.historyCursor < len(m.historyRuns) {
+		run := m.historyRuns[m.historyCursor]
+		c := run.Conversation
+		lines = append(lines, keySt.Render(" Folder: ")+textSt.Render(run.ID))
+		lines = append(lines, keySt.Render(" Model: ")+textSt.Render(c.Provider+" / "+c.Model))
+		lines = append(lines, mutedSt.Render(""))
+		for _, r := range c.Revisions {
+			label := "original run"
+			if r.Prompt != "" {
+				label = "“" + r.Prompt + "”"
+			}
+			if r.Status != "" {
+				label += " · " + r.Status
+			}
+			lines = append(lines, mutedSt.Render(fmt.Sprintf(" rev %d  %s", r.N, label)))
+		}
+		if c.Result != nil {
+			lines = append(lines, mutedSt.Render(""))
+			for _, line := range strings.Split(output.Preview(c.Result), "\n") {
+				lines = append(lines, textSt.Render(line))
+			}
+		}
+	}
+
+	lines = padLines(lines, iH, iW)
+	return borderStyle.Width(outerW).Height(outerH).Render(strings.Join(lines, "\n"))
+}
+
 // ─── Config Screen ───────────────────────────────────────────────────────────
 
 func (m Model) viewConfigScreen() string {
@@ -441,7 +607,7 @@ func (m Model) viewConfigScreen() string {
 		lipgloss.NewStyle().Foreground(colorAccent).Bold(true).Background(colorBg).Render("Configuration"))
 	rows = append(rows,
 		lipgloss.NewStyle().Foreground(colorMuted).Background(colorBg).
-			Render("Tab navigate  </> options  Ctrl-S save  Esc cancel"))
+			Render(joinHelp("  ", m.keyHelp()...)))
 	rows = append(rows, "")
 
 	fieldStart := 0
@@ -477,6 +643,16 @@ func (m Model) viewConfigScreen() string {
 				}
 			}
 			valueStr = "  " + strings.Join(opts, " ")
+			if lipgloss.Width(valueStr) > iW {
+				// Too many options for one line, e.g. with user themes.
+				valueStr = "  " + lipgloss.NewStyle().Foreground(colorYellow).Bold(true).Background(colorBg).
+					Render("< "+f.options[cursor]+" >") +
+					lipgloss.NewStyle().Foreground(colorMuted).Background(colorBg).
+						Render(fmt.Sprintf("  %d/%d", cursor+1, len(f.options)))
+			}
+			if f.key == "theme" && isFocused {
+				valueStr += "\n  " + themeSwatches()
+			}
 		} else {
 			inp := m.cfgInputs[i]
 			if isFocused {
@@ -541,6 +717,88 @@ func (m Model) viewSearchOverlay() string {
 		lipgloss.WithWhitespaceBackground(colorBg))
 }
 
+// ─── Follow-up Chat ──────────────────────────────────────────────────────────
+
+// chatPageLines is how many transcript lines the chat pane shows at once.
+func (m Model) chatPageLines() int {
+	// Border, title, subtitle, a blank line either side of the transcript,
+	// the input and its hints, and the status bar below the pane.
+	return max(m.height-1-2-6, 1)
+}
+
+// chatLines renders the chat transcript, wrapped to width: every recorded
+// turn, then the follow-up awaiting its reply with the latest progress.
+func (m Model) chatLines(width int) []string {
+	you := lipgloss.NewStyle().Foreground(colorYellow).Background(colorBg).Bold(true)
+	model := lipgloss.NewStyle().Foreground(colorAccent).Background(colorBg).Bold(true)
+	body := lipgloss.NewStyle().Foreground(colorText).Background(colorBg)
+	dim := lipgloss.NewStyle().Foreground(colorMuted).Background(colorBg)
+	wrap := lipgloss.NewStyle().Width(max(width-2, 1))
+
+	var lines []string
+	add := func(label lipgloss.Style, who, text string) {
+		lines = append(lines, label.Render(who))
+		for _, l := range strings.Split(wrap.Render(text), "\n") {
+			lines = append(lines, body.Render("  "+l))
+		}
+		lines = append(lines, "")
+	}
+	for _, t := range runner.Transcript(m.chatConv) {
+		if t.Role == "user" {
+			add(you, "you", t.Content)
+		} else {
+			add(model, m.chatConv.Model, t.Content)
+		}
+	}
+	if m.chatPending != "" {
+		add(you, "you", m.chatPending)
+		progress := "waiting for the reply…"
+		if n := len(m.logLines); n > 0 && strings.TrimSpace(m.logLines[n-1].text) != "" {
+			progress = strings.TrimSpace(m.logLines[n-1].text)
+		}
+		lines = append(lines, dim.Render(m.spinner.View()+" "+progress))
+	}
+	return lines
+}
+
+// viewChatPane is the full-screen follow-up chat: the run's conversation
+// with scrollback, and the input for the next instruction.
+func (m Model) viewChatPane() string {
+	conv := m.chatConv
+	iW := panelInnerW(m.width)
+	lines := m.chatLines(iW)
+	page := m.chatPageLines()
+	scroll := min(m.chatScroll, max(len(lines)-page, 0))
+	end := len(lines) - scroll
+	visible := lines[max(end-page, 0):end]
+	for len(visible) < page {
+		visible = append(visible, "")
+	}
+
+	dim := lipgloss.NewStyle().Foreground(colorMuted).Background(colorBg)
+	sub := fmt.Sprintf("%s / %s · revision %d · %s", conv.Provider, conv.Model, conv.Revision, filepath.Base(m.chatDir))
+	if scroll > 0 {
+		sub += fmt.Sprintf("  ↑ %d more", scroll)
+	}
+	hints := "Enter send · Esc close · ↑↓ PgUp/PgDn scroll"
+	if m.executing {
+		hints = "Waiting for the reply · Esc close (the reply still lands in the folder)"
+	}
+	rows := []string{
+		lipgloss.NewStyle().Foreground(colorAccent).Background(colorBg).Bold(true).Render("💬 Follow-up — " + conv.TaskName),
+		dim.Render(sub),
+		"",
+	}
+	rows = append(rows, visible...)
+	rows = append(rows,
+		"",
+		lipgloss.NewStyle().Foreground(colorYellow).Background(colorBg).Bold(true).Render("💬 ")+m.chatInput.View(),
+		dim.Render(hints),
+	)
+	pane := activeBorderStyle.Width(iW).Height(m.height - 1 - 2).Render(strings.Join(rows, "\n"))
+	return lipgloss.JoinVertical(lipgloss.Left, pane, m.viewStatusBar())
+}
+
 // ─── Status Bar ──────────────────────────────────────────────────────────────
 
 func (m Model) viewStatusBar() string {
@@ -568,29 +826,38 @@ func (m Model) viewStatusBar() string {
 // ─── Keybind Bar ─────────────────────────────────────────────────────────────
 
 func (m Model) viewKeybinds() string {
-	binds := []struct{ k, d string }{
-		{"jk", "nav"}, {"Enter", "execute"}, {"Tab", "pane"},
-		{"/", "search"}, {"C", "config"}, {"L", "log"}, {"r", "refresh"}, {"q", "quit"},
-	}
-	if m.activePane == paneConfig {
-		binds = []struct{ k, d string }{
-			{"Tab", "navigate"}, {"<>", "option"}, {"Ctrl-S", "save"}, {"Esc", "cancel"},
-		}
-	}
 	kSt  := lipgloss.NewStyle().Foreground(colorAccent).Background(colorSurface).Bold(true)
 	dSt  := lipgloss.NewStyle().Foreground(colorMuted).Background(colorSurface)
 	sep  := dSt.Render(" · ")
 	var parts []string
-	for _, b := range binds {
-		parts = append(parts, kSt.Render(b.k)+dSt.Render(" "+b.d))
+	used := 1
+	for _, b := range m.keyHelp() {
+		part := kSt.Render(b.Help().Key) + dSt.Render(" "+b.Help().Desc)
+		// Drop bindings that don't fit rather than wrapping the bar.
+		if used+lipgloss.Width(part)+3 > m.width {
+			break
+		}
+		used += lipgloss.Width(part) + 3
+		parts = append(parts, part)
 	}
 	return lipgloss.NewStyle().Width(m.width).Background(colorSurface).
 		Render(" " + strings.Join(parts, sep))
 }
 
+// themeSwatches previews the active theme: one block per element.
+func themeSwatches() string {
+	var b strings.Builder
+	for _, c := range []lipgloss.Color{colorBg, colorSurface, colorBorder, colorAccent, colorSelected, colorText, colorMuted, colorGreen, colorRed, colorYellow} {
+		b.WriteString(lipgloss.NewStyle().Foreground(c).Background(colorBg).Render("██"))
+	}
+	return b.String()
+}
+
 // ─── Run ─────────────────────────────────────────────────────────────────────
 
 func Run(cfg *config.Config, client *asana.Client) error {
+	// Ask the terminal for its background while nothing else reads input.
+	darkBackground()
 	m := New(cfg, client)
 	p := tea.NewProgram(m, tea.WithAltScreen(), tea.WithMouseCellMotion())
 	_, err := p.Run()
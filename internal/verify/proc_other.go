@@ -0,0 +1,12 @@
+//go:build !unix
+
+package verify
+
+import (
+	"os/exec"
+	"time"
+)
+
+func isolate(cmd *exec.Cmd) {
+	cmd.WaitDelay = 5 * time.Second
+}
@@ -0,0 +1,19 @@
+//go:build unix
+
+package verify
+
+import (
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+// isolate runs the hook in its own process group so a timeout kills the
+// whole tree (e.g. test binaries spawned by go test), not just the shell.
+func isolate(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	cmd.WaitDelay = 5 * time.Second
+}
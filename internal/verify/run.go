@@ -0,0 +1,164 @@
+package verify
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/thecoolrobot/task-agent/internal/ai"
+)
+
+// maxCapture bounds the stdout and stderr kept per hook; see capBuffer.
+const maxCapture = 64 << 10
+
+// Run executes every hook that applies to result inside dir and returns the
+// report. Progress receives a line per hook and the tail of failing output.
+func Run(ctx context.Context, dir string, result *ai.TaskResult, cfg Config, progress func(string)) *Report {
+	emit := func(s string) {
+		if progress != nil {
+			progress(s)
+		}
+	}
+	rep := &Report{Languages: Languages(result)}
+	for _, h := range HooksFor(cfg, result) {
+		emit(fmt.Sprintf("Verifying with %s: %s", h.Name, h.Command))
+		res := runHook(ctx, dir, h, cfg)
+		switch res.Status {
+		case Passed:
+			emit(fmt.Sprintf("✔ %s passed in %.1fs", h.Name, float64(res.DurationMS)/1000))
+		case Skipped:
+			emit(fmt.Sprintf("⏭ %s skipped — %s", h.Name, res.Reason))
+		case TimedOut:
+			emit(fmt.Sprintf("✘ %s timed out after %.0fs", h.Name, float64(res.DurationMS)/1000))
+		default:
+			emit(fmt.Sprintf("✘ %s failed (exit %d)", h.Name, res.ExitCode))
+			for _, line := range strings.Split(Tail(res.Stdout+res.Stderr, 10), "\n") {
+				emit("  │ " + line)
+			}
+		}
+		rep.Results = append(rep.Results, res)
+	}
+	return rep
+}
+
+func runHook(ctx context.Context, dir string, h Hook, cfg Config) Result {
+	res := Result{Hook: h.Name, Command: h.Command}
+	if tool := strings.Fields(h.Command); len(tool) > 0 {
+		if _, err := exec.LookPath(tool[0]); err != nil {
+			res.Status = Skipped
+			res.Reason = tool[0] + " not installed"
+			return res
+		}
+	}
+
+	timeout := time.Duration(h.TimeoutSeconds) * time.Second
+	if timeout == 0 {
+		timeout = time.Duration(cfg.TimeoutSeconds) * time.Second
+	}
+	if timeout == 0 {
+		timeout = 5 * time.Minute
+	}
+	res = Exec(ctx, dir, h.Command, timeout, cfg)
+	res.Hook = h.Name
+	return res
+}
+
+// Exec runs command through the platform shell in dir the way hooks run:
+// with cfg's resource limits, in its own process group so a timeout kills
+// everything it started, and with its output capped at maxCapture. The
+// result has no Hook name.
+func Exec(ctx context.Context, dir, command string, timeout time.Duration, cfg Config) Result {
+	res := Result{Command: command}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cmd := shellCommand(ctx, command, cfg)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "CI=1")
+	stdout, stderr := &capBuffer{max: maxCapture}, &capBuffer{max: maxCapture}
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+	isolate(cmd)
+
+	start := time.Now()
+	err := cmd.Run()
+	res.DurationMS = time.Since(start).Milliseconds()
+	res.Stdout, res.Stderr = stdout.String(), stderr.String()
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		res.Status = TimedOut
+		res.ExitCode = -1
+	case err != nil:
+		res.Status = Failed
+		res.ExitCode = -1
+		if ee, ok := err.(*exec.ExitError); ok {
+			res.ExitCode = ee.ExitCode()
+		} else {
+			res.Stderr += err.Error()
+		}
+	default:
+		res.Status = Passed
+	}
+	return res
+}
+
+// shellCommand wraps command in the platform shell, applying ulimits on
+// Unix.
+func shellCommand(ctx context.Context, command string, cfg Config) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd", "/C", command)
+	}
+	var limits string
+	if cfg.CPUSeconds > 0 {
+		limits += fmt.Sprintf("ulimit -t %d; ", cfg.CPUSeconds)
+	}
+	if cfg.MemoryMB > 0 {
+		limits += fmt.Sprintf("ulimit -v %d; ", cfg.MemoryMB*1024)
+	}
+	return exec.CommandContext(ctx, "sh", "-c", limits+command)
+}
+
+// capBuffer keeps the first and last bytes written to it, up to max in
+// all: the start of the output says what ran and the end holds the failure
+// summary, so what is dropped is the middle.
+type capBuffer struct {
+	max     int
+	head    []byte
+	tail    []byte // the last tailMax bytes, plus slack compacted away in bulk
+	written int
+}
+
+// headShare is the part of max kept from the start of the output.
+const headShare = 4
+
+func (c *capBuffer) headMax() int { return c.max / headShare }
+func (c *capBuffer) tailMax() int { return c.max - c.headMax() }
+
+func (c *capBuffer) Write(p []byte) (int, error) {
+	n := len(p)
+	c.written += n
+	if room := c.headMax() - len(c.head); room > 0 {
+		k := min(room, len(p))
+		c.head = append(c.head, p[:k]...)
+		p = p[k:]
+	}
+	c.tail = append(c.tail, p...)
+	if keep := c.tailMax(); len(c.tail) > 2*keep {
+		c.tail = append(c.tail[:0], c.tail[len(c.tail)-keep:]...)
+	}
+	return n, nil
+}
+
+func (c *capBuffer) String() string {
+	tail := c.tail
+	if keep := c.tailMax(); len(tail) > keep {
+		tail = tail[len(tail)-keep:]
+	}
+	if dropped := c.written - len(c.head) - len(tail); dropped > 0 {
+		return fmt.Sprintf("%s\n…(%d bytes of output truncated)…\n%s", c.head, dropped, tail)
+	}
+	return string(c.head) + string(tail)
+}
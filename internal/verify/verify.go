@@ -0,0 +1,256 @@
+// Package verify runs post-generation checks — build, test, lint — in an
+// output folder so a run can be marked verified or failed.
+package verify
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/thecoolrobot/task-agent/internal/ai"
+)
+
+// ErrFailed is wrapped by errors reporting a failed verification, so the CLI
+// can exit with a distinct code.
+var ErrFailed = errors.New("verification failed")
+
+// Hook is a shell command run in the output folder after the files are
+// written.
+type Hook struct {
+	Name string `json:"name"`
+	// Command runs with sh -c (cmd /C on Windows) in the output folder.
+	Command string `json:"command"`
+	// Languages and OutputTypes select when the hook runs. Both must match
+	// when set; a hook with neither runs for every output.
+	Languages   []string `json:"languages,omitempty"`
+	OutputTypes []string `json:"output_types,omitempty"`
+	// TimeoutSeconds overrides Config.TimeoutSeconds for this hook.
+	TimeoutSeconds int `json:"timeout_seconds,omitempty"`
+}
+
+// Config controls verification.
+type Config struct {
+	Enabled bool `json:"enabled"`
+	// Hooks replaces DefaultHooks when non-empty.
+	Hooks []Hook `json:"hooks,omitempty"`
+	// TimeoutSeconds bounds each hook (default 300).
+	TimeoutSeconds int `json:"timeout_seconds,omitempty"`
+	// MemoryMB and CPUSeconds are ulimit -v / -t limits for hook processes
+	// on Unix. Zero means no limit.
+	MemoryMB   int `json:"memory_mb,omitempty"`
+	CPUSeconds int `json:"cpu_seconds,omitempty"`
+	// FixAttempts is how many times a failing output is sent back to the
+	// model for repair. Zero disables the fix loop.
+	FixAttempts int `json:"fix_attempts,omitempty"`
+}
+
+// DefaultHooks are used when Config.Hooks is empty.
+var DefaultHooks = []Hook{
+	{Name: "go", Command: "go build ./... && go test ./...", Languages: []string{"go"}},
+	{Name: "npm", Command: "npm test", Languages: []string{"node"}},
+	{Name: "pytest", Command: "python -m pytest -q", Languages: []string{"python"}},
+	{Name: "markdownlint", Command: "markdownlint .", OutputTypes: []string{"markdown"}},
+}
+
+// HooksFor returns the hooks in cfg that apply to result.
+func HooksFor(cfg Config, result *ai.TaskResult) []Hook {
+	hooks := cfg.Hooks
+	if len(hooks) == 0 {
+		hooks = DefaultHooks
+	}
+	langs := Languages(result)
+	var out []Hook
+	for _, h := range hooks {
+		if len(h.OutputTypes) > 0 && !contains(h.OutputTypes, result.OutputType) {
+			continue
+		}
+		if len(h.Languages) > 0 && !overlaps(h.Languages, langs) {
+			continue
+		}
+		out = append(out, h)
+	}
+	return out
+}
+
+// Languages detects the languages present in a result from its file names.
+func Languages(result *ai.TaskResult) []string {
+	seen := map[string]bool{}
+	var out []string
+	add := func(l string) {
+		if !seen[l] {
+			seen[l] = true
+			out = append(out, l)
+		}
+	}
+	for _, f := range result.Files {
+		base := path.Base(f.Path)
+		switch {
+		case base == "go.mod" || strings.HasSuffix(base, ".go"):
+			add("go")
+		case base == "package.json":
+			add("node")
+		case base == "pyproject.toml" || base == "requirements.txt" || strings.HasSuffix(base, ".py"):
+			add("python")
+		case strings.HasSuffix(base, ".md"):
+			add("markdown")
+		}
+	}
+	return out
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func overlaps(a, b []string) bool {
+	for _, v := range a {
+		if contains(b, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// Status is the outcome of one hook.
+type Status string
+
+const (
+	Passed   Status = "passed"
+	Failed   Status = "failed"
+	TimedOut Status = "timeout"
+	Skipped  Status = "skipped"
+)
+
+// Result is one hook's outcome with its captured output.
+type Result struct {
+	Hook       string `json:"hook"`
+	Command    string `json:"command"`
+	Status     Status `json:"status"`
+	ExitCode   int    `json:"exit_code"`
+	DurationMS int64  `json:"duration_ms"`
+	Stdout     string `json:"stdout,omitempty"`
+	Stderr     string `json:"stderr,omitempty"`
+	// Reason explains a skipped hook.
+	Reason string `json:"reason,omitempty"`
+}
+
+// OK reports whether the hook passed or was skipped.
+func (r Result) OK() bool { return r.Status == Passed || r.Status == Skipped }
+
+// Report is the outcome of every hook run against one output folder.
+type Report struct {
+	Languages []string `json:"languages"`
+	Results   []Result `json:"results"`
+}
+
+// Overall statuses returned by Report.Status.
+const (
+	StatusVerified   = "verified"
+	StatusFailed     = "failed"
+	StatusUnverified = "unverified"
+)
+
+// Status is "failed" if any hook failed, "verified" if at least one passed
+// and "unverified" when every hook was skipped or none applied.
+func (r *Report) Status() string {
+	status := StatusUnverified
+	for _, res := range r.Results {
+		switch res.Status {
+		case Failed, TimedOut:
+			return StatusFailed
+		case Passed:
+			status = StatusVerified
+		}
+	}
+	return status
+}
+
+// Failures returns the hooks that failed or timed out.
+func (r *Report) Failures() []Result {
+	var out []Result
+	for _, res := range r.Results {
+		if !res.OK() {
+			out = append(out, res)
+		}
+	}
+	return out
+}
+
+// Err returns an error wrapping ErrFailed when any hook failed.
+func (r *Report) Err() error {
+	fails := r.Failures()
+	if len(fails) == 0 {
+		return nil
+	}
+	names := make([]string, len(fails))
+	for i, f := range fails {
+		names[i] = f.Hook
+	}
+	return fmt.Errorf("%w: %s", ErrFailed, strings.Join(names, ", "))
+}
+
+// Log renders the full captured output of every hook.
+func (r *Report) Log() string {
+	var b strings.Builder
+	for _, res := range r.Results {
+		fmt.Fprintf(&b, "=== %s: %s\n$ %s\n", res.Hook, res.Status, res.Command)
+		if res.Reason != "" {
+			fmt.Fprintf(&b, "%s\n", res.Reason)
+		}
+		if res.Stdout != "" {
+			fmt.Fprintf(&b, "--- stdout\n%s\n", strings.TrimRight(res.Stdout, "\n"))
+		}
+		if res.Stderr != "" {
+			fmt.Fprintf(&b, "--- stderr\n%s\n", strings.TrimRight(res.Stderr, "\n"))
+		}
+		if res.Status != Skipped {
+			fmt.Fprintf(&b, "--- exit %d in %dms\n", res.ExitCode, res.DurationMS)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// Markdown renders the report as a manifest section.
+func (r *Report) Markdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "## Verification: %s\n\n", r.Status())
+	if len(r.Results) == 0 {
+		b.WriteString("No verification hooks apply to this output.\n")
+		return b.String()
+	}
+	b.WriteString("| Hook | Command | Result | Time |\n|------|---------|--------|-----:|\n")
+	for _, res := range r.Results {
+		result := string(res.Status)
+		switch res.Status {
+		case Failed:
+			result = fmt.Sprintf("❌ exit %d", res.ExitCode)
+		case TimedOut:
+			result = "⏱️ timeout"
+		case Passed:
+			result = "✅ passed"
+		case Skipped:
+			result = "⏭️ " + res.Reason
+		}
+		fmt.Fprintf(&b, "| %s | `%s` | %s | %.1fs |\n", res.Hook, res.Command, result, float64(res.DurationMS)/1000)
+	}
+	for _, res := range r.Failures() {
+		fmt.Fprintf(&b, "\n### %s output\n\n```\n%s\n```\n", res.Hook, Tail(res.Stdout+res.Stderr, 40))
+	}
+	return b.String()
+}
+
+// Tail returns the last n lines of s.
+func Tail(s string, n int) string {
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,119 @@
+package verify
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/thecoolrobot/task-agent/internal/ai"
+)
+
+func TestHooksFor(t *testing.T) {
+	goResult := &ai.TaskResult{OutputType: "code_folder", Files: []ai.OutputFile{{Path: "go.mod"}, {Path: "cmd/main.go"}, {Path: "README.md"}}}
+	docResult := &ai.TaskResult{OutputType: "markdown", Files: []ai.OutputFile{{Path: "report.md"}}}
+
+	names := func(hooks []Hook) string {
+		var out []string
+		for _, h := range hooks {
+			out = append(out, h.Name)
+		}
+		return strings.Join(out, ",")
+	}
+	if got := names(HooksFor(Config{}, goResult)); got != "go" {
+		t.Errorf("code folder hooks = %q", got)
+	}
+	if got := names(HooksFor(Config{}, docResult)); got != "markdownlint" {
+		t.Errorf("markdown hooks = %q", got)
+	}
+	custom := Config{Hooks: []Hook{{Name: "all", Command: "true"}, {Name: "py", Command: "true", Languages: []string{"python"}}}}
+	if got := names(HooksFor(custom, goResult)); got != "all" {
+		t.Errorf("custom hooks = %q", got)
+	}
+}
+
+func TestRun(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hooks use sh")
+	}
+	result := &ai.TaskResult{Files: []ai.OutputFile{{Path: "a.txt"}}}
+	cfg := Config{Hooks: []Hook{
+		{Name: "ok", Command: "echo fine"},
+		{Name: "bad", Command: "echo broken >&2; exit 3"},
+		{Name: "slow", Command: "sleep 5", TimeoutSeconds: 1},
+		{Name: "missing", Command: "definitely-not-a-real-tool --check"},
+	}}
+	rep := Run(context.Background(), t.TempDir(), result, cfg, nil)
+
+	want := []Status{Passed, Failed, TimedOut, Skipped}
+	for i, res := range rep.Results {
+		if res.Status != want[i] {
+			t.Errorf("%s: status %s, want %s", res.Hook, res.Status, want[i])
+		}
+	}
+	if bad := rep.Results[1]; bad.ExitCode != 3 || !strings.Contains(bad.Stderr, "broken") {
+		t.Errorf("bad hook = %+v", bad)
+	}
+	if rep.Status() != StatusFailed || !errors.Is(rep.Err(), ErrFailed) {
+		t.Errorf("status %s, err %v", rep.Status(), rep.Err())
+	}
+	if !strings.Contains(rep.Markdown(), "exit 3") || !strings.Contains(rep.Log(), "--- stderr\nbroken") {
+		t.Errorf("report missing captured output:\n%s", rep.Log())
+	}
+}
+
+func TestCaptureKeepsTail(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hooks use sh")
+	}
+	// Far more than maxCapture of test chatter, then the failure summary.
+	cfg := Config{Hooks: []Hook{{Name: "long", Command: `echo "=== RUN first"; i=0; while [ $i -lt 20000 ]; do echo "ok line $i padding padding"; i=$((i+1)); done; echo "--- FAIL: TestLast"; exit 1`}}}
+	rep := Run(context.Background(), t.TempDir(), &ai.TaskResult{}, cfg, nil)
+	out := rep.Results[0].Stdout
+	if len(out) > maxCapture+100 {
+		t.Errorf("kept %d bytes, cap %d", len(out), maxCapture)
+	}
+	for _, want := range []string{"=== RUN first", "bytes of output truncated", "--- FAIL: TestLast"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output lacks %q", want)
+		}
+	}
+	if tail := Tail(out, 1); tail != "--- FAIL: TestLast" {
+		t.Errorf("tail = %q", tail)
+	}
+
+	var small capBuffer
+	small.max = 16
+	for _, s := range []string{"abc", "def"} {
+		small.Write([]byte(s))
+	}
+	if small.String() != "abcdef" {
+		t.Errorf("under the cap = %q", small.String())
+	}
+	small.Write([]byte("ghijklmnopqrstuvwxyz"))
+	if got := small.String(); got != "abcd\n…(10 bytes of output truncated)…\nopqrstuvwxyz" {
+		t.Errorf("over the cap = %q", got)
+	}
+}
+
+func TestExecKillsProcessGroup(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hooks use sh")
+	}
+	dir := t.TempDir()
+	// The shell starts a child, as go test starts test binaries, and waits
+	// for it; the child would leave a file behind if it outlived the hook.
+	start := time.Now()
+	res := Exec(context.Background(), dir, "(sleep 2; touch survived) & wait", time.Second, Config{})
+	if res.Status != TimedOut || res.Command == "" {
+		t.Fatalf("result %+v", res)
+	}
+	time.Sleep(3*time.Second - time.Since(start))
+	if _, err := os.Stat(filepath.Join(dir, "survived")); err == nil {
+		t.Error("the hook's child kept running after the timeout")
+	}
+}